@@ -1,7 +1,6 @@
 package cmd
 
 import (
-	"context"
 	"fmt"
 	"time"
 
@@ -25,7 +24,13 @@ func chunkIntervalGet(cmd *cobra.Command, args []string) error {
 
 	metric := args[0]
 
-	pool, err := OpenConnectionToDB(namespace, name, user, dbname, FORWARD_PORT_TSDB)
+	ctx := cmd.Context()
+	client, err := getKubeClient()
+	if err != nil {
+		return fmt.Errorf("could not get chunk interval for %v: %w", metric, err)
+	}
+
+	pool, err := OpenConnectionToDB(ctx, client, namespace, name, user, dbname)
 	if err != nil {
 		return fmt.Errorf("could not get chunk interval for %v: %w", metric, err)
 	}
@@ -33,7 +38,7 @@ func chunkIntervalGet(cmd *cobra.Command, args []string) error {
 
 	fmt.Printf("Getting chunk interval of %v\n", metric)
 	var microsecs int64
-	err = pool.QueryRow(context.Background(),
+	err = pool.QueryRow(ctx,
 		`SELECT d.interval_length
 	 FROM _timescaledb_catalog.hypertable h
 	 INNER JOIN LATERAL
@@ -1,39 +1,67 @@
 package cmd
 
 import (
-	"context"
 	"fmt"
+	"os"
 
 	"github.com/spf13/cobra"
 )
 
 // chunkIntervalResetCmd represents the chunk-interval reset command
 var chunkIntervalResetCmd = &cobra.Command{
-	Use:   "reset <metric>",
-	Short: "Resets the chunk interval for a specific metric back to the default",
-	Args:  cobra.ExactArgs(1),
+	Use:   "reset (<metric> | --match <pattern> | --all)",
+	Short: "Resets the chunk interval for a specific metric, or all matching metrics, back to the default",
+	Args:  metricSelectionArgs(0),
 	RunE:  chunkIntervalReset,
 }
 
 func init() {
 	chunkIntervalCmd.AddCommand(chunkIntervalResetCmd)
+	addMetricSelectionFlags(chunkIntervalResetCmd)
 }
 
 func chunkIntervalReset(cmd *cobra.Command, args []string) error {
 	var err error
 
-	metric := args[0]
+	var selection *metricSelection
+	selection, _, err = getMetricSelection(cmd, args)
+	if err != nil {
+		return fmt.Errorf("could not reset chunk interval: %w", err)
+	}
+
+	ctx := cmd.Context()
+	client, err := getKubeClient()
+	if err != nil {
+		return fmt.Errorf("could not reset chunk interval for %v: %w", selection, err)
+	}
 
-	pool, err := OpenConnectionToDB(namespace, name, user, dbname, FORWARD_PORT_TSDB)
+	pool, err := OpenConnectionToDB(ctx, client, namespace, name, user, dbname)
 	if err != nil {
-		return fmt.Errorf("could not reset chunk interval for %v: %w", metric, err)
+		return fmt.Errorf("could not reset chunk interval for %v: %w", selection, err)
 	}
 	defer pool.Close()
 
-	fmt.Printf("Resetting chunk interval for %v back to default\n", metric)
-	_, err = pool.Exec(context.Background(), "SELECT prom_api.reset_metric_chunk_interval($1)", metric)
+	metrics, err := selection.expand(ctx, pool)
+	if err != nil {
+		return fmt.Errorf("could not reset chunk interval for %v: %w", selection, err)
+	}
+
+	proceed, err := selection.confirm(os.Stdin, os.Stdout, metrics, "reset the chunk interval to the default")
+	if err != nil {
+		return fmt.Errorf("could not reset chunk interval for %v: %w", selection, err)
+	}
+	if !proceed {
+		fmt.Println("Aborted")
+		return nil
+	}
+
+	fmt.Printf("Resetting chunk interval for %v back to default\n", selection)
+	err = selection.apply(os.Stdout, metrics, func(metric string) error {
+		_, err := pool.Exec(ctx, "SELECT prom_api.reset_metric_chunk_interval($1)", metric)
+		return err
+	})
 	if err != nil {
-		return fmt.Errorf("could not reset chunk interval for %v: %w", metric, err)
+		return fmt.Errorf("could not reset chunk interval for %v: %w", selection, err)
 	}
 
 	return nil
@@ -1,9 +1,9 @@
 package cmd
 
 import (
-	"context"
 	"errors"
 	"fmt"
+	"os"
 	"time"
 
 	"github.com/spf13/cobra"
@@ -11,40 +11,69 @@ import (
 
 // chunkIntervalSetCmd represents the chunk-interval set command
 var chunkIntervalSetCmd = &cobra.Command{
-	Use:   "set <metric> <duration>",
-	Short: "Sets chunk interval for a specific metric",
-	Args:  cobra.ExactArgs(2),
+	Use:   "set (<metric> | --match <pattern> | --all) <duration>",
+	Short: "Sets chunk interval for a specific metric, or all matching metrics",
+	Args:  metricSelectionArgs(1),
 	RunE:  chunkIntervalSet,
 }
 
 func init() {
 	chunkIntervalCmd.AddCommand(chunkIntervalSetCmd)
+	addMetricSelectionFlags(chunkIntervalSetCmd)
 }
 
 func chunkIntervalSet(cmd *cobra.Command, args []string) error {
 	var err error
 
-	metric := args[0]
+	var selection *metricSelection
+	selection, args, err = getMetricSelection(cmd, args)
+	if err != nil {
+		return fmt.Errorf("could not set chunk interval: %w", err)
+	}
+
 	var chunk_interval time.Duration
-	chunk_interval, err = time.ParseDuration(args[1])
+	chunk_interval, err = time.ParseDuration(args[0])
 	if err != nil {
-		return fmt.Errorf("could not set chunk interval for %v: %w", metric, err)
+		return fmt.Errorf("could not set chunk interval for %v: %w", selection, err)
 	}
 
 	if chunk_interval.Minutes() < 1.0 {
-		return fmt.Errorf("could not set chunk interval for %v: %w", metric, errors.New("Chunk interval must be at least 1 minute"))
+		return fmt.Errorf("could not set chunk interval for %v: %w", selection, errors.New("Chunk interval must be at least 1 minute"))
 	}
 
-	pool, err := OpenConnectionToDB(namespace, name, user, dbname, FORWARD_PORT_TSDB)
+	ctx := cmd.Context()
+	client, err := getKubeClient()
 	if err != nil {
-		return fmt.Errorf("could not set chunk interval for %v: %w", metric, err)
+		return fmt.Errorf("could not set chunk interval for %v: %w", selection, err)
+	}
+
+	pool, err := OpenConnectionToDB(ctx, client, namespace, name, user, dbname)
+	if err != nil {
+		return fmt.Errorf("could not set chunk interval for %v: %w", selection, err)
 	}
 	defer pool.Close()
 
-	fmt.Printf("Setting chunk interval of %v to %v\n", metric, chunk_interval)
-	_, err = pool.Exec(context.Background(), "SELECT prom_api.set_metric_chunk_interval($1, $2::INTERVAL)", metric, chunk_interval)
+	metrics, err := selection.expand(ctx, pool)
+	if err != nil {
+		return fmt.Errorf("could not set chunk interval for %v: %w", selection, err)
+	}
+
+	proceed, err := selection.confirm(os.Stdin, os.Stdout, metrics, fmt.Sprintf("set the chunk interval to %v", chunk_interval))
+	if err != nil {
+		return fmt.Errorf("could not set chunk interval for %v: %w", selection, err)
+	}
+	if !proceed {
+		fmt.Println("Aborted")
+		return nil
+	}
+
+	fmt.Printf("Setting chunk interval of %v to %v\n", selection, chunk_interval)
+	err = selection.apply(os.Stdout, metrics, func(metric string) error {
+		_, err := pool.Exec(ctx, "SELECT prom_api.set_metric_chunk_interval($1, $2::INTERVAL)", metric, chunk_interval)
+		return err
+	})
 	if err != nil {
-		return fmt.Errorf("could not set chunk interval for %v: %w", metric, err)
+		return fmt.Errorf("could not set chunk interval for %v: %w", selection, err)
 	}
 
 	return nil
@@ -1,7 +1,6 @@
 package cmd
 
 import (
-	"context"
 	"errors"
 	"fmt"
 	"time"
@@ -34,14 +33,20 @@ func chunkIntervalSetDefault(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("could not set default chunk interval: %w", errors.New("Chunk interval must be at least 1 minute"))
 	}
 
-	pool, err := OpenConnectionToDB(namespace, name, user, dbname, FORWARD_PORT_TSDB)
+	ctx := cmd.Context()
+	client, err := getKubeClient()
+	if err != nil {
+		return fmt.Errorf("could not set default chunk interval: %w", err)
+	}
+
+	pool, err := OpenConnectionToDB(ctx, client, namespace, name, user, dbname)
 	if err != nil {
 		return fmt.Errorf("could not set default chunk interval: %w", err)
 	}
 	defer pool.Close()
 
 	fmt.Printf("Setting default chunk interval to %v\n", chunk_interval)
-	_, err = pool.Exec(context.Background(), "SELECT prom_api.set_default_chunk_interval($1::INTERVAL)", chunk_interval)
+	_, err = pool.Exec(ctx, "SELECT prom_api.set_default_chunk_interval($1::INTERVAL)", chunk_interval)
 	if err != nil {
 		return fmt.Errorf("could not set default chunk interval: %w", err)
 	}
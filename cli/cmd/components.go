@@ -0,0 +1,270 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"text/template"
+
+	"github.com/spf13/viper"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// stackComponent describes how to find one component of the stack in the
+// cluster. All string values are templates rendered with the release name
+// and namespace, so that they can be overridden in the config file under
+// components.<id> for charts that label their objects differently, e.g.
+//
+//	components:
+//	  timescaledb:
+//	    primary: "app={{ .Release.Name }}-db,role=leader"
+//	    secrets:
+//	      passwords: "{{ .Release.Name }}-db-credentials"
+type stackComponent struct {
+	ID   string
+	Name string
+	// Selector matches every pod, service and PVC of the component.
+	Selector map[string]string
+	// Primary matches the pod to exec into or forward to, defaults to Selector.
+	Primary map[string]string
+	// Service matches the service to forward to, defaults to Selector.
+	Service map[string]string
+	// Container is the main container of the component's pods.
+	Container string
+	// Port is the container or service port the component serves on.
+	Port       int
+	Secrets    map[string]string
+	ConfigMaps map[string]string
+}
+
+// defaultStackComponents match the labels and names of the tobs Helm chart.
+var defaultStackComponents = []stackComponent{
+	{
+		ID:        "timescaledb",
+		Name:      "TimescaleDB",
+		Selector:  map[string]string{"release": "{{ .Release.Name }}", "cluster-name": "{{ .Release.Name }}"},
+		Primary:   map[string]string{"release": "{{ .Release.Name }}", "role": "master"},
+		Container: "timescaledb",
+		Port:      5432,
+		Secrets:   map[string]string{"passwords": "{{ .Release.Name }}-timescaledb-passwords"},
+	},
+	{
+		ID:       "promscale",
+		Name:     "Promscale",
+		Selector: map[string]string{"app": "{{ .Release.Name }}-promscale"},
+		Service:  map[string]string{"release": "{{ .Release.Name }}", "app": "{{ .Release.Name }}-promscale"},
+		Port:     9201,
+	},
+	{
+		ID:         "prometheus",
+		Name:       "Prometheus",
+		Selector:   map[string]string{"release": "{{ .Release.Name }}", "app": "prometheus", "component": "server"},
+		Container:  "prometheus-server",
+		Port:       9090,
+		ConfigMaps: map[string]string{"config": "{{ .Release.Name }}-prometheus-config"},
+	},
+	{
+		ID:        "grafana",
+		Name:      "Grafana",
+		Selector:  map[string]string{"app.kubernetes.io/instance": "{{ .Release.Name }}", "app.kubernetes.io/name": "grafana"},
+		Container: "grafana",
+		Port:      3000,
+		Secrets: map[string]string{
+			"admin":       "{{ .Release.Name }}-grafana",
+			"datasources": "{{ .Release.Name }}-grafana-datasources",
+			"db":          "{{ .Release.Name }}-grafana-db",
+		},
+	},
+	{
+		ID:       "promlens",
+		Name:     "PromLens",
+		Selector: map[string]string{"app": "{{ .Fullname }}", "component": "promlens"},
+		Service:  map[string]string{"release": "{{ .Release.Name }}", "component": "promlens"},
+		Port:     8080,
+	},
+	{
+		ID:       "grafana-db",
+		Name:     "Grafana DB job",
+		Selector: map[string]string{"job-name": "{{ .Release.Name }}-grafana-db"},
+	},
+	{
+		ID:       "node-exporter",
+		Name:     "Node exporter",
+		Selector: map[string]string{"release": "{{ .Release.Name }}", "app": "prometheus", "component": "node-exporter"},
+	},
+	{
+		ID:       "kube-state-metrics",
+		Name:     "kube-state-metrics",
+		Selector: map[string]string{"app.kubernetes.io/instance": "{{ .Release.Name }}", "app.kubernetes.io/name": "kube-state-metrics"},
+	},
+}
+
+// stackComponents returns every component of the given release with the
+// overrides from the config file applied.
+func stackComponents(name, namespace string) ([]stackComponent, error) {
+	data := map[string]interface{}{
+		"Release":  map[string]string{"Name": name, "Namespace": namespace},
+		"Fullname": tobsFullname(name),
+	}
+
+	var components []stackComponent
+	for _, d := range defaultStackComponents {
+		c, err := overrideStackComponent(d, data)
+		if err != nil {
+			return nil, fmt.Errorf("invalid configuration of component %v: %w", d.ID, err)
+		}
+
+		for _, m := range []*map[string]string{&c.Selector, &c.Primary, &c.Service, &c.Secrets, &c.ConfigMaps} {
+			*m, err = renderComponentMap(*m, data)
+			if err != nil {
+				return nil, fmt.Errorf("invalid configuration of component %v: %w", c.ID, err)
+			}
+		}
+		c.Container, err = renderComponentValue(c.Container, data)
+		if err != nil {
+			return nil, fmt.Errorf("invalid configuration of component %v: %w", c.ID, err)
+		}
+
+		if c.Primary == nil {
+			c.Primary = c.Selector
+		}
+		if c.Service == nil {
+			c.Service = c.Selector
+		}
+
+		components = append(components, c)
+	}
+
+	return components, nil
+}
+
+// getStackComponent returns the component with the given ID of a release.
+func getStackComponent(name, namespace, id string) (stackComponent, error) {
+	components, err := stackComponents(name, namespace)
+	if err != nil {
+		return stackComponent{}, err
+	}
+
+	for _, c := range components {
+		if c.ID == id {
+			return c, nil
+		}
+	}
+
+	return stackComponent{}, fmt.Errorf("unknown component %v", id)
+}
+
+// overrideStackComponent replaces the fields of a default component that are
+// set in the config file. Selectors are replaced as a whole rather than
+// merged, so that a forked chart can drop labels the default selector
+// requires.
+func overrideStackComponent(d stackComponent, data interface{}) (stackComponent, error) {
+	c := d
+	key := "components." + d.ID + "."
+
+	if viper.IsSet(key + "name") {
+		c.Name = viper.GetString(key + "name")
+	}
+	for setting, selector := range map[string]*map[string]string{"selector": &c.Selector, "primary": &c.Primary, "service": &c.Service} {
+		if !viper.IsSet(key + setting) {
+			continue
+		}
+		var err error
+		*selector, err = parseComponentSelector(viper.Get(key+setting), data)
+		if err != nil {
+			return c, fmt.Errorf("invalid %v: %w", setting, err)
+		}
+	}
+	if viper.IsSet(key + "container") {
+		c.Container = viper.GetString(key + "container")
+	}
+	if viper.IsSet(key + "port") {
+		c.Port = viper.GetInt(key + "port")
+	}
+	if viper.IsSet(key + "secrets") {
+		c.Secrets = mergeComponentMap(d.Secrets, viper.GetStringMapString(key+"secrets"))
+	}
+	if viper.IsSet(key + "configmaps") {
+		c.ConfigMaps = mergeComponentMap(d.ConfigMaps, viper.GetStringMapString(key+"configmaps"))
+	}
+
+	return c, nil
+}
+
+// parseComponentSelector renders and parses a selector of the config file.
+// Selectors are strings like "app=foo,role=master" rather than maps, as
+// viper lowercases map keys and label keys are case sensitive.
+func parseComponentSelector(value interface{}, data interface{}) (map[string]string, error) {
+	selector, ok := value.(string)
+	if !ok {
+		return nil, fmt.Errorf("expected a selector like \"app=foo,role=master\", got %v", value)
+	}
+
+	rendered, err := renderComponentValue(selector, data)
+	if err != nil {
+		return nil, err
+	}
+
+	return labels.ConvertSelectorToLabelsMap(rendered)
+}
+
+// mergeComponentMap overrides single entries of a name map, as opposed to
+// selectors which are replaced as a whole.
+func mergeComponentMap(defaults, overrides map[string]string) map[string]string {
+	merged := make(map[string]string)
+	for k, v := range defaults {
+		merged[k] = v
+	}
+	for k, v := range overrides {
+		merged[k] = v
+	}
+	return merged
+}
+
+func renderComponentMap(m map[string]string, data interface{}) (map[string]string, error) {
+	if m == nil {
+		return nil, nil
+	}
+
+	rendered := make(map[string]string)
+	for k, v := range m {
+		value, err := renderComponentValue(v, data)
+		if err != nil {
+			return nil, err
+		}
+		rendered[k] = value
+	}
+
+	return rendered, nil
+}
+
+func renderComponentValue(value string, data interface{}) (string, error) {
+	if !strings.Contains(value, "{{") {
+		return value, nil
+	}
+
+	tmpl, err := template.New("component").Option("missingkey=error").Parse(value)
+	if err != nil {
+		return "", err
+	}
+
+	var out bytes.Buffer
+	err = tmpl.Execute(&out, data)
+	if err != nil {
+		return "", err
+	}
+
+	return out.String(), nil
+}
+
+// tobsFullname mirrors the tobs.fullname template of the Helm chart.
+func tobsFullname(name string) string {
+	fullname := name
+	if !strings.Contains(name, "tobs") {
+		fullname = name + "-tobs"
+	}
+	if len(fullname) > 63 {
+		fullname = fullname[:63]
+	}
+	return strings.TrimSuffix(fullname, "-")
+}
@@ -0,0 +1,115 @@
+package cmd
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/spf13/viper"
+)
+
+func TestStackComponentsDefaults(t *testing.T) {
+	viper.Reset()
+
+	timescaledb, err := getStackComponent("gg", "ns", "timescaledb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if timescaledb.Primary["release"] != "gg" || timescaledb.Primary["role"] != "master" {
+		t.Fatalf("unexpected primary selector %v", timescaledb.Primary)
+	}
+	if timescaledb.Secrets["passwords"] != "gg-timescaledb-passwords" {
+		t.Fatalf("unexpected passwords secret %v", timescaledb.Secrets["passwords"])
+	}
+
+	promlens, err := getStackComponent("gg", "ns", "promlens")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if promlens.Selector["app"] != "gg-tobs" || promlens.Service["release"] != "gg" {
+		t.Fatalf("unexpected PromLens selectors %v %v", promlens.Selector, promlens.Service)
+	}
+
+	grafana, err := getStackComponent("gg", "ns", "grafana")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if grafana.Service["app.kubernetes.io/name"] != "grafana" {
+		t.Fatalf("expected the service selector to default to the selector, got %v", grafana.Service)
+	}
+}
+
+func TestStackComponentsOverrides(t *testing.T) {
+	viper.Reset()
+	defer viper.Reset()
+
+	viper.SetConfigType("yaml")
+	err := viper.ReadConfig(bytes.NewBufferString(`
+components:
+  grafana:
+    selector: "app.kubernetes.io/name={{ .Release.Name }}-dashboards, Tier=UI"
+    port: 3001
+    secrets:
+      admin: "{{ .Release.Namespace }}-admin"
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	grafana, err := getStackComponent("gg", "ns", "grafana")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(grafana.Selector) != 2 || grafana.Selector["app.kubernetes.io/name"] != "gg-dashboards" || grafana.Selector["Tier"] != "UI" {
+		t.Fatalf("expected the selector to be replaced, got %v", grafana.Selector)
+	}
+	if grafana.Port != 3001 {
+		t.Fatalf("expected port 3001, got %d", grafana.Port)
+	}
+	if grafana.Secrets["admin"] != "ns-admin" || grafana.Secrets["datasources"] != "gg-grafana-datasources" {
+		t.Fatalf("expected the secrets to be merged, got %v", grafana.Secrets)
+	}
+
+	err = viper.ReadConfig(bytes.NewBufferString(`
+components:
+  promscale:
+    container: "{{ .Release.Nme }}"
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = stackComponents("gg", "ns"); err == nil {
+		t.Fatal("expected an error for an invalid template")
+	}
+
+	for _, config := range []string{
+		"components:\n  grafana:\n    selector:\n      App: grafana\n",
+		"components:\n  grafana:\n    primary: \"app\"\n",
+	} {
+		viper.Reset()
+		viper.SetConfigType("yaml")
+		if err = viper.ReadConfig(bytes.NewBufferString(config)); err != nil {
+			t.Fatal(err)
+		}
+		if _, err = stackComponents("gg", "ns"); err == nil {
+			t.Fatalf("expected an error for the selector of\n%v", config)
+		}
+	}
+}
+
+func TestTobsFullname(t *testing.T) {
+	tests := map[string]string{
+		"gg":                          "gg-tobs",
+		"tobs":                        "tobs",
+		"my-tobs":                     "my-tobs",
+		strings.Repeat("a", 70):       strings.Repeat("a", 63),
+		strings.Repeat("a", 58):       strings.Repeat("a", 58) + "-tobs",
+		strings.Repeat("a", 59):       strings.Repeat("a", 59) + "-tob",
+		strings.Repeat("a", 62) + "-": strings.Repeat("a", 62),
+	}
+	for name, expected := range tests {
+		if fullname := tobsFullname(name); fullname != expected {
+			t.Errorf("expected %q for %q, got %q", expected, name, fullname)
+		}
+	}
+}
@@ -0,0 +1,15 @@
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+)
+
+// compressionCmd represents the compression command
+var compressionCmd = &cobra.Command{
+	Use:   "compression",
+	Short: "Subcommand for operations on the compression of metrics",
+}
+
+func init() {
+	metricsCmd.AddCommand(compressionCmd)
+}
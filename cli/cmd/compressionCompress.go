@@ -0,0 +1,181 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// compressionCompressCmd represents the compression compress command
+var compressionCompressCmd = &cobra.Command{
+	Use:   "compress (<metric> | --match <pattern> | --all)",
+	Short: "Compresses the chunks of a specific metric, or all matching metrics, in a time range",
+	Args:  metricSelectionArgs(0),
+	RunE:  compressionCompress,
+}
+
+func init() {
+	compressionCmd.AddCommand(compressionCompressCmd)
+	addMetricSelectionFlags(compressionCompressCmd)
+	addChunkTimeRangeFlags(compressionCompressCmd)
+}
+
+func compressionCompress(cmd *cobra.Command, args []string) error {
+	return compressMetricChunks(cmd, args, false)
+}
+
+func addChunkTimeRangeFlags(cmd *cobra.Command) {
+	cmd.Flags().StringP("older-than", "", "", "Only chunks with all samples older than a duration ago, like 24h, or an RFC 3339 time")
+	cmd.Flags().StringP("newer-than", "", "", "Only chunks with all samples newer than a duration ago, like 168h, or an RFC 3339 time")
+}
+
+// getChunkTimeRange reads the bounds of the time range of chunks to change,
+// nil if not given.
+func getChunkTimeRange(cmd *cobra.Command, now time.Time) (olderThan *time.Time, newerThan *time.Time, err error) {
+	var value string
+	value, err = cmd.Flags().GetString("older-than")
+	if err != nil {
+		return nil, nil, err
+	}
+	olderThan, err = parseChunkTime(value, now)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid --older-than: %w", err)
+	}
+
+	value, err = cmd.Flags().GetString("newer-than")
+	if err != nil {
+		return nil, nil, err
+	}
+	newerThan, err = parseChunkTime(value, now)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid --newer-than: %w", err)
+	}
+
+	if olderThan != nil && newerThan != nil && !newerThan.Before(*olderThan) {
+		return nil, nil, fmt.Errorf("no chunks are newer than %v and older than %v", newerThan.Format(time.RFC3339), olderThan.Format(time.RFC3339))
+	}
+	return olderThan, newerThan, nil
+}
+
+func parseChunkTime(value string, now time.Time) (*time.Time, error) {
+	if value == "" {
+		return nil, nil
+	}
+	if ago, err := time.ParseDuration(value); err == nil {
+		t := now.Add(-ago)
+		return &t, nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return nil, fmt.Errorf("%v is neither a duration nor an RFC 3339 time", value)
+	}
+	return &t, nil
+}
+
+// describeChunkTimeRange describes the chunks in a time range, like "chunks
+// older than 2020-10-01T00:00:00Z".
+func describeChunkTimeRange(olderThan, newerThan *time.Time) string {
+	description := []string{"chunks"}
+	if newerThan != nil {
+		description = append(description, "newer than "+newerThan.UTC().Format(time.RFC3339))
+	}
+	if olderThan != nil {
+		if newerThan != nil {
+			description = append(description, "and")
+		}
+		description = append(description, "older than "+olderThan.UTC().Format(time.RFC3339))
+	}
+	if len(description) == 1 {
+		return "all chunks"
+	}
+	return strings.Join(description, " ")
+}
+
+// compressMetricChunks compresses, or decompresses, the chunks of the selected
+// metrics in the time range given on the command line.
+func compressMetricChunks(cmd *cobra.Command, args []string, decompress bool) error {
+	var err error
+
+	verb, action, done := "compress", "Compressing", "Compressed"
+	if decompress {
+		verb, action, done = "decompress", "Decompressing", "Decompressed"
+	}
+
+	var selection *metricSelection
+	selection, _, err = getMetricSelection(cmd, args)
+	if err != nil {
+		return fmt.Errorf("could not %v chunks: %w", verb, err)
+	}
+
+	var olderThan, newerThan *time.Time
+	olderThan, newerThan, err = getChunkTimeRange(cmd, time.Now())
+	if err != nil {
+		return fmt.Errorf("could not %v chunks of %v: %w", verb, selection, err)
+	}
+	chunks := describeChunkTimeRange(olderThan, newerThan)
+
+	ctx := cmd.Context()
+	client, err := getKubeClient()
+	if err != nil {
+		return fmt.Errorf("could not %v chunks of %v: %w", verb, selection, err)
+	}
+
+	pool, err := OpenConnectionToDB(ctx, client, namespace, name, user, dbname)
+	if err != nil {
+		return fmt.Errorf("could not %v chunks of %v: %w", verb, selection, err)
+	}
+	defer pool.Close()
+
+	metrics, err := selection.expand(ctx, pool)
+	if err != nil {
+		return fmt.Errorf("could not %v chunks of %v: %w", verb, selection, err)
+	}
+
+	proceed, err := selection.confirm(os.Stdin, os.Stdout, metrics, verb+" "+chunks)
+	if err != nil {
+		return fmt.Errorf("could not %v chunks of %v: %w", verb, selection, err)
+	}
+	if !proceed {
+		fmt.Println("Aborted")
+		return nil
+	}
+
+	fmt.Printf("%v %v of %v\n", action, chunks, selection)
+	var total int64
+	err = selection.apply(os.Stdout, metrics, func(metric string) error {
+		changed, err := compressChunks(ctx, pool, metric, olderThan, newerThan, decompress)
+		total += changed
+		return err
+	})
+	fmt.Printf("%v %d chunks\n", done, total)
+	if err != nil {
+		return fmt.Errorf("could not %v chunks of %v: %w", verb, selection, err)
+	}
+
+	return nil
+}
+
+// compressChunks compresses the uncompressed chunks of a metric in a time
+// range, or decompresses the compressed ones, and returns how many it changed.
+func compressChunks(ctx context.Context, pool *DBPool, metric string, olderThan, newerThan *time.Time, decompress bool) (int64, error) {
+	function, compressed := "compress_chunk", "IS NULL"
+	if decompress {
+		function, compressed = "decompress_chunk", "IS NOT NULL"
+	}
+
+	var changed int64
+	err := pool.QueryRow(ctx,
+		`SELECT count(`+function+`(c))
+	 FROM _prom_catalog.metric m
+	 CROSS JOIN LATERAL show_chunks(format('prom_data.%I', m.table_name)::regclass,
+	    older_than => $2::timestamptz, newer_than => $3::timestamptz) c
+	 INNER JOIN _timescaledb_catalog.chunk ch
+	    ON (format('%I.%I', ch.schema_name, ch.table_name)::regclass = c)
+	 WHERE m.metric_name = $1 AND ch.compressed_chunk_id `+compressed,
+		metric, olderThan, newerThan).Scan(&changed)
+	return changed, err
+}
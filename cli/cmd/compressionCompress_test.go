@@ -0,0 +1,47 @@
+package cmd
+
+import (
+	"testing"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+func TestGetChunkTimeRange(t *testing.T) {
+	now := time.Date(2020, 10, 8, 0, 0, 0, 0, time.UTC)
+	newCmd := func(flags ...string) *cobra.Command {
+		cmd := &cobra.Command{Use: "compress"}
+		addChunkTimeRangeFlags(cmd)
+		cmd.Flags().Parse(flags)
+		return cmd
+	}
+
+	olderThan, newerThan, err := getChunkTimeRange(newCmd(), now)
+	if err != nil || olderThan != nil || newerThan != nil {
+		t.Fatalf("expected no bounds, got %v %v %v", olderThan, newerThan, err)
+	}
+	if description := describeChunkTimeRange(olderThan, newerThan); description != "all chunks" {
+		t.Fatalf("unexpected description %q", description)
+	}
+
+	olderThan, newerThan, err = getChunkTimeRange(newCmd("--older-than", "24h", "--newer-than", "2020-10-01T00:00:00Z"), now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !olderThan.Equal(now.Add(-24*time.Hour)) || !newerThan.Equal(time.Date(2020, 10, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Fatalf("unexpected bounds %v %v", olderThan, newerThan)
+	}
+	expected := "chunks newer than 2020-10-01T00:00:00Z and older than 2020-10-07T00:00:00Z"
+	if description := describeChunkTimeRange(olderThan, newerThan); description != expected {
+		t.Fatalf("expected %q, got %q", expected, description)
+	}
+
+	_, _, err = getChunkTimeRange(newCmd("--older-than", "yesterday"), now)
+	if err == nil {
+		t.Fatal("expected an invalid time to be refused")
+	}
+	_, _, err = getChunkTimeRange(newCmd("--older-than", "168h", "--newer-than", "24h"), now)
+	if err == nil {
+		t.Fatal("expected an empty time range to be refused")
+	}
+}
@@ -0,0 +1,23 @@
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+)
+
+// compressionDecompressCmd represents the compression decompress command
+var compressionDecompressCmd = &cobra.Command{
+	Use:   "decompress (<metric> | --match <pattern> | --all)",
+	Short: "Decompresses the chunks of a specific metric, or all matching metrics, in a time range",
+	Args:  metricSelectionArgs(0),
+	RunE:  compressionDecompress,
+}
+
+func init() {
+	compressionCmd.AddCommand(compressionDecompressCmd)
+	addMetricSelectionFlags(compressionDecompressCmd)
+	addChunkTimeRangeFlags(compressionDecompressCmd)
+}
+
+func compressionDecompress(cmd *cobra.Command, args []string) error {
+	return compressMetricChunks(cmd, args, true)
+}
@@ -0,0 +1,48 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+)
+
+// compressionGetCmd represents the compression get command
+var compressionGetCmd = &cobra.Command{
+	Use:   "get <metric>",
+	Short: "Gets whether compression is enabled for a specific metric",
+	Args:  cobra.ExactArgs(1),
+	RunE:  compressionGet,
+}
+
+func init() {
+	compressionCmd.AddCommand(compressionGetCmd)
+}
+
+func compressionGet(cmd *cobra.Command, args []string) error {
+	var err error
+
+	metric := args[0]
+
+	ctx := cmd.Context()
+	client, err := getKubeClient()
+	if err != nil {
+		return fmt.Errorf("could not get compression setting for %v: %w", metric, err)
+	}
+
+	pool, err := OpenConnectionToDB(ctx, client, namespace, name, user, dbname)
+	if err != nil {
+		return fmt.Errorf("could not get compression setting for %v: %w", metric, err)
+	}
+	defer pool.Close()
+
+	fmt.Printf("Getting compression setting for %v\n", metric)
+	var enabled bool
+	err = pool.QueryRow(ctx, "SELECT _prom_catalog.get_metric_compression_setting($1)", metric).Scan(&enabled)
+	if err != nil {
+		return fmt.Errorf("could not get compression setting for %v: %w", metric, err)
+	}
+
+	fmt.Println(enabledString(enabled))
+
+	return nil
+}
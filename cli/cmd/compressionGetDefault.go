@@ -0,0 +1,46 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+)
+
+// compressionGetDefaultCmd represents the compression get-default command
+var compressionGetDefaultCmd = &cobra.Command{
+	Use:   "get-default",
+	Short: "Gets whether compression is enabled by default",
+	Args:  cobra.ExactArgs(0),
+	RunE:  compressionGetDefault,
+}
+
+func init() {
+	compressionCmd.AddCommand(compressionGetDefaultCmd)
+}
+
+func compressionGetDefault(cmd *cobra.Command, args []string) error {
+	var err error
+
+	ctx := cmd.Context()
+	client, err := getKubeClient()
+	if err != nil {
+		return fmt.Errorf("could not get default compression setting: %w", err)
+	}
+
+	pool, err := OpenConnectionToDB(ctx, client, namespace, name, user, dbname)
+	if err != nil {
+		return fmt.Errorf("could not get default compression setting: %w", err)
+	}
+	defer pool.Close()
+
+	fmt.Println("Getting default compression setting")
+	var enabled bool
+	err = pool.QueryRow(ctx, "SELECT _prom_catalog.get_default_compression_setting()").Scan(&enabled)
+	if err != nil {
+		return fmt.Errorf("could not get default compression setting: %w", err)
+	}
+
+	fmt.Println(enabledString(enabled))
+
+	return nil
+}
@@ -0,0 +1,68 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+)
+
+// compressionResetCmd represents the compression reset command
+var compressionResetCmd = &cobra.Command{
+	Use:   "reset (<metric> | --match <pattern> | --all)",
+	Short: "Resets the compression setting for a specific metric, or all matching metrics, back to the default",
+	Args:  metricSelectionArgs(0),
+	RunE:  compressionReset,
+}
+
+func init() {
+	compressionCmd.AddCommand(compressionResetCmd)
+	addMetricSelectionFlags(compressionResetCmd)
+}
+
+func compressionReset(cmd *cobra.Command, args []string) error {
+	var err error
+
+	var selection *metricSelection
+	selection, _, err = getMetricSelection(cmd, args)
+	if err != nil {
+		return fmt.Errorf("could not reset compression setting: %w", err)
+	}
+
+	ctx := cmd.Context()
+	client, err := getKubeClient()
+	if err != nil {
+		return fmt.Errorf("could not reset compression setting for %v: %w", selection, err)
+	}
+
+	pool, err := OpenConnectionToDB(ctx, client, namespace, name, user, dbname)
+	if err != nil {
+		return fmt.Errorf("could not reset compression setting for %v: %w", selection, err)
+	}
+	defer pool.Close()
+
+	metrics, err := selection.expand(ctx, pool)
+	if err != nil {
+		return fmt.Errorf("could not reset compression setting for %v: %w", selection, err)
+	}
+
+	proceed, err := selection.confirm(os.Stdin, os.Stdout, metrics, "reset the compression setting to the default")
+	if err != nil {
+		return fmt.Errorf("could not reset compression setting for %v: %w", selection, err)
+	}
+	if !proceed {
+		fmt.Println("Aborted")
+		return nil
+	}
+
+	fmt.Printf("Resetting compression setting for %v back to default\n", selection)
+	err = selection.apply(os.Stdout, metrics, func(metric string) error {
+		_, err := pool.Exec(ctx, "SELECT prom_api.reset_metric_compression_setting($1)", metric)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("could not reset compression setting for %v: %w", selection, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,92 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+
+	"github.com/spf13/cobra"
+)
+
+// compressionSetCmd represents the compression set command
+var compressionSetCmd = &cobra.Command{
+	Use:   "set (<metric> | --match <pattern> | --all) <true|false>",
+	Short: "Sets whether compression is enabled for a specific metric, or all matching metrics",
+	Args:  metricSelectionArgs(1),
+	RunE:  compressionSet,
+}
+
+func init() {
+	compressionCmd.AddCommand(compressionSetCmd)
+	addMetricSelectionFlags(compressionSetCmd)
+}
+
+func compressionSet(cmd *cobra.Command, args []string) error {
+	var err error
+
+	var selection *metricSelection
+	selection, args, err = getMetricSelection(cmd, args)
+	if err != nil {
+		return fmt.Errorf("could not set compression setting: %w", err)
+	}
+
+	var enabled bool
+	enabled, err = parseCompressionSetting(args[0])
+	if err != nil {
+		return fmt.Errorf("could not set compression setting for %v: %w", selection, err)
+	}
+
+	ctx := cmd.Context()
+	client, err := getKubeClient()
+	if err != nil {
+		return fmt.Errorf("could not set compression setting for %v: %w", selection, err)
+	}
+
+	pool, err := OpenConnectionToDB(ctx, client, namespace, name, user, dbname)
+	if err != nil {
+		return fmt.Errorf("could not set compression setting for %v: %w", selection, err)
+	}
+	defer pool.Close()
+
+	metrics, err := selection.expand(ctx, pool)
+	if err != nil {
+		return fmt.Errorf("could not set compression setting for %v: %w", selection, err)
+	}
+
+	proceed, err := selection.confirm(os.Stdin, os.Stdout, metrics, "set compression to "+enabledString(enabled))
+	if err != nil {
+		return fmt.Errorf("could not set compression setting for %v: %w", selection, err)
+	}
+	if !proceed {
+		fmt.Println("Aborted")
+		return nil
+	}
+
+	fmt.Printf("Setting compression setting for %v to %v\n", selection, enabledString(enabled))
+	err = selection.apply(os.Stdout, metrics, func(metric string) error {
+		_, err := pool.Exec(ctx, "SELECT prom_api.set_metric_compression_setting($1, $2)", metric, enabled)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("could not set compression setting for %v: %w", selection, err)
+	}
+
+	return nil
+}
+
+// parseCompressionSetting accepts true and false, and also enabled and
+// disabled as printed by the get commands.
+func parseCompressionSetting(setting string) (bool, error) {
+	switch setting {
+	case "enabled":
+		return true, nil
+	case "disabled":
+		return false, nil
+	}
+
+	enabled, err := strconv.ParseBool(setting)
+	if err != nil {
+		return false, fmt.Errorf("invalid compression setting %v, expected true or false", setting)
+	}
+	return enabled, nil
+}
@@ -0,0 +1,49 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+)
+
+// compressionSetDefaultCmd represents the compression set-default command
+var compressionSetDefaultCmd = &cobra.Command{
+	Use:   "set-default <true|false>",
+	Short: "Sets whether compression is enabled by default",
+	Args:  cobra.ExactArgs(1),
+	RunE:  compressionSetDefault,
+}
+
+func init() {
+	compressionCmd.AddCommand(compressionSetDefaultCmd)
+}
+
+func compressionSetDefault(cmd *cobra.Command, args []string) error {
+	var err error
+
+	var enabled bool
+	enabled, err = parseCompressionSetting(args[0])
+	if err != nil {
+		return fmt.Errorf("could not set default compression setting: %w", err)
+	}
+
+	ctx := cmd.Context()
+	client, err := getKubeClient()
+	if err != nil {
+		return fmt.Errorf("could not set default compression setting: %w", err)
+	}
+
+	pool, err := OpenConnectionToDB(ctx, client, namespace, name, user, dbname)
+	if err != nil {
+		return fmt.Errorf("could not set default compression setting: %w", err)
+	}
+	defer pool.Close()
+
+	fmt.Printf("Setting default compression setting to %v\n", enabledString(enabled))
+	_, err = pool.Exec(ctx, "SELECT prom_api.set_default_compression_setting($1)", enabled)
+	if err != nil {
+		return fmt.Errorf("could not set default compression setting: %w", err)
+	}
+
+	return nil
+}
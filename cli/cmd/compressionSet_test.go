@@ -0,0 +1,17 @@
+package cmd
+
+import (
+	"testing"
+)
+
+func TestParseCompressionSetting(t *testing.T) {
+	for setting, expected := range map[string]bool{"true": true, "false": false, "enabled": true, "disabled": false, "1": true} {
+		enabled, err := parseCompressionSetting(setting)
+		if err != nil || enabled != expected {
+			t.Fatalf("expected %v to be %v, got %v %v", setting, expected, enabled, err)
+		}
+	}
+	if _, err := parseCompressionSetting("maybe"); err == nil {
+		t.Fatal("expected an invalid setting to be refused")
+	}
+}
@@ -0,0 +1,150 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+)
+
+// compressionStatsCmd represents the compression stats command
+var compressionStatsCmd = &cobra.Command{
+	Use:   "stats",
+	Short: "Shows the compressed chunks, bytes before and after compression and compression ratio of each metric",
+	Args:  cobra.ExactArgs(0),
+	RunE:  compressionStats,
+}
+
+func init() {
+	compressionCmd.AddCommand(compressionStatsCmd)
+	compressionStatsCmd.Flags().StringP("match", "m", "", "Only show metrics matching a glob, or a regular expression wrapped in slashes")
+	compressionStatsCmd.Flags().StringP("output", "o", "table", "Output format, one of table or json")
+}
+
+// compressionStat sums up the compression stats of the chunks of a metric.
+// The sizes and ratio only cover compressed chunks.
+type compressionStat struct {
+	Metric           string  `json:"metric"`
+	Chunks           int64   `json:"chunks"`
+	CompressedChunks int64   `json:"compressedChunks"`
+	BytesBefore      int64   `json:"bytesBefore"`
+	BytesAfter       int64   `json:"bytesAfter"`
+	Ratio            float64 `json:"ratio"`
+}
+
+func compressionStats(cmd *cobra.Command, args []string) error {
+	var err error
+
+	var match, output string
+	match, err = cmd.Flags().GetString("match")
+	if err != nil {
+		return fmt.Errorf("could not get compression stats: %w", err)
+	}
+	output, err = cmd.Flags().GetString("output")
+	if err != nil {
+		return fmt.Errorf("could not get compression stats: %w", err)
+	}
+	if output != "table" && output != "json" {
+		return fmt.Errorf("could not get compression stats: unknown output format %v", output)
+	}
+
+	matches := func(string) bool { return true }
+	if match != "" {
+		matches, err = metricNameMatcher(match)
+		if err != nil {
+			return fmt.Errorf("could not get compression stats: %w", err)
+		}
+	}
+
+	ctx := cmd.Context()
+	client, err := getKubeClient()
+	if err != nil {
+		return fmt.Errorf("could not get compression stats: %w", err)
+	}
+
+	pool, err := OpenConnectionToDB(ctx, client, namespace, name, user, dbname)
+	if err != nil {
+		return fmt.Errorf("could not get compression stats: %w", err)
+	}
+	defer pool.Close()
+
+	stats, err := getCompressionStats(ctx, pool, matches)
+	if err != nil {
+		return fmt.Errorf("could not get compression stats: %w", err)
+	}
+
+	if output == "json" {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		err = encoder.Encode(stats)
+	} else {
+		err = printCompressionStats(os.Stdout, stats)
+	}
+	if err != nil {
+		return fmt.Errorf("could not get compression stats: %w", err)
+	}
+
+	return nil
+}
+
+// getCompressionStats sums up the TimescaleDB chunk compression stats of the
+// hypertables of the metrics matching a filter.
+func getCompressionStats(ctx context.Context, pool *DBPool, matches func(string) bool) ([]compressionStat, error) {
+	rows, err := pool.Query(ctx,
+		`SELECT m.metric_name,
+	    count(c.id),
+	    count(s.chunk_id),
+	    COALESCE(sum(s.uncompressed_heap_size + s.uncompressed_toast_size + s.uncompressed_index_size), 0)::bigint,
+	    COALESCE(sum(s.compressed_heap_size + s.compressed_toast_size + s.compressed_index_size), 0)::bigint
+	 FROM _prom_catalog.metric m
+	 INNER JOIN _timescaledb_catalog.hypertable h
+	    ON (h.schema_name = 'prom_data' AND h.table_name = m.table_name)
+	 LEFT JOIN _timescaledb_catalog.chunk c
+	    ON (c.hypertable_id = h.id)
+	 LEFT JOIN _timescaledb_catalog.compression_chunk_size s
+	    ON (s.chunk_id = c.id)
+	 GROUP BY m.metric_name
+	 ORDER BY m.metric_name`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var stats []compressionStat
+	for rows.Next() {
+		var stat compressionStat
+		err = rows.Scan(&stat.Metric, &stat.Chunks, &stat.CompressedChunks, &stat.BytesBefore, &stat.BytesAfter)
+		if err != nil {
+			return nil, err
+		}
+		if !matches(stat.Metric) {
+			continue
+		}
+		if stat.BytesAfter != 0 {
+			stat.Ratio = float64(stat.BytesBefore) / float64(stat.BytesAfter)
+		}
+		stats = append(stats, stat)
+	}
+
+	return stats, rows.Err()
+}
+
+func printCompressionStats(w io.Writer, stats []compressionStat) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+
+	fmt.Fprintln(tw, "METRIC\tCOMPRESSED CHUNKS\tBEFORE\tAFTER\tRATIO")
+	for _, stat := range stats {
+		before, after, ratio := "-", "-", "-"
+		if stat.CompressedChunks != 0 {
+			before, after = formatBytes(stat.BytesBefore), formatBytes(stat.BytesAfter)
+			ratio = fmt.Sprintf("%.1fx", stat.Ratio)
+		}
+		fmt.Fprintf(tw, "%v\t%d/%d\t%v\t%v\t%v\n", stat.Metric, stat.CompressedChunks, stat.Chunks, before, after, ratio)
+	}
+
+	return tw.Flush()
+}
@@ -0,0 +1,29 @@
+package cmd
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestPrintCompressionStats(t *testing.T) {
+	var out bytes.Buffer
+	err := printCompressionStats(&out, []compressionStat{
+		{Metric: "node_load1", Chunks: 4, CompressedChunks: 3, BytesBefore: 10 * 1024 * 1024, BytesAfter: 1024 * 1024, Ratio: 10},
+		{Metric: "up", Chunks: 2},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("expected a header and two metrics, got\n%v", out.String())
+	}
+	if strings.Join(strings.Fields(lines[1]), " ") != "node_load1 3/4 10.0 MiB 1.0 MiB 10.0x" {
+		t.Fatalf("unexpected stats %q", lines[1])
+	}
+	if strings.Join(strings.Fields(lines[2]), " ") != "up 0/2 - - -" {
+		t.Fatalf("unexpected stats %q", lines[2])
+	}
+}
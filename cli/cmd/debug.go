@@ -0,0 +1,15 @@
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+)
+
+// debugCmd represents the debug command
+var debugCmd = &cobra.Command{
+	Use:   "debug",
+	Short: "Subcommand for debugging operations",
+}
+
+func init() {
+	rootCmd.AddCommand(debugCmd)
+}
@@ -0,0 +1,584 @@
+package cmd
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"helm.sh/helm/v3/pkg/releaseutil"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"sigs.k8s.io/yaml"
+)
+
+// debugBundleCmd represents the debug bundle command
+var debugBundleCmd = &cobra.Command{
+	Use:   "bundle",
+	Short: "Collects diagnostics of The Observability Stack into a tar.gz archive",
+	Long: `Collects pod descriptions, events, recent logs, the Helm values and manifest,
+the Prometheus configuration, the Promscale connection settings and database
+statistics into a tar.gz archive. Secret data, password and token fields,
+environment variables named like them and passwords in connection strings are
+redacted, as are the longer values of the TimescaleDB passwords, Grafana and
+Grafana datasources secrets wherever they appear.`,
+	Args: cobra.ExactArgs(0),
+	RunE: debugBundle,
+}
+
+func init() {
+	debugCmd.AddCommand(debugBundleCmd)
+	debugBundleCmd.Flags().StringP("output", "o", "", "Path of the archive to write (default \"tobs-debug-<name>-<timestamp>.tar.gz\")")
+	debugBundleCmd.Flags().Int64("tail", 1000, "Number of recent log lines to collect per container")
+	debugBundleCmd.Flags().StringP("user", "U", "postgres", "database user name")
+	debugBundleCmd.Flags().StringP("dbname", "d", "postgres", "database name to connect to")
+}
+
+const REDACTED = "<redacted>"
+
+// minRedactLength is the length below which secret values are not replaced
+// wherever they appear, as short values like "admin" or "postgres" would wipe
+// out unrelated names and log lines. Such values are still redacted where the
+// structure marks them as secret.
+const minRedactLength = 10
+
+// sensitivePatterns match secrets in text like logs and configuration files:
+// passwords in connection strings and settings like password=..., token: ...
+// or PGPASSWORD=.... What lies between the first and second group is
+// redacted.
+var sensitivePatterns = []*regexp.Regexp{
+	regexp.MustCompile(`(?i)(postgres(?:ql)?://[^:/@\s]+:)[^@\s]+(@)`),
+	regexp.MustCompile(`(?i)((?:\b|_|pg)(?:password|passwd|pwd|token|secret)["']?\s*[:=]\s*)(?:"[^"]*"|'[^']*'|[^\s,;&"']+)()`),
+}
+
+// debugBundleQueries are run against the database; each result is stored in
+// the bundle as-is.
+var debugBundleQueries = map[string]string{
+	"extensions":  "SELECT extname, extversion FROM pg_extension ORDER BY extname",
+	"version":     "SELECT version()",
+	"size":        "SELECT pg_size_pretty(pg_database_size(current_database()))",
+	"metrics":     "SELECT count(*) FROM _prom_catalog.metric",
+	"series":      "SELECT count(*) FROM _prom_catalog.series",
+	"hypertables": "SELECT count(*) FROM _timescaledb_catalog.hypertable",
+	"chunks":      "SELECT count(*) FROM _timescaledb_catalog.chunk",
+}
+
+// bundleWriter writes redacted files into a gzipped tarball. Collection
+// errors are gathered into errors.txt instead of failing the whole bundle.
+type bundleWriter struct {
+	tar      *tar.Writer
+	redactor *strings.Replacer
+	modTime  time.Time
+	errors   []string
+}
+
+func (b *bundleWriter) add(path string, data []byte) error {
+	data = []byte(redactText(string(data)))
+	if b.redactor != nil {
+		data = []byte(b.redactor.Replace(string(data)))
+	}
+
+	err := b.tar.WriteHeader(&tar.Header{
+		Name:    path,
+		Mode:    0644,
+		Size:    int64(len(data)),
+		ModTime: b.modTime,
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = b.tar.Write(data)
+	return err
+}
+
+// addYAML adds an object with the fields its structure marks as secret
+// redacted, see redactDoc.
+func (b *bundleWriter) addYAML(path string, obj interface{}) error {
+	data, err := yaml.Marshal(obj)
+	if err != nil {
+		return err
+	}
+
+	var doc interface{}
+	err = yaml.Unmarshal(data, &doc)
+	if err != nil {
+		return err
+	}
+	data, err = yaml.Marshal(redactDoc(doc, false))
+	if err != nil {
+		return err
+	}
+
+	return b.add(path, data)
+}
+
+// addManifest adds a multi-document manifest, redacting each document like
+// addYAML.
+func (b *bundleWriter) addManifest(path string, manifest string) error {
+	docs := releaseutil.SplitManifests(manifest)
+	keys := make([]string, 0, len(docs))
+	for key := range docs {
+		keys = append(keys, key)
+	}
+	sort.Sort(releaseutil.BySplitManifestsOrder(keys))
+
+	var redacted strings.Builder
+	for _, key := range keys {
+		var doc interface{}
+		err := yaml.Unmarshal([]byte(docs[key]), &doc)
+		if err != nil {
+			return err
+		}
+		if doc == nil {
+			continue
+		}
+		data, err := yaml.Marshal(redactDoc(doc, false))
+		if err != nil {
+			return err
+		}
+		redacted.WriteString("---\n")
+		redacted.Write(data)
+	}
+
+	return b.add(path, []byte(redacted.String()))
+}
+
+func (b *bundleWriter) fail(what string, err error) {
+	b.errors = append(b.errors, fmt.Sprintf("could not collect %v: %v", what, err))
+}
+
+func debugBundle(cmd *cobra.Command, args []string) error {
+	var err error
+
+	var output string
+	output, err = cmd.Flags().GetString("output")
+	if err != nil {
+		return fmt.Errorf("could not create debug bundle: %w", err)
+	}
+
+	var tail int64
+	tail, err = cmd.Flags().GetInt64("tail")
+	if err != nil {
+		return fmt.Errorf("could not create debug bundle: %w", err)
+	}
+
+	var user string
+	user, err = cmd.Flags().GetString("user")
+	if err != nil {
+		return fmt.Errorf("could not create debug bundle: %w", err)
+	}
+
+	var dbname string
+	dbname, err = cmd.Flags().GetString("dbname")
+	if err != nil {
+		return fmt.Errorf("could not create debug bundle: %w", err)
+	}
+
+	now := time.Now()
+	if output == "" {
+		output = fmt.Sprintf("tobs-debug-%v-%v.tar.gz", name, now.Format("20060102-150405"))
+	}
+
+	ctx := cmd.Context()
+	client, err := getKubeClient()
+	if err != nil {
+		return fmt.Errorf("could not create debug bundle: %w", err)
+	}
+
+	redactor, err := newSecretRedactor(ctx, client)
+	if err != nil {
+		return fmt.Errorf("could not create debug bundle: %w", err)
+	}
+
+	file, err := os.Create(output)
+	if err != nil {
+		return fmt.Errorf("could not create debug bundle: %w", err)
+	}
+	defer file.Close()
+
+	gz := gzip.NewWriter(file)
+	bundle := &bundleWriter{tar: tar.NewWriter(gz), redactor: redactor, modTime: now}
+
+	err = collectDebugBundle(ctx, client, bundle, tail, user, dbname)
+	if err != nil {
+		return fmt.Errorf("could not create debug bundle: %w", err)
+	}
+
+	if len(bundle.errors) != 0 {
+		err = bundle.add("errors.txt", []byte(strings.Join(bundle.errors, "\n")+"\n"))
+		if err != nil {
+			return fmt.Errorf("could not create debug bundle: %w", err)
+		}
+	}
+
+	err = bundle.tar.Close()
+	if err != nil {
+		return fmt.Errorf("could not create debug bundle: %w", err)
+	}
+	err = gz.Close()
+	if err != nil {
+		return fmt.Errorf("could not create debug bundle: %w", err)
+	}
+
+	fmt.Printf("Wrote debug bundle to %v\n", output)
+	for _, e := range bundle.errors {
+		fmt.Fprintln(os.Stderr, "Warning: "+e)
+	}
+
+	return nil
+}
+
+// collectDebugBundle adds every artifact to the bundle. It only returns an
+// error if writing the bundle fails.
+func collectDebugBundle(ctx context.Context, client *KubeClient, bundle *bundleWriter, tail int64, user, dbname string) error {
+	var err error
+
+	components, err := stackComponents(name, namespace)
+	if err != nil {
+		return err
+	}
+
+	for _, component := range components {
+		pods, err := client.KubeGetPods(ctx, namespace, component.Selector)
+		if err != nil {
+			bundle.fail(component.Name+" pods", err)
+			continue
+		}
+
+		for _, pod := range pods {
+			err = bundle.addYAML("pods/"+component.ID+"/"+pod.Name+".yaml", pod)
+			if err != nil {
+				return err
+			}
+
+			for _, container := range pod.Spec.Containers {
+				err = addContainerLogs(ctx, client, bundle, "logs/"+component.ID+"/"+pod.Name+"/", pod, container.Name, tail)
+				if err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	events, err := client.KubeGetEvents(ctx, namespace)
+	if err != nil {
+		bundle.fail("events", err)
+	} else {
+		sort.Slice(events, func(i, j int) bool {
+			return events[i].LastTimestamp.Before(&events[j].LastTimestamp)
+		})
+		err = bundle.addYAML("events.yaml", events)
+		if err != nil {
+			return err
+		}
+	}
+
+	helm, err := getHelmClient()
+	if err != nil {
+		bundle.fail("Helm release", err)
+	} else {
+		values, err := helm.HelmGetValues(ctx, name, true)
+		if err != nil {
+			bundle.fail("Helm values", err)
+		} else {
+			var doc interface{}
+			err = yaml.Unmarshal(values, &doc)
+			if err != nil {
+				bundle.fail("Helm values", err)
+			} else {
+				err = bundle.addYAML("helm/values.yaml", doc)
+				if err != nil {
+					return err
+				}
+			}
+		}
+
+		manifest, err := helm.HelmGetManifest(ctx, name)
+		if err != nil {
+			bundle.fail("Helm manifest", err)
+		} else {
+			err = bundle.addManifest("helm/manifest.yaml", manifest)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	prometheus, err := getStackComponent(name, namespace, "prometheus")
+	if err != nil {
+		return err
+	}
+
+	configMap, err := client.KubeGetConfigMap(ctx, namespace, prometheus.ConfigMaps["config"])
+	if err != nil {
+		bundle.fail("Prometheus config", err)
+	} else {
+		for key, value := range configMap.Data {
+			err = bundle.add("prometheus/"+key, []byte(value))
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	envs, err := getPromscaleEnv(ctx, client, namespace, name)
+	if err != nil {
+		bundle.fail("Promscale env", err)
+	} else {
+		var lines []string
+		for _, env := range envs {
+			value := env.Value
+			if env.ValueFrom != nil {
+				value = REDACTED
+			}
+			lines = append(lines, env.Name+"="+value)
+		}
+		err = bundle.add("promscale/env.txt", []byte(strings.Join(lines, "\n")+"\n"))
+		if err != nil {
+			return err
+		}
+	}
+
+	pool, err := OpenConnectionToDB(ctx, client, namespace, name, user, dbname)
+	if err != nil {
+		bundle.fail("database statistics", err)
+		return nil
+	}
+	defer pool.Close()
+
+	var stats strings.Builder
+	var keys []string
+	for key := range debugBundleQueries {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		rows, err := pool.Query(ctx, debugBundleQueries[key])
+		if err != nil {
+			bundle.fail("database "+key, err)
+			continue
+		}
+
+		fmt.Fprintf(&stats, "-- %v\n", key)
+		for rows.Next() {
+			values, err := rows.Values()
+			if err != nil {
+				bundle.fail("database "+key, err)
+				break
+			}
+			var columns []string
+			for _, value := range values {
+				columns = append(columns, fmt.Sprint(value))
+			}
+			fmt.Fprintln(&stats, strings.Join(columns, "\t"))
+		}
+		rows.Close()
+		if rows.Err() != nil {
+			bundle.fail("database "+key, rows.Err())
+		}
+	}
+
+	return bundle.add("database/stats.txt", []byte(stats.String()))
+}
+
+// addContainerLogs adds the recent logs of a container and, if it has
+// restarted, of its previous instance.
+func addContainerLogs(ctx context.Context, client *KubeClient, bundle *bundleWriter, dir string, pod corev1.Pod, container string, tail int64) error {
+	previous := []bool{false}
+	for _, status := range pod.Status.ContainerStatuses {
+		if status.Name == container && status.RestartCount > 0 {
+			previous = append(previous, true)
+		}
+	}
+
+	for _, p := range previous {
+		path := dir + container + ".log"
+		if p {
+			path = dir + container + ".previous.log"
+		}
+
+		stream, err := client.KubeGetPodLogs(ctx, namespace, pod.Name, &corev1.PodLogOptions{Container: container, TailLines: &tail, Previous: p})
+		if err != nil {
+			bundle.fail("logs of "+path, err)
+			continue
+		}
+		logs, err := ioutil.ReadAll(stream)
+		stream.Close()
+		if err != nil {
+			bundle.fail("logs of "+path, err)
+			continue
+		}
+
+		err = bundle.add(path, logs)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// newSecretRedactor builds a replacer for the values of the secrets of the
+// stack components, i.e. the TimescaleDB passwords, Grafana and Grafana
+// datasources secrets, that are long enough to be replaced wherever they
+// appear. A secret that can not be read is an error, as the bundle could
+// otherwise leak its values.
+func newSecretRedactor(ctx context.Context, client *KubeClient) (*strings.Replacer, error) {
+	var values []string
+
+	components, err := stackComponents(name, namespace)
+	if err != nil {
+		return nil, err
+	}
+
+	var secretNames []string
+	for _, component := range components {
+		for _, secretName := range component.Secrets {
+			secretNames = append(secretNames, secretName)
+		}
+	}
+
+	for _, secretName := range secretNames {
+		secret, err := client.KubeGetSecret(ctx, namespace, secretName)
+		if err != nil {
+			if apierrors.IsNotFound(err) {
+				continue
+			}
+			return nil, fmt.Errorf("could not read secret %v to redact it: %w", secretName, err)
+		}
+
+		for _, data := range secret.Data {
+			values = append(values, secretValues(data)...)
+		}
+	}
+
+	return newRedactor(values), nil
+}
+
+// secretValues returns the value of a secret key, as well as the sensitive
+// fields of it if it is a YAML document such as a Grafana datasources file.
+func secretValues(data []byte) []string {
+	values := []string{string(data)}
+
+	var doc interface{}
+	if yaml.Unmarshal(data, &doc) == nil {
+		values = append(values, sensitiveFields(doc, false)...)
+	}
+
+	return values
+}
+
+func sensitiveFields(doc interface{}, sensitive bool) []string {
+	var values []string
+
+	switch v := doc.(type) {
+	case map[string]interface{}:
+		for key, value := range v {
+			values = append(values, sensitiveFields(value, sensitive || sensitiveKey(key))...)
+		}
+	case []interface{}:
+		for _, value := range v {
+			values = append(values, sensitiveFields(value, sensitive)...)
+		}
+	case string:
+		if sensitive {
+			values = append(values, v)
+		}
+	}
+
+	return values
+}
+
+// sensitiveKey reports whether a field or environment variable named key
+// holds a secret, as opposed to e.g. the name of a secret.
+func sensitiveKey(key string) bool {
+	lower := strings.ToLower(key)
+	for _, suffix := range []string{"name", "ref", "template", "file", "path"} {
+		if strings.HasSuffix(lower, suffix) {
+			return false
+		}
+	}
+	return lower == "pass" || lower == "secret" || lower == "securejsondata" ||
+		strings.Contains(lower, "password") || strings.Contains(lower, "passwd") || strings.Contains(lower, "token")
+}
+
+// redactDoc redacts the fields of a YAML document that its structure marks as
+// secret: the data of Secrets, fields named like passwords or tokens, and
+// the value of environment variables named like them.
+func redactDoc(doc interface{}, sensitive bool) interface{} {
+	switch v := doc.(type) {
+	case map[string]interface{}:
+		if v["kind"] == "Secret" {
+			for _, field := range []string{"data", "stringData"} {
+				if data, ok := v[field].(map[string]interface{}); ok {
+					for key := range data {
+						data[key] = REDACTED
+					}
+				}
+			}
+		}
+		if envName, ok := v["name"].(string); ok && sensitiveKey(envName) {
+			if _, ok := v["value"].(string); ok {
+				v["value"] = REDACTED
+			}
+		}
+		for key, value := range v {
+			v[key] = redactDoc(value, sensitive || sensitiveKey(key))
+		}
+	case []interface{}:
+		for i, value := range v {
+			v[i] = redactDoc(value, sensitive)
+		}
+	case string:
+		if sensitive && v != "" {
+			return REDACTED
+		}
+	}
+
+	return doc
+}
+
+// redactText redacts passwords in connection strings and the values of
+// password-like settings in free text such as logs.
+func redactText(text string) string {
+	for _, pattern := range sensitivePatterns {
+		text = pattern.ReplaceAllString(text, "${1}"+REDACTED+"${2}")
+	}
+	return text
+}
+
+// newRedactor replaces the values of at least minRedactLength and their
+// base64 encodings wherever they appear. Longer values are replaced first so
+// that a value containing another one is redacted as a whole.
+func newRedactor(values []string) *strings.Replacer {
+	var all []string
+	for _, value := range values {
+		for _, v := range []string{value, strings.TrimSpace(value)} {
+			if len(v) < minRedactLength {
+				continue
+			}
+			all = append(all, v, base64.StdEncoding.EncodeToString([]byte(v)))
+		}
+	}
+
+	sort.Slice(all, func(i, j int) bool {
+		return len(all[i]) > len(all[j])
+	})
+
+	var oldnew []string
+	for _, value := range all {
+		oldnew = append(oldnew, value, REDACTED)
+	}
+
+	return strings.NewReplacer(oldnew...)
+}
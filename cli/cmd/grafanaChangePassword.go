@@ -23,7 +23,18 @@ func grafanaChangePassword(cmd *cobra.Command, args []string) error {
 
 	password := args[0]
 
-	secret, err := KubeGetSecret(namespace, name+"-grafana")
+	ctx := cmd.Context()
+	client, err := getKubeClient()
+	if err != nil {
+		return fmt.Errorf("could not change Grafana password: %w", err)
+	}
+
+	component, err := getStackComponent(name, namespace, "grafana")
+	if err != nil {
+		return fmt.Errorf("could not change Grafana password: %w", err)
+	}
+
+	secret, err := client.KubeGetSecret(ctx, namespace, component.Secrets["admin"])
 	if err != nil {
 		return fmt.Errorf("could not change Grafana password: %w", err)
 	}
@@ -31,21 +42,21 @@ func grafanaChangePassword(cmd *cobra.Command, args []string) error {
 	oldpassword := secret.Data["admin-password"]
 
 	secret.Data["admin-password"] = []byte(password)
-	err = KubeUpdateSecret(namespace, secret)
+	err = client.KubeUpdateSecret(ctx, namespace, secret)
 	if err != nil {
 		return fmt.Errorf("could not change Grafana password: %w", err)
 	}
 
 	fmt.Println("Changing password...")
-	grafanaPod, err := KubeGetPodName(namespace, map[string]string{"app.kubernetes.io/instance": name, "app.kubernetes.io/name": "grafana"})
+	grafanaPod, err := client.KubeGetPodName(ctx, namespace, component.Primary)
 	if err != nil {
 		return fmt.Errorf("could not change Grafana password: %w", err)
 	}
 
-	err = KubeExecCmd(namespace, grafanaPod, "grafana", "grafana-cli admin reset-admin-password "+password, nil, false)
+	err = client.KubeExecCmd(ctx, namespace, grafanaPod, component.Container, "grafana-cli admin reset-admin-password "+password, nil, false)
 	if err != nil {
 		secret.Data["admin-password"] = oldpassword
-		_ = KubeUpdateSecret(namespace, secret)
+		_ = client.KubeUpdateSecret(ctx, namespace, secret)
 		return fmt.Errorf("could not change Grafana password: %w", err)
 	}
 
@@ -21,7 +21,18 @@ func init() {
 func grafanaGetPassword(cmd *cobra.Command, args []string) error {
 	var err error
 
-	secret, err := KubeGetSecret(namespace, name+"-grafana")
+	ctx := cmd.Context()
+	client, err := getKubeClient()
+	if err != nil {
+		return fmt.Errorf("could not get Grafana password: %w", err)
+	}
+
+	component, err := getStackComponent(name, namespace, "grafana")
+	if err != nil {
+		return fmt.Errorf("could not get Grafana password: %w", err)
+	}
+
+	secret, err := client.KubeGetSecret(ctx, namespace, component.Secrets["admin"])
 	if err != nil {
 		return fmt.Errorf("could not get Grafana password: %w", err)
 	}
@@ -7,7 +7,6 @@ import (
 )
 
 const LISTEN_PORT_GRAFANA = 8080
-const FORWARD_PORT_GRAFANA = 3000
 
 // grafanaPortForwardCmd represents the grafana port-forward command
 var grafanaPortForwardCmd = &cobra.Command{
@@ -31,17 +30,26 @@ func grafanaPortForward(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("could not port-forward Grafana: %w", err)
 	}
 
-	serviceName, err := KubeGetServiceName(namespace, map[string]string{"app.kubernetes.io/instance": name, "app.kubernetes.io/name": "grafana"})
+	ctx := cmd.Context()
+	client, err := getKubeClient()
 	if err != nil {
 		return fmt.Errorf("could not port-forward Grafana: %w", err)
 	}
 
-	_, err = KubePortForwardService(namespace, serviceName, port, FORWARD_PORT_GRAFANA)
+	component, err := getStackComponent(name, namespace, "grafana")
 	if err != nil {
 		return fmt.Errorf("could not port-forward Grafana: %w", err)
 	}
 
-	select {}
+	forward, err := newComponentPortForward(ctx, client, namespace, component, port)
+	if err != nil {
+		return err
+	}
+
+	err = runPortForwards(ctx, forward)
+	if err != nil {
+		return fmt.Errorf("could not port-forward Grafana: %w", err)
+	}
 
 	return nil
 }
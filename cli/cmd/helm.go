@@ -2,8 +2,14 @@ package cmd
 
 import (
 	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"helm.sh/helm/v3/pkg/cli/values"
 )
 
+const DEVEL = false
+const REPO_NAME = "timescale"
+const REPO_LOCATION = "https://charts.timescale.com"
+
 // helmCmd represents the helm command
 var helmCmd = &cobra.Command{
 	Use:   "helm",
@@ -13,3 +19,98 @@ var helmCmd = &cobra.Command{
 func init() {
 	rootCmd.AddCommand(helmCmd)
 }
+
+// addHelmValuesFlags adds the flags the Helm CLI takes to override chart values.
+func addHelmValuesFlags(cmd *cobra.Command) {
+	cmd.Flags().StringArrayP("filename", "f", nil, "YAML configuration file to load, can be repeated")
+	cmd.Flags().StringArray("set", nil, "Set values on the command line, e.g. key1=val1,key2=val2")
+	cmd.Flags().StringArray("set-string", nil, "Set string values on the command line, e.g. key1=val1,key2=val2")
+	cmd.Flags().StringArray("set-file", nil, "Set values from files on the command line, e.g. key1=path1,key2=path2")
+}
+
+func getHelmValuesOptions(cmd *cobra.Command) (*values.Options, error) {
+	var err error
+
+	options := &values.Options{}
+	options.ValueFiles, err = cmd.Flags().GetStringArray("filename")
+	if err != nil {
+		return nil, err
+	}
+	options.Values, err = cmd.Flags().GetStringArray("set")
+	if err != nil {
+		return nil, err
+	}
+	options.StringValues, err = cmd.Flags().GetStringArray("set-string")
+	if err != nil {
+		return nil, err
+	}
+	options.FileValues, err = cmd.Flags().GetStringArray("set-file")
+	if err != nil {
+		return nil, err
+	}
+
+	return options, nil
+}
+
+// addHelmChartFlags adds the flags selecting the chart and the repository it
+// is downloaded from.
+func addHelmChartFlags(cmd *cobra.Command) {
+	cmd.Flags().StringP("chart-reference", "c", REPO_NAME+"/tobs", "Helm chart reference: <repo>/<chart>, an oci:// reference, a URL, or a local chart archive or directory")
+	cmd.Flags().StringP("version", "", "", "Chart version, the latest if empty")
+	cmd.Flags().BoolP("devel", "", DEVEL, "Use development versions too, equivalent to version '>0.0.0-0'")
+	cmd.Flags().StringP("repo-name", "", REPO_NAME, "Name of the chart repository configured by the --repo-* flags")
+	cmd.Flags().StringP("repo-url", "", REPO_LOCATION, "URL of the chart repository")
+	cmd.Flags().StringP("repo-username", "", "", "Username for the chart repository or OCI registry")
+	cmd.Flags().StringP("repo-password", "", "", "Password for the chart repository or OCI registry")
+	cmd.Flags().StringP("repo-ca-file", "", "", "CA bundle to verify the chart repository or OCI registry with")
+	cmd.Flags().BoolP("insecure-skip-tls-verify", "", false, "Skip the TLS certificate check of the chart repository or OCI registry")
+}
+
+// getChartSource reads the chart flags. Flags take precedence over the
+// settings under chart in the config file, which take precedence over the
+// flag defaults.
+func getChartSource(cmd *cobra.Command) (*ChartSource, error) {
+	var err error
+
+	source := &ChartSource{}
+	for _, setting := range []struct {
+		flag  string
+		key   string
+		value *string
+	}{
+		{"chart-reference", "chart.reference", &source.Ref},
+		{"version", "chart.version", &source.Version},
+		{"repo-name", "chart.repository.name", &source.RepoName},
+		{"repo-url", "chart.repository.url", &source.RepoURL},
+		{"repo-username", "chart.repository.username", &source.Username},
+		{"repo-password", "chart.repository.password", &source.Password},
+		{"repo-ca-file", "chart.repository.caFile", &source.CAFile},
+	} {
+		*setting.value, err = cmd.Flags().GetString(setting.flag)
+		if err != nil {
+			return nil, err
+		}
+		if !cmd.Flags().Changed(setting.flag) && viper.IsSet(setting.key) {
+			*setting.value = viper.GetString(setting.key)
+		}
+	}
+
+	for _, setting := range []struct {
+		flag  string
+		key   string
+		value *bool
+	}{
+		{"devel", "chart.devel", &source.Devel},
+		{"insecure-skip-tls-verify", "chart.repository.insecureSkipTLSVerify", &source.InsecureSkipTLSVerify},
+	} {
+		*setting.value, err = cmd.Flags().GetBool(setting.flag)
+		if err != nil {
+			return nil, err
+		}
+		if !cmd.Flags().Changed(setting.flag) && viper.IsSet(setting.key) {
+			*setting.value = viper.GetBool(setting.key)
+		}
+	}
+
+	return source, nil
+}
@@ -0,0 +1,703 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/containerd/containerd/remotes/docker"
+	orascontent "github.com/deislabs/oras/pkg/content"
+	"github.com/deislabs/oras/pkg/oras"
+	"helm.sh/helm/v3/pkg/action"
+	"helm.sh/helm/v3/pkg/chart"
+	"helm.sh/helm/v3/pkg/chart/loader"
+	"helm.sh/helm/v3/pkg/cli"
+	"helm.sh/helm/v3/pkg/cli/values"
+	"helm.sh/helm/v3/pkg/downloader"
+	"helm.sh/helm/v3/pkg/getter"
+	"helm.sh/helm/v3/pkg/release"
+	"helm.sh/helm/v3/pkg/releaseutil"
+	"helm.sh/helm/v3/pkg/repo"
+	"helm.sh/helm/v3/pkg/storage/driver"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+	"k8s.io/cli-runtime/pkg/resource"
+	"sigs.k8s.io/yaml"
+)
+
+var (
+	ErrReleaseNotFound = errors.New("release not found")
+	ErrReleaseExists   = errors.New("release already exists")
+	ErrChartNotFound   = errors.New("chart not found")
+	ErrRepoUnreachable = errors.New("chart repository can not be reached")
+)
+
+// The media types Helm pushes charts to OCI registries with.
+const (
+	helmChartConfigMediaType       = "application/vnd.cncf.helm.config.v1+json"
+	helmChartContentLayerMediaType = "application/tar+gzip"
+)
+
+// HelmError describes a failed Helm operation. Where the cause is known it
+// wraps one of the Err* values, so callers can check it with errors.Is.
+type HelmError struct {
+	Op      string
+	Release string
+	Err     error
+}
+
+func (e *HelmError) Error() string {
+	if e.Release == "" {
+		return fmt.Sprintf("helm %v: %v", e.Op, e.Err)
+	}
+	return fmt.Sprintf("helm %v %v: %v", e.Op, e.Release, e.Err)
+}
+
+func (e *HelmError) Unwrap() error {
+	return e.Err
+}
+
+// HelmClient performs Helm operations on the releases of a namespace through
+// the Helm SDK, using the same Kubernetes settings as KubeClient.
+type HelmClient struct {
+	config    *action.Configuration
+	settings  *cli.EnvSettings
+	namespace string
+}
+
+// helmClient is created by getHelmClient on first use. Tests may set it to a
+// client backed by an in-memory release store.
+var helmClient *HelmClient
+
+func NewHelmClient(config *action.Configuration, settings *cli.EnvSettings, namespace string) *HelmClient {
+	return &HelmClient{config: config, settings: settings, namespace: namespace}
+}
+
+// HelmInit builds a Helm client for the namespace and Kubernetes profile
+// selected by the global flags. Releases are stored as secrets, unless
+// HELM_DRIVER selects another storage driver.
+func HelmInit() (*HelmClient, error) {
+	var err error
+
+	settings := cli.New()
+
+	flags := genericclioptions.NewConfigFlags(true)
+	flags.Namespace = &namespace
+	if kubeconfig != "" {
+		flags.KubeConfig = &kubeconfig
+	}
+	if kubeContext != "" {
+		flags.Context = &kubeContext
+	}
+	if kubeAs != "" {
+		flags.Impersonate = &kubeAs
+	}
+	if len(kubeAsGroups) != 0 {
+		flags.ImpersonateGroup = &kubeAsGroups
+	}
+
+	config := new(action.Configuration)
+	err = config.Init(flags, namespace, os.Getenv("HELM_DRIVER"), func(format string, v ...interface{}) {})
+	if err != nil {
+		return nil, err
+	}
+
+	return NewHelmClient(config, settings, namespace), nil
+}
+
+func getHelmClient() (*HelmClient, error) {
+	var err error
+
+	if helmClient == nil {
+		helmClient, err = HelmInit()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return helmClient, nil
+}
+
+// helmRun runs a Helm action, which can not be cancelled, and stops waiting
+// for it once the context is done.
+func helmRun(ctx context.Context, run func() error) error {
+	done := make(chan error, 1)
+	go func() {
+		done <- run()
+	}()
+
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// helmError wraps err of a Helm operation on a release into a HelmError.
+func helmError(op, releaseName string, err error) error {
+	if err == nil {
+		return nil
+	}
+	if errors.Is(err, driver.ErrReleaseNotFound) || errors.Is(err, driver.ErrNoDeployedReleases) {
+		err = fmt.Errorf("%w: %v", ErrReleaseNotFound, err)
+	}
+	return &HelmError{Op: op, Release: releaseName, Err: err}
+}
+
+// HelmDependencyUpdate downloads the dependencies of a local chart into its
+// charts directory, like `helm dependency update` would.
+func (h *HelmClient) HelmDependencyUpdate(ctx context.Context, chartPath string) error {
+	var err error
+
+	manager := &downloader.Manager{
+		Out:              ioutil.Discard,
+		ChartPath:        chartPath,
+		Getters:          getter.All(h.settings),
+		RepositoryConfig: h.settings.RepositoryConfig,
+		RepositoryCache:  h.settings.RepositoryCache,
+	}
+	err = helmRun(ctx, manager.Update)
+	if err != nil {
+		return helmError("dependency update", "", err)
+	}
+
+	return nil
+}
+
+// HelmTemplate renders the chart as a release would be installed, without
+// contacting the cluster. Hooks are appended to the manifest of the returned
+// release like `helm template` prints them.
+func (h *HelmClient) HelmTemplate(ctx context.Context, releaseName string, chrt *chart.Chart, vals map[string]interface{}) (*release.Release, error) {
+	var err error
+
+	// A client-only install replaces the storage and Kubernetes client of its
+	// configuration, so it runs on a copy.
+	config := *h.config
+	install := action.NewInstall(&config)
+	install.ReleaseName = releaseName
+	install.Namespace = h.namespace
+	install.DryRun = true
+	install.ClientOnly = true
+	install.Replace = true
+
+	var rel *release.Release
+	err = helmRun(ctx, func() error {
+		var err error
+		rel, err = install.Run(chrt, vals)
+		return err
+	})
+	if err != nil {
+		return nil, helmError("template", releaseName, err)
+	}
+
+	var manifest strings.Builder
+	fmt.Fprintln(&manifest, strings.TrimSpace(rel.Manifest))
+	for _, hook := range rel.Hooks {
+		fmt.Fprintf(&manifest, "---\n# Source: %v\n%v\n", hook.Path, hook.Manifest)
+	}
+	rel.Manifest = manifest.String()
+
+	return rel, nil
+}
+
+// ChartSource describes where a chart is loaded from. Ref is a local chart
+// directory or archive, a URL, an oci:// reference or <repo>/<chart>. Charts
+// of the repository named RepoName are looked up at RepoURL with the given
+// credentials, while other repositories come from the Helm repositories file.
+type ChartSource struct {
+	Ref                   string
+	Version               string
+	Devel                 bool
+	RepoName              string
+	RepoURL               string
+	Username              string
+	Password              string
+	CAFile                string
+	InsecureSkipTLSVerify bool
+}
+
+// version returns the version constraint to look the chart up with.
+func (s *ChartSource) version() string {
+	if s.Devel && s.Version == "" {
+		return ">0.0.0-0"
+	}
+	return s.Version
+}
+
+// HelmLocateChart resolves a chart source to a local chart directory or
+// archive, downloading the chart if necessary.
+func (h *HelmClient) HelmLocateChart(ctx context.Context, source *ChartSource) (string, error) {
+	var err error
+
+	if strings.HasPrefix(source.Ref, "oci://") {
+		return "", helmError("pull", "", fmt.Errorf("%v is an OCI reference, which has no local path", source.Ref))
+	}
+
+	options := action.ChartPathOptions{
+		Version:               source.version(),
+		Username:              source.Username,
+		Password:              source.Password,
+		CaFile:                source.CAFile,
+		InsecureSkipTLSverify: source.InsecureSkipTLSVerify,
+	}
+
+	ref := source.Ref
+	if repoName := helmRepoName(ref); repoName != "" && repoName == source.RepoName && source.RepoURL != "" {
+		ref, err = h.helmFindChartURL(ctx, source)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	var path string
+	err = helmRun(ctx, func() error {
+		var err error
+		path, err = options.LocateChart(ref, h.settings)
+		return err
+	})
+	if err != nil {
+		if ctx.Err() != nil {
+			return "", helmError("pull", "", err)
+		}
+		return "", helmError("pull", "", fmt.Errorf("%w: %v: %v", ErrChartNotFound, source.Ref, err))
+	}
+
+	return path, nil
+}
+
+// helmFindChartURL looks the chart of a <repo>/<chart> reference up in the
+// index of the source's repository, without adding the repository to the
+// Helm repositories file.
+func (h *HelmClient) helmFindChartURL(ctx context.Context, source *ChartSource) (string, error) {
+	var err error
+
+	entry := &repo.Entry{
+		Name:                  source.RepoName,
+		URL:                   source.RepoURL,
+		Username:              source.Username,
+		Password:              source.Password,
+		CAFile:                source.CAFile,
+		InsecureSkipTLSverify: source.InsecureSkipTLSVerify,
+	}
+	chartRepo, err := repo.NewChartRepository(entry, getter.All(h.settings))
+	if err != nil {
+		return "", helmError("pull", "", err)
+	}
+	chartRepo.CachePath = h.settings.RepositoryCache
+
+	var indexPath string
+	err = helmRun(ctx, func() error {
+		var err error
+		indexPath, err = chartRepo.DownloadIndexFile()
+		return err
+	})
+	if err != nil {
+		return "", helmError("pull", "", fmt.Errorf("%w: %v: %v", ErrRepoUnreachable, source.RepoURL, err))
+	}
+
+	index, err := repo.LoadIndexFile(indexPath)
+	if err != nil {
+		return "", helmError("pull", "", err)
+	}
+
+	chartName := strings.SplitN(source.Ref, "/", 2)[1]
+	chartVersion, err := index.Get(chartName, source.version())
+	if err != nil || len(chartVersion.URLs) == 0 {
+		return "", helmError("pull", "", fmt.Errorf("%w: %v %v in %v", ErrChartNotFound, chartName, source.version(), source.RepoURL))
+	}
+
+	url, err := repo.ResolveReferenceURL(source.RepoURL, chartVersion.URLs[0])
+	if err != nil {
+		return "", helmError("pull", "", err)
+	}
+	return url, nil
+}
+
+// helmPullOCIChart pulls a chart pushed to an OCI registry by Helm, which
+// stores the chart archive as the single content layer of the artifact.
+func (h *HelmClient) helmPullOCIChart(ctx context.Context, source *ChartSource) (*chart.Chart, error) {
+	var err error
+
+	tlsConfig := &tls.Config{InsecureSkipVerify: source.InsecureSkipTLSVerify}
+	if source.CAFile != "" {
+		caCerts, err := ioutil.ReadFile(source.CAFile)
+		if err != nil {
+			return nil, helmError("pull", "", err)
+		}
+		tlsConfig.RootCAs = x509.NewCertPool()
+		if !tlsConfig.RootCAs.AppendCertsFromPEM(caCerts) {
+			return nil, helmError("pull", "", fmt.Errorf("no certificates found in %v", source.CAFile))
+		}
+	}
+	resolver := docker.NewResolver(docker.ResolverOptions{
+		Client: &http.Client{Transport: &http.Transport{Proxy: http.ProxyFromEnvironment, TLSClientConfig: tlsConfig}},
+		Credentials: func(host string) (string, string, error) {
+			return source.Username, source.Password, nil
+		},
+	})
+
+	ref := strings.TrimPrefix(source.Ref, "oci://")
+	if source.Version != "" && !strings.Contains(ref[strings.LastIndex(ref, "/")+1:], ":") {
+		ref += ":" + source.Version
+	}
+
+	store := orascontent.NewMemoryStore()
+	_, layers, err := oras.Pull(ctx, resolver, ref, store, oras.WithAllowedMediaTypes([]string{helmChartConfigMediaType, helmChartContentLayerMediaType}))
+	if err != nil {
+		return nil, helmError("pull", "", fmt.Errorf("%w: %v: %v", ErrChartNotFound, source.Ref, err))
+	}
+
+	for _, layer := range layers {
+		if layer.MediaType != helmChartContentLayerMediaType {
+			continue
+		}
+		_, data, ok := store.Get(layer)
+		if !ok {
+			break
+		}
+		chrt, err := loader.LoadArchive(bytes.NewReader(data))
+		if err != nil {
+			return nil, helmError("load", "", err)
+		}
+		return chrt, nil
+	}
+
+	return nil, helmError("pull", "", fmt.Errorf("%w: %v has no chart content", ErrChartNotFound, source.Ref))
+}
+
+// helmLoadChart loads the chart of a source without checking its dependencies.
+func (h *HelmClient) helmLoadChart(ctx context.Context, source *ChartSource) (*chart.Chart, error) {
+	if strings.HasPrefix(source.Ref, "oci://") {
+		return h.helmPullOCIChart(ctx, source)
+	}
+
+	path, err := h.HelmLocateChart(ctx, source)
+	if err != nil {
+		return nil, err
+	}
+
+	chrt, err := loader.Load(path)
+	if err != nil {
+		return nil, helmError("load", "", err)
+	}
+	return chrt, nil
+}
+
+// HelmLoadChart loads the chart of a source and checks that its dependencies
+// are present.
+func (h *HelmClient) HelmLoadChart(ctx context.Context, source *ChartSource) (*chart.Chart, error) {
+	var err error
+
+	chrt, err := h.helmLoadChart(ctx, source)
+	if err != nil {
+		return nil, err
+	}
+
+	if chrt.Metadata.Dependencies != nil {
+		err = action.CheckDependencies(chrt, chrt.Metadata.Dependencies)
+		if err != nil {
+			return nil, helmError("load", "", err)
+		}
+	}
+
+	return chrt, nil
+}
+
+// ManifestResource is an object of a manifest resolved against the API
+// resources of the cluster. Namespace is empty for cluster-scoped objects.
+type ManifestResource struct {
+	Kind      string
+	Name      string
+	Namespace string
+	Group     string
+	Resource  string
+	// Exists is set if the object is already in the cluster. OwnerRelease
+	// and OwnerNamespace name the release owning it, if Helm manages it.
+	Exists         bool
+	OwnerRelease   string
+	OwnerNamespace string
+}
+
+// HelmManifestResources resolves the objects of a rendered manifest and looks
+// up which of them already exist.
+func (h *HelmClient) HelmManifestResources(ctx context.Context, manifest string) ([]ManifestResource, error) {
+	var err error
+
+	var resources []ManifestResource
+	err = helmRun(ctx, func() error {
+		infos, err := h.config.KubeClient.Build(strings.NewReader(manifest), false)
+		if err != nil {
+			return err
+		}
+
+		return infos.Visit(func(info *resource.Info, err error) error {
+			if err != nil {
+				return err
+			}
+
+			r := ManifestResource{
+				Kind:      info.Mapping.GroupVersionKind.Kind,
+				Name:      info.Name,
+				Namespace: info.Namespace,
+				Group:     info.Mapping.Resource.Group,
+				Resource:  info.Mapping.Resource.Resource,
+			}
+			existing, err := resource.NewHelper(info.Client, info.Mapping).Get(info.Namespace, info.Name, false)
+			if err != nil && !apierrors.IsNotFound(err) {
+				return err
+			}
+			if err == nil {
+				r.Exists = true
+				if accessor, err := meta.Accessor(existing); err == nil {
+					r.OwnerRelease = accessor.GetAnnotations()["meta.helm.sh/release-name"]
+					r.OwnerNamespace = accessor.GetAnnotations()["meta.helm.sh/release-namespace"]
+				}
+			}
+			resources = append(resources, r)
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, helmError("check resources", "", err)
+	}
+
+	return resources, nil
+}
+
+// HelmMergeValues merges values files and --set style values like the Helm
+// CLI does, later sources overriding earlier ones.
+func (h *HelmClient) HelmMergeValues(options *values.Options) (map[string]interface{}, error) {
+	vals, err := options.MergeValues(getter.All(h.settings))
+	if err != nil {
+		return nil, helmError("values", "", err)
+	}
+
+	return vals, nil
+}
+
+// HelmInstall installs the chart as a new release into the client's namespace.
+func (h *HelmClient) HelmInstall(ctx context.Context, releaseName string, chrt *chart.Chart, vals map[string]interface{}) (*release.Release, error) {
+	var err error
+
+	history, err := h.config.Releases.History(releaseName)
+	if err == nil && len(history) != 0 {
+		return nil, helmError("install", releaseName, ErrReleaseExists)
+	}
+
+	install := action.NewInstall(h.config)
+	install.ReleaseName = releaseName
+	install.Namespace = h.namespace
+	install.CreateNamespace = h.namespace != "default"
+
+	var rel *release.Release
+	err = helmRun(ctx, func() error {
+		var err error
+		rel, err = install.Run(chrt, vals)
+		return err
+	})
+	if err != nil {
+		return nil, helmError("install", releaseName, err)
+	}
+
+	return rel, nil
+}
+
+// HelmUpgrade upgrades a release to the chart with the given values. With
+// reuseValues the values of the current revision are merged into vals, and
+// with dryRun the upgraded release is rendered but not applied.
+func (h *HelmClient) HelmUpgrade(ctx context.Context, releaseName string, chrt *chart.Chart, vals map[string]interface{}, reuseValues, dryRun bool) (*release.Release, error) {
+	var err error
+
+	upgrade := action.NewUpgrade(h.config)
+	upgrade.Namespace = h.namespace
+	upgrade.ReuseValues = reuseValues
+	upgrade.DryRun = dryRun
+
+	var rel *release.Release
+	err = helmRun(ctx, func() error {
+		var err error
+		rel, err = upgrade.Run(releaseName, chrt, vals)
+		return err
+	})
+	if err != nil {
+		return nil, helmError("upgrade", releaseName, err)
+	}
+
+	return rel, nil
+}
+
+// HelmRollback rolls a release back to a revision, or to the previous
+// revision if revision is 0.
+func (h *HelmClient) HelmRollback(ctx context.Context, releaseName string, revision int) error {
+	var err error
+
+	rollback := action.NewRollback(h.config)
+	rollback.Version = revision
+
+	err = helmRun(ctx, func() error {
+		return rollback.Run(releaseName)
+	})
+	if err != nil {
+		return helmError("rollback", releaseName, err)
+	}
+
+	return nil
+}
+
+// HelmHistory returns up to max revisions of a release, oldest first.
+func (h *HelmClient) HelmHistory(ctx context.Context, releaseName string, max int) ([]*release.Release, error) {
+	var err error
+
+	history := action.NewHistory(h.config)
+	history.Max = max
+
+	var releases []*release.Release
+	err = helmRun(ctx, func() error {
+		var err error
+		releases, err = history.Run(releaseName)
+		return err
+	})
+	if err != nil {
+		return nil, helmError("history", releaseName, err)
+	}
+
+	releaseutil.SortByRevision(releases)
+	if max > 0 && len(releases) > max {
+		releases = releases[len(releases)-max:]
+	}
+
+	return releases, nil
+}
+
+// HelmUninstall uninstalls a release and deletes its history.
+func (h *HelmClient) HelmUninstall(ctx context.Context, releaseName string) (*release.UninstallReleaseResponse, error) {
+	var err error
+
+	uninstall := action.NewUninstall(h.config)
+
+	var res *release.UninstallReleaseResponse
+	err = helmRun(ctx, func() error {
+		var err error
+		res, err = uninstall.Run(releaseName)
+		return err
+	})
+	if err != nil {
+		return nil, helmError("uninstall", releaseName, err)
+	}
+
+	return res, nil
+}
+
+// HelmGetManifest returns the rendered manifest of the latest revision of a release.
+func (h *HelmClient) HelmGetManifest(ctx context.Context, releaseName string) (string, error) {
+	var err error
+
+	get := action.NewGet(h.config)
+
+	var rel *release.Release
+	err = helmRun(ctx, func() error {
+		var err error
+		rel, err = get.Run(releaseName)
+		return err
+	})
+	if err != nil {
+		return "", helmError("get manifest", releaseName, err)
+	}
+
+	return rel.Manifest, nil
+}
+
+// HelmStatus returns the latest revision of a release.
+func (h *HelmClient) HelmStatus(ctx context.Context, releaseName string) (*release.Release, error) {
+	var err error
+
+	status := action.NewStatus(h.config)
+
+	var rel *release.Release
+	err = helmRun(ctx, func() error {
+		var err error
+		rel, err = status.Run(releaseName)
+		return err
+	})
+	if err != nil {
+		return nil, helmError("status", releaseName, err)
+	}
+
+	return rel, nil
+}
+
+// HelmGetValues returns the values of the latest revision of a release as
+// YAML, including the chart defaults if all is set.
+func (h *HelmClient) HelmGetValues(ctx context.Context, releaseName string, all bool) ([]byte, error) {
+	var err error
+
+	getValues := action.NewGetValues(h.config)
+	getValues.AllValues = all
+
+	var vals map[string]interface{}
+	err = helmRun(ctx, func() error {
+		var err error
+		vals, err = getValues.Run(releaseName)
+		return err
+	})
+	if err != nil {
+		return nil, helmError("get values", releaseName, err)
+	}
+
+	out, err := yaml.Marshal(vals)
+	if err != nil {
+		return nil, helmError("get values", releaseName, err)
+	}
+
+	return out, nil
+}
+
+// HelmShowValues returns the default values file of a chart, comments included.
+func (h *HelmClient) HelmShowValues(ctx context.Context, source *ChartSource) (string, error) {
+	var err error
+
+	// Read values.yaml directly rather than the parsed values, which would
+	// lose the comments documenting them.
+	chrt, err := h.helmLoadChart(ctx, source)
+	if err != nil {
+		return "", err
+	}
+	for _, f := range chrt.Raw {
+		if f.Name == "values.yaml" {
+			return string(f.Data), nil
+		}
+	}
+
+	out, err := yaml.Marshal(chrt.Values)
+	if err != nil {
+		return "", helmError("show values", "", err)
+	}
+
+	return string(out), nil
+}
+
+// helmRepoName returns the repository part of a <repo>/<chart> reference,
+// or "" if ref is a path or URL.
+func helmRepoName(ref string) string {
+	if strings.Contains(ref, "://") || strings.HasPrefix(ref, ".") || strings.HasPrefix(ref, "/") {
+		return ""
+	}
+	if _, err := os.Stat(ref); err == nil {
+		return ""
+	}
+
+	parts := strings.SplitN(ref, "/", 2)
+	if len(parts) != 2 {
+		return ""
+	}
+	return parts[0]
+}
@@ -0,0 +1,348 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"helm.sh/helm/v3/pkg/action"
+	"helm.sh/helm/v3/pkg/chart"
+	"helm.sh/helm/v3/pkg/chartutil"
+	"helm.sh/helm/v3/pkg/cli"
+	"helm.sh/helm/v3/pkg/cli/values"
+	kubefake "helm.sh/helm/v3/pkg/kube/fake"
+	"helm.sh/helm/v3/pkg/release"
+	"helm.sh/helm/v3/pkg/repo"
+	"helm.sh/helm/v3/pkg/storage"
+	"helm.sh/helm/v3/pkg/storage/driver"
+)
+
+func newMemoryHelmClient() *HelmClient {
+	config := &action.Configuration{
+		Releases:     storage.Init(driver.NewMemory()),
+		KubeClient:   &kubefake.PrintingKubeClient{Out: ioutil.Discard},
+		Capabilities: chartutil.DefaultCapabilities,
+		Log:          func(format string, v ...interface{}) {},
+	}
+	return NewHelmClient(config, cli.New(), "default")
+}
+
+func testChart() *chart.Chart {
+	return &chart.Chart{
+		Metadata: &chart.Metadata{APIVersion: "v2", Name: "tobs", Version: "0.1.0"},
+		Templates: []*chart.File{{
+			Name: "templates/configmap.yaml",
+			Data: []byte("apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: {{ .Release.Name }}-cli\ndata:\n  cli: {{ .Values.cli | quote }}\n"),
+		}},
+		Values: map[string]interface{}{"cli": false},
+	}
+}
+
+func TestHelmInstallAndUninstall(t *testing.T) {
+	ctx := context.Background()
+	helm := newMemoryHelmClient()
+
+	rel, err := helm.HelmInstall(ctx, "gg", testChart(), map[string]interface{}{"cli": true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rel.Info.Status != release.StatusDeployed || rel.Version != 1 {
+		t.Fatalf("expected revision 1 to be deployed, got %v %v", rel.Version, rel.Info.Status)
+	}
+
+	_, err = helm.HelmInstall(ctx, "gg", testChart(), nil)
+	if !errors.Is(err, ErrReleaseExists) {
+		t.Fatalf("expected ErrReleaseExists, got %v", err)
+	}
+	var helmErr *HelmError
+	if !errors.As(err, &helmErr) || helmErr.Op != "install" || helmErr.Release != "gg" {
+		t.Fatalf("expected a HelmError for the install of gg, got %#v", err)
+	}
+
+	manifest, err := helm.HelmGetManifest(ctx, "gg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(manifest, `cli: "true"`) {
+		t.Fatalf("expected the values to be rendered into the manifest:\n%v", manifest)
+	}
+
+	values, err := helm.HelmGetValues(ctx, "gg", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.TrimSpace(string(values)) != "cli: true" {
+		t.Fatalf("unexpected user supplied values %q", values)
+	}
+
+	_, err = helm.HelmUninstall(ctx, "gg")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = helm.HelmStatus(ctx, "gg")
+	if !errors.Is(err, ErrReleaseNotFound) {
+		t.Fatalf("expected ErrReleaseNotFound after uninstall, got %v", err)
+	}
+	_, err = helm.HelmUninstall(ctx, "gg")
+	if !errors.Is(err, ErrReleaseNotFound) {
+		t.Fatalf("expected ErrReleaseNotFound uninstalling twice, got %v", err)
+	}
+}
+
+func TestHelmUpgrade(t *testing.T) {
+	ctx := context.Background()
+	helm := newMemoryHelmClient()
+
+	_, err := helm.HelmUpgrade(ctx, "gg", testChart(), nil, false, false)
+	if !errors.Is(err, ErrReleaseNotFound) {
+		t.Fatalf("expected ErrReleaseNotFound upgrading a missing release, got %v", err)
+	}
+
+	_, err = helm.HelmInstall(ctx, "gg", testChart(), map[string]interface{}{"cli": true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	planned, err := helm.HelmUpgrade(ctx, "gg", testChart(), nil, true, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if planned.Version != 2 || !strings.Contains(planned.Manifest, `cli: "true"`) {
+		t.Fatalf("expected a dry run of revision 2 reusing the values, got %v:\n%v", planned.Version, planned.Manifest)
+	}
+	current, err := helm.HelmStatus(ctx, "gg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if current.Version != 1 {
+		t.Fatalf("expected the dry run not to be applied, got revision %v", current.Version)
+	}
+
+	rel, err := helm.HelmUpgrade(ctx, "gg", testChart(), map[string]interface{}{"cli": false}, false, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rel.Version != 2 || rel.Info.Status != release.StatusDeployed || !strings.Contains(rel.Manifest, `cli: "false"`) {
+		t.Fatalf("expected revision 2 to be deployed with the new values, got %v %v", rel.Version, rel.Info.Status)
+	}
+}
+
+func TestHelmHistoryAndRollback(t *testing.T) {
+	ctx := context.Background()
+	helm := newMemoryHelmClient()
+
+	_, err := helm.HelmHistory(ctx, "gg", 10)
+	if !errors.Is(err, ErrReleaseNotFound) {
+		t.Fatalf("expected ErrReleaseNotFound, got %v", err)
+	}
+
+	_, err = helm.HelmInstall(ctx, "gg", testChart(), map[string]interface{}{"cli": true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = helm.HelmUpgrade(ctx, "gg", testChart(), map[string]interface{}{"cli": false}, false, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = helm.HelmRollback(ctx, "gg", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	releases, err := helm.HelmHistory(ctx, "gg", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(releases) != 3 || releases[2].Version != 3 || releases[2].Info.Status != release.StatusDeployed {
+		t.Fatalf("expected the rollback to be deployed as revision 3, got %v revisions", len(releases))
+	}
+	if releases[1].Info.Status != release.StatusSuperseded {
+		t.Fatalf("expected revision 2 to be superseded, got %v", releases[1].Info.Status)
+	}
+	if !strings.Contains(releases[2].Manifest, `cli: "true"`) {
+		t.Fatalf("expected revision 3 to have the values of revision 1:\n%v", releases[2].Manifest)
+	}
+
+	releases, err = helm.HelmHistory(ctx, "gg", 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(releases) != 2 || releases[0].Version != 2 {
+		t.Fatalf("expected the 2 latest revisions, got %v", len(releases))
+	}
+
+	err = helm.HelmRollback(ctx, "gg", 7)
+	if err == nil {
+		t.Fatal("expected an error rolling back to a missing revision")
+	}
+}
+
+func TestHelmTemplate(t *testing.T) {
+	helm := newMemoryHelmClient()
+	helm.namespace = "observability"
+
+	chrt := testChart()
+	chrt.Templates = append(chrt.Templates, &chart.File{
+		Name: "templates/job.yaml",
+		Data: []byte("apiVersion: batch/v1\nkind: Job\nmetadata:\n  name: {{ .Release.Name }}-setup\n  namespace: {{ .Release.Namespace }}\n  annotations:\n    helm.sh/hook: post-install\n"),
+	})
+
+	rel, err := helm.HelmTemplate(context.Background(), "gg", chrt, map[string]interface{}{"cli": true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, expected := range []string{"# Source: tobs/templates/configmap.yaml", `cli: "true"`, "# Source: tobs/templates/job.yaml", "namespace: observability"} {
+		if !strings.Contains(rel.Manifest, expected) {
+			t.Fatalf("expected %q in the manifest:\n%v", expected, rel.Manifest)
+		}
+	}
+
+	_, err = helm.HelmStatus(context.Background(), "gg")
+	if !errors.Is(err, ErrReleaseNotFound) {
+		t.Fatalf("expected the template not to be stored as a release, got %v", err)
+	}
+}
+
+func TestHelmMergeValues(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tobs-values")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	write := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	base := write("base.yaml", "promscale:\n  image: timescale/promscale:0.1.0\n  connection:\n    user: postgres\n")
+	override := write("override.yaml", "promscale:\n  connection:\n    user: metrics\n")
+	query := write("query.sql", "SELECT 1")
+
+	helm := newMemoryHelmClient()
+	vals, err := helm.HelmMergeValues(&values.Options{
+		ValueFiles:   []string{base, override},
+		Values:       []string{"cli=true", "timescaledb-single.replicaCount=3"},
+		StringValues: []string{"promscale.connection.port=5432"},
+		FileValues:   []string{"query=" + query},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for path, expected := range map[string]interface{}{
+		"promscale.image":                 "timescale/promscale:0.1.0",
+		"promscale.connection.user":       "metrics",
+		"promscale.connection.port":       "5432",
+		"timescaledb-single.replicaCount": int64(3),
+		"cli":                             true,
+		"query":                           "SELECT 1",
+	} {
+		value, err := chartutil.Values(vals).PathValue(path)
+		if err != nil || value != expected {
+			t.Errorf("expected %v to be %#v, got %#v", path, expected, value)
+		}
+	}
+}
+
+func TestHelmShowValues(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tobs-chart")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	chrt := testChart()
+	chrt.Raw = []*chart.File{{Name: "values.yaml", Data: []byte("# whether tobs installed the chart\ncli: false\n")}}
+	err = chartutil.SaveDir(chrt, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	helm := newMemoryHelmClient()
+	out, err := helm.HelmShowValues(context.Background(), &ChartSource{Ref: filepath.Join(dir, "tobs")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(out, "# whether tobs installed the chart") {
+		t.Fatalf("expected the values file with its comments, got %q", out)
+	}
+
+	_, err = helm.HelmShowValues(context.Background(), &ChartSource{Ref: filepath.Join(dir, "missing")})
+	if !errors.Is(err, ErrChartNotFound) {
+		t.Fatalf("expected ErrChartNotFound, got %v", err)
+	}
+}
+
+func TestHelmLoadChartFromRepository(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tobs-repo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	archive, err := chartutil.Save(testChart(), dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if username, password, ok := r.BasicAuth(); !ok || username != "tobs" || password != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		http.ServeFile(w, r, filepath.Join(dir, filepath.Base(r.URL.Path)))
+	}))
+	defer server.Close()
+
+	index := repo.NewIndexFile()
+	index.Add(testChart().Metadata, filepath.Base(archive), server.URL, "")
+	err = index.WriteFile(filepath.Join(dir, "index.yaml"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	helm := newMemoryHelmClient()
+	helm.settings.RepositoryCache = filepath.Join(dir, "cache")
+	helm.settings.RepositoryConfig = filepath.Join(dir, "repositories.yaml")
+
+	source := &ChartSource{Ref: "mirror/tobs", RepoName: "mirror", RepoURL: server.URL, Username: "tobs", Password: "secret"}
+	chrt, err := helm.HelmLoadChart(context.Background(), source)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if chrt.Metadata.Version != "0.1.0" {
+		t.Fatalf("expected chart version 0.1.0, got %v", chrt.Metadata.Version)
+	}
+
+	source.Version = "0.2.0"
+	_, err = helm.HelmLoadChart(context.Background(), source)
+	if !errors.Is(err, ErrChartNotFound) {
+		t.Fatalf("expected ErrChartNotFound, got %v", err)
+	}
+
+	source.Version = ""
+	source.Password = "wrong"
+	_, err = helm.HelmLoadChart(context.Background(), source)
+	if !errors.Is(err, ErrRepoUnreachable) {
+		t.Fatalf("expected ErrRepoUnreachable, got %v", err)
+	}
+
+	chrt, err = helm.HelmLoadChart(context.Background(), &ChartSource{Ref: archive})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if chrt.Metadata.Name != "tobs" {
+		t.Fatalf("expected the tobs chart from the archive, got %v", chrt.Metadata.Name)
+	}
+}
@@ -21,17 +21,23 @@ func init() {
 func helmDeleteData(cmd *cobra.Command, args []string) error {
 	var err error
 
+	ctx := cmd.Context()
+	client, err := getKubeClient()
+	if err != nil {
+		return fmt.Errorf("could not delete PVCs: %w", err)
+	}
+
 	fmt.Println("Getting Persistent Volume Claims")
-	pvcnames, err := KubeGetPVCNames(namespace, map[string]string{"release": name})
+	pvcnames, err := client.KubeGetAllPVCNames(ctx, namespace, name)
 	if err != nil {
 		return fmt.Errorf("could not delete PVCs: %w", err)
 	}
 
 	fmt.Println("Removing Persistent Volume Claims")
 	for _, s := range pvcnames {
-		err = KubeDeletePVC(namespace, s)
+		err = client.KubeDeletePVC(ctx, namespace, s)
 		if err != nil {
-		    return fmt.Errorf("could not delete PVCs: %w", err)
+			return fmt.Errorf("could not delete PVCs: %w", err)
 		}
 	}
 
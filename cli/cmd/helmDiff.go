@@ -0,0 +1,270 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/fatih/color"
+	"github.com/pmezard/go-difflib/difflib"
+	"github.com/spf13/cobra"
+	"helm.sh/helm/v3/pkg/cli/values"
+	"sigs.k8s.io/yaml"
+)
+
+// helmDiffCmd represents the helm diff command
+var helmDiffCmd = &cobra.Command{
+	Use:   "diff",
+	Short: "Shows how an upgrade would change the resources of The Observability Stack, exits non-zero if anything changes",
+	Args:  cobra.ExactArgs(0),
+	RunE:  helmDiff,
+}
+
+func init() {
+	helmCmd.AddCommand(helmDiffCmd)
+	addHelmValuesFlags(helmDiffCmd)
+	addHelmChartFlags(helmDiffCmd)
+	helmDiffCmd.Flags().BoolP("reuse-values", "", false, "Reuse the values of the current release and merge in overrides")
+	helmDiffCmd.Flags().IntP("context-lines", "", 3, "Number of unchanged lines to show around each change")
+	helmDiffCmd.Flags().BoolP("no-color", "", false, "Do not color the diff, the default if the output is not a terminal")
+}
+
+// secretMask replaces the values of Secrets in diffs.
+const secretMask = "***"
+
+// resourceDiff is the unified diff of one resource between two manifests.
+// Change is one of added, removed or changed.
+type resourceDiff struct {
+	Resource string
+	Change   string
+	Diff     string
+}
+
+func helmDiff(cmd *cobra.Command, args []string) error {
+	var err error
+
+	var valuesOptions *values.Options
+	valuesOptions, err = getHelmValuesOptions(cmd)
+	if err != nil {
+		return fmt.Errorf("could not diff The Observability Stack: %w", err)
+	}
+
+	var source *ChartSource
+	source, err = getChartSource(cmd)
+	if err != nil {
+		return fmt.Errorf("could not diff The Observability Stack: %w", err)
+	}
+
+	var reuseValues, noColor bool
+	reuseValues, err = cmd.Flags().GetBool("reuse-values")
+	if err != nil {
+		return fmt.Errorf("could not diff The Observability Stack: %w", err)
+	}
+	noColor, err = cmd.Flags().GetBool("no-color")
+	if err != nil {
+		return fmt.Errorf("could not diff The Observability Stack: %w", err)
+	}
+
+	var contextLines int
+	contextLines, err = cmd.Flags().GetInt("context-lines")
+	if err != nil {
+		return fmt.Errorf("could not diff The Observability Stack: %w", err)
+	}
+
+	ctx := cmd.Context()
+	helm, err := getHelmClient()
+	if err != nil {
+		return fmt.Errorf("could not diff The Observability Stack: %w", err)
+	}
+
+	current, err := helm.HelmStatus(ctx, name)
+	if errors.Is(err, ErrReleaseNotFound) {
+		return fmt.Errorf("could not diff The Observability Stack: %w, use tobs install to install it", err)
+	}
+	if err != nil {
+		return fmt.Errorf("could not diff The Observability Stack: %w", err)
+	}
+
+	chart, err := helm.HelmLoadChart(ctx, source)
+	if err != nil {
+		return fmt.Errorf("could not diff The Observability Stack: %w", err)
+	}
+
+	valuesOptions.Values = append([]string{"cli=true"}, valuesOptions.Values...)
+	vals, err := helm.HelmMergeValues(valuesOptions)
+	if err != nil {
+		return fmt.Errorf("could not diff The Observability Stack: %w", err)
+	}
+
+	vals = upgradeValues(vals, current, reuseValues)
+	planned, err := helm.HelmUpgrade(ctx, name, chart, vals, false, true)
+	if err != nil {
+		return fmt.Errorf("could not diff The Observability Stack: %w", err)
+	}
+
+	diffs, err := diffManifests(current.Manifest, planned.Manifest, contextLines)
+	if err != nil {
+		return fmt.Errorf("could not diff The Observability Stack: %w", err)
+	}
+
+	if noColor {
+		color.NoColor = true
+	}
+	printResourceDiffs(os.Stdout, diffs)
+
+	if len(diffs) != 0 {
+		return fmt.Errorf("%d resources of revision %d would change", len(diffs), current.Version)
+	}
+	fmt.Printf("No changes to revision %d\n", current.Version)
+	return nil
+}
+
+// diffManifests compares two release manifests resource by resource. The
+// values of Secrets are masked, only showing whether they changed.
+func diffManifests(from, to string, contextLines int) ([]resourceDiff, error) {
+	fromResources, err := manifestResources(from)
+	if err != nil {
+		return nil, err
+	}
+	toResources, err := manifestResources(to)
+	if err != nil {
+		return nil, err
+	}
+
+	var diffs []resourceDiff
+	for _, key := range unionKeys(fromResources, toResources) {
+		fromDoc, inFrom := fromResources[key]
+		toDoc, inTo := toResources[key]
+		if fromDoc == toDoc {
+			continue
+		}
+
+		if strings.HasPrefix(key, "Secret/") {
+			fromDoc, toDoc, err = maskSecrets(fromDoc, toDoc)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		change := "changed"
+		switch {
+		case !inFrom:
+			change = "added"
+			fromDoc = ""
+		case !inTo:
+			change = "removed"
+			toDoc = ""
+		}
+
+		diff, err := difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+			A:        difflib.SplitLines(strings.TrimSpace(fromDoc) + "\n"),
+			B:        difflib.SplitLines(strings.TrimSpace(toDoc) + "\n"),
+			FromFile: "deployed/" + key,
+			ToFile:   "planned/" + key,
+			Context:  contextLines,
+		})
+		if err != nil {
+			return nil, err
+		}
+		if diff == "" {
+			// Only the formatting differs, which masking a Secret normalizes.
+			continue
+		}
+		diffs = append(diffs, resourceDiff{Resource: key, Change: change, Diff: diff})
+	}
+
+	return diffs, nil
+}
+
+// maskSecrets replaces the values under data and stringData of two revisions
+// of a Secret. Values present in both revisions are masked alike when they
+// are equal and differently when they changed, so the diff shows which keys
+// changed without showing their values.
+func maskSecrets(from, to string) (string, string, error) {
+	var fromSecret, toSecret map[string]interface{}
+	if from != "" {
+		if err := yaml.Unmarshal([]byte(from), &fromSecret); err != nil {
+			return "", "", fmt.Errorf("could not parse the release manifest: %w", err)
+		}
+	}
+	if to != "" {
+		if err := yaml.Unmarshal([]byte(to), &toSecret); err != nil {
+			return "", "", fmt.Errorf("could not parse the release manifest: %w", err)
+		}
+	}
+
+	for _, field := range []string{"data", "stringData"} {
+		fromData, _ := fromSecret[field].(map[string]interface{})
+		toData, _ := toSecret[field].(map[string]interface{})
+		for key, fromValue := range fromData {
+			toValue, exists := toData[key]
+			switch {
+			case !exists:
+				fromData[key] = secretMask
+			case fmt.Sprint(fromValue) == fmt.Sprint(toValue):
+				fromData[key] = secretMask
+				toData[key] = secretMask
+			default:
+				fromData[key] = secretMask + " (before)"
+				toData[key] = secretMask + " (after)"
+			}
+		}
+		for key := range toData {
+			if _, exists := fromData[key]; !exists {
+				toData[key] = secretMask
+			}
+		}
+	}
+
+	var maskedFrom, maskedTo []byte
+	var err error
+	if fromSecret != nil {
+		maskedFrom, err = yaml.Marshal(fromSecret)
+		if err != nil {
+			return "", "", err
+		}
+	}
+	if toSecret != nil {
+		maskedTo, err = yaml.Marshal(toSecret)
+		if err != nil {
+			return "", "", err
+		}
+	}
+	return string(maskedFrom), string(maskedTo), nil
+}
+
+// printResourceDiffs prints the diffs with added lines in green and removed
+// lines in red, unless colors are disabled.
+func printResourceDiffs(w io.Writer, diffs []resourceDiff) {
+	header := color.New(color.Bold)
+	added := color.New(color.FgGreen)
+	removed := color.New(color.FgRed)
+	hunk := color.New(color.FgCyan)
+
+	counts := make(map[string]int)
+	for _, diff := range diffs {
+		counts[diff.Change]++
+		header.Fprintf(w, "%v %v\n", diff.Resource, diff.Change)
+		for _, line := range strings.SplitAfter(diff.Diff, "\n") {
+			switch {
+			case strings.HasPrefix(line, "+++"), strings.HasPrefix(line, "---"):
+				header.Fprint(w, line)
+			case strings.HasPrefix(line, "+"):
+				added.Fprint(w, line)
+			case strings.HasPrefix(line, "-"):
+				removed.Fprint(w, line)
+			case strings.HasPrefix(line, "@@"):
+				hunk.Fprint(w, line)
+			default:
+				fmt.Fprint(w, line)
+			}
+		}
+		fmt.Fprintln(w)
+	}
+
+	if len(diffs) != 0 {
+		fmt.Fprintf(w, "%d changed, %d added, %d removed\n", counts["changed"], counts["added"], counts["removed"])
+	}
+}
@@ -0,0 +1,96 @@
+package cmd
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/fatih/color"
+)
+
+func TestDiffManifests(t *testing.T) {
+	from := `---
+# Source: tobs/templates/configmap.yaml
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: gg-cli
+data:
+  cli: "true"
+---
+# Source: tobs/templates/secret.yaml
+apiVersion: v1
+kind: Secret
+metadata:
+  name: gg-passwords
+data:
+  admin: b2xk
+  grafana: c2FtZQ==
+---
+# Source: tobs/templates/removed.yaml
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: gg-removed
+`
+	to := strings.Replace(from, `cli: "true"`, `cli: "false"`, 1)
+	to = strings.Replace(to, "admin: b2xk", "admin: bmV3", 1)
+	to = strings.Replace(to, "name: gg-removed", "name: gg-added", 1)
+
+	diffs, err := diffManifests(from, to, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	changes := make(map[string]string)
+	for _, diff := range diffs {
+		changes[diff.Resource] = diff.Change
+	}
+	expected := map[string]string{
+		"ConfigMap/gg-cli":     "changed",
+		"ConfigMap/gg-added":   "added",
+		"ConfigMap/gg-removed": "removed",
+		"Secret/gg-passwords":  "changed",
+	}
+	if len(changes) != len(expected) {
+		t.Fatalf("expected %v, got %v", expected, changes)
+	}
+	for resource, change := range expected {
+		if changes[resource] != change {
+			t.Fatalf("expected %v to be %v, got %v", resource, change, changes[resource])
+		}
+	}
+
+	for _, diff := range diffs {
+		if diff.Resource != "Secret/gg-passwords" {
+			continue
+		}
+		if strings.Contains(diff.Diff, "b2xk") || strings.Contains(diff.Diff, "bmV3") || strings.Contains(diff.Diff, "c2FtZQ==") {
+			t.Fatalf("expected the secret values to be masked, got\n%v", diff.Diff)
+		}
+		if !strings.Contains(diff.Diff, "-  admin: '*** (before)'") || !strings.Contains(diff.Diff, "+  admin: '*** (after)'") || strings.Contains(diff.Diff, "+  grafana") {
+			t.Fatalf("expected only the changed key to show up, got\n%v", diff.Diff)
+		}
+	}
+
+	diffs, err = diffManifests(from, from, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(diffs) != 0 {
+		t.Fatalf("expected no diffs between identical manifests, got %v", diffs)
+	}
+}
+
+func TestPrintResourceDiffs(t *testing.T) {
+	noColor := color.NoColor
+	color.NoColor = true
+	defer func() { color.NoColor = noColor }()
+
+	var out bytes.Buffer
+	printResourceDiffs(&out, []resourceDiff{{Resource: "ConfigMap/gg-cli", Change: "changed", Diff: "--- a\n+++ b\n@@ -1 +1 @@\n-old\n+new\n"}})
+	expected := "ConfigMap/gg-cli changed\n--- a\n+++ b\n@@ -1 +1 @@\n-old\n+new\n\n1 changed, 0 added, 0 removed\n"
+	if out.String() != expected {
+		t.Fatalf("expected %q, got %q", expected, out.String())
+	}
+}
@@ -0,0 +1,111 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+	"helm.sh/helm/v3/pkg/release"
+)
+
+// helmHistoryCmd represents the helm history command
+var helmHistoryCmd = &cobra.Command{
+	Use:   "history",
+	Short: "Lists the revisions of The Observability Stack release",
+	Args:  cobra.ExactArgs(0),
+	RunE:  helmHistory,
+}
+
+func init() {
+	helmCmd.AddCommand(helmHistoryCmd)
+	helmHistoryCmd.Flags().IntP("max", "", 256, "Maximum number of revisions to list")
+	helmHistoryCmd.Flags().StringP("output", "o", "table", "Output format, one of table or json")
+}
+
+type releaseRevision struct {
+	Revision     int       `json:"revision"`
+	Updated      time.Time `json:"updated"`
+	Status       string    `json:"status"`
+	Chart        string    `json:"chart"`
+	ChartVersion string    `json:"chartVersion"`
+	AppVersion   string    `json:"appVersion"`
+	Description  string    `json:"description"`
+}
+
+func helmHistory(cmd *cobra.Command, args []string) error {
+	var err error
+
+	var max int
+	max, err = cmd.Flags().GetInt("max")
+	if err != nil {
+		return fmt.Errorf("could not get release history: %w", err)
+	}
+
+	var output string
+	output, err = cmd.Flags().GetString("output")
+	if err != nil {
+		return fmt.Errorf("could not get release history: %w", err)
+	}
+	if output != "table" && output != "json" {
+		return fmt.Errorf("could not get release history: unknown output format %v", output)
+	}
+
+	ctx := cmd.Context()
+	helm, err := getHelmClient()
+	if err != nil {
+		return fmt.Errorf("could not get release history: %w", err)
+	}
+
+	releases, err := helm.HelmHistory(ctx, name, max)
+	if err != nil {
+		return fmt.Errorf("could not get release history: %w", err)
+	}
+
+	revisions := make([]releaseRevision, 0, len(releases))
+	for _, rel := range releases {
+		revisions = append(revisions, getReleaseRevision(rel))
+	}
+
+	if output == "json" {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		err = encoder.Encode(revisions)
+	} else {
+		err = printReleaseRevisions(revisions)
+	}
+	if err != nil {
+		return fmt.Errorf("could not get release history: %w", err)
+	}
+
+	return nil
+}
+
+func getReleaseRevision(rel *release.Release) releaseRevision {
+	revision := releaseRevision{Revision: rel.Version}
+	if rel.Info != nil {
+		revision.Updated = rel.Info.LastDeployed.Time
+		revision.Status = rel.Info.Status.String()
+		revision.Description = rel.Info.Description
+	}
+	if rel.Chart != nil && rel.Chart.Metadata != nil {
+		revision.Chart = rel.Chart.Metadata.Name
+		revision.ChartVersion = rel.Chart.Metadata.Version
+		revision.AppVersion = rel.Chart.Metadata.AppVersion
+	}
+	return revision
+}
+
+func printReleaseRevisions(revisions []releaseRevision) error {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+
+	fmt.Fprintln(w, "REVISION\tUPDATED\tSTATUS\tCHART\tAPP VERSION\tDESCRIPTION")
+	for _, revision := range revisions {
+		updated := revision.Updated.Local().Format(time.ANSIC)
+		fmt.Fprintf(w, "%d\t%v\t%v\t%v-%v\t%v\t%v\n", revision.Revision, updated, revision.Status, revision.Chart, revision.ChartVersion, revision.AppVersion, revision.Description)
+	}
+
+	return w.Flush()
+}
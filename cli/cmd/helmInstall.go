@@ -2,17 +2,13 @@ package cmd
 
 import (
 	"fmt"
-	"io"
-	"os"
-	"os/exec"
 	"time"
 
 	"github.com/spf13/cobra"
+	"helm.sh/helm/v3/pkg/cli/values"
+	"sigs.k8s.io/yaml"
 )
 
-const DEVEL = false
-const REPO_LOCATION = "https://charts.timescale.com"
-
 // helmInstallCmd represents the helm install command
 var helmInstallCmd = &cobra.Command{
 	Use:   "install",
@@ -27,79 +23,110 @@ func init() {
 }
 
 func addHelmInstallFlags(cmd *cobra.Command) {
-	cmd.Flags().StringP("filename", "f", "", "YAML configuration file to load")
-	cmd.Flags().StringP("chart-reference", "c", "timescale/tobs", "Helm chart reference")
+	addHelmValuesFlags(cmd)
+	addHelmChartFlags(cmd)
+	cmd.Flags().DurationP("wait-timeout", "", DEFAULT_TIMEOUT, "How long to wait for all pods to become ready")
+	cmd.Flags().BoolP("dry-run", "", false, "Print the manifests that would be installed without contacting the cluster")
+	cmd.Flags().BoolP("skip-preflight", "", false, "Install without running the pre-flight checks")
 }
 
 func helmInstall(cmd *cobra.Command, args []string) error {
 	var err error
 
-	var file, ref string
-	file, err = cmd.Flags().GetString("filename")
+	var valuesOptions *values.Options
+	valuesOptions, err = getHelmValuesOptions(cmd)
 	if err != nil {
 		return fmt.Errorf("could not install The Observability Stack: %w", err)
 	}
-	ref, err = cmd.Flags().GetString("chart-reference")
+
+	var source *ChartSource
+	source, err = getChartSource(cmd)
 	if err != nil {
 		return fmt.Errorf("could not install The Observability Stack: %w", err)
 	}
 
-	w := io.Writer(os.Stdout)
-
-	addchart := exec.Command("helm", "repo", "add", "timescale", REPO_LOCATION)
-
-	addchart.Stdout = w
-	addchart.Stderr = w
-	fmt.Println("Adding Timescale Helm Repository")
-	err = addchart.Run()
+	var waitTimeout time.Duration
+	waitTimeout, err = cmd.Flags().GetDuration("wait-timeout")
 	if err != nil {
 		return fmt.Errorf("could not install The Observability Stack: %w", err)
 	}
 
-	update := exec.Command("helm", "repo", "update")
-
-	update.Stdout = w
-	update.Stderr = w
-	fmt.Println("Fetching updates from repository")
-	err = update.Run()
+	var dryRun, skipPreflight bool
+	dryRun, err = cmd.Flags().GetBool("dry-run")
+	if err != nil {
+		return fmt.Errorf("could not install The Observability Stack: %w", err)
+	}
+	skipPreflight, err = cmd.Flags().GetBool("skip-preflight")
 	if err != nil {
 		return fmt.Errorf("could not install The Observability Stack: %w", err)
 	}
 
-	cmds := []string{"install", name, ref, "--set", "cli=true"}
-	if namespace != "default" {
-		cmds = append(cmds, "--create-namespace", "--namespace", namespace)
+	ctx := cmd.Context()
+	helm, err := getHelmClient()
+	if err != nil {
+		return fmt.Errorf("could not install The Observability Stack: %w", err)
 	}
-	if file != "" {
-		cmds = append(cmds, "--values", file)
+
+	chart, err := helm.HelmLoadChart(ctx, source)
+	if err != nil {
+		return fmt.Errorf("could not install The Observability Stack: %w", err)
 	}
-	if DEVEL {
-		cmds = append(cmds, "--devel")
+
+	valuesOptions.Values = append([]string{"cli=true"}, valuesOptions.Values...)
+	vals, err := helm.HelmMergeValues(valuesOptions)
+	if err != nil {
+		return fmt.Errorf("could not install The Observability Stack: %w", err)
 	}
 
-	install := exec.Command("helm", cmds...)
-	fmt.Println("Installing The Observability Stack")
-	out, err := install.CombinedOutput()
+	err = checkValues(chart, vals)
 	if err != nil {
-		return fmt.Errorf("could not install The Observability Stack: %w \nOutput: %v", err, string(out))
+		return fmt.Errorf("could not install The Observability Stack: %w", err)
 	}
 
-	time.Sleep(10 * time.Second)
+	if dryRun {
+		release, err := helm.HelmTemplate(ctx, name, chart, vals)
+		if err != nil {
+			return fmt.Errorf("could not install The Observability Stack: %w", err)
+		}
+		fmt.Print(release.Manifest)
+		return nil
+	}
 
-	fmt.Println("Waiting for pods to initialize...")
-	pods, err := KubeGetAllPods(namespace, name)
+	client, err := getKubeClient()
 	if err != nil {
-		return err
+		return fmt.Errorf("could not install The Observability Stack: %w", err)
 	}
 
-	for _, pod := range pods {
-		err = KubeWaitOnPod(namespace, pod.Name)
+	// Skipping does not even run the checks, as users who can not pass them
+	// may not be allowed to run them either, e.g. with restricted RBAC.
+	if !skipPreflight {
+		err = checkPreflight(ctx, client, helm, chart, vals)
 		if err != nil {
-			return err
+			return fmt.Errorf("could not install The Observability Stack: %w, use --skip-preflight to install anyway", err)
 		}
 	}
 
+	merged, err := yaml.Marshal(vals)
+	if err != nil {
+		return fmt.Errorf("could not install The Observability Stack: %w", err)
+	}
+	fmt.Printf("Installing The Observability Stack %v with the values:\n%v", chart.Metadata.Version, merged)
+	release, err := helm.HelmInstall(ctx, name, chart, vals)
+	if err != nil {
+		return fmt.Errorf("could not install The Observability Stack: %w", err)
+	}
+
+	fmt.Println("Waiting for pods to initialize...")
+	progress, err := waitForStack(ctx, client, waitTimeout)
+	if err != nil {
+		return fmt.Errorf("could not install The Observability Stack: %w", err)
+	}
+
 	fmt.Println("The Observability Stack has been installed successfully")
-	fmt.Println(string(out))
+	fmt.Println(release.Info.Notes)
+	err = printStackSummary(ctx, client, progress)
+	if err != nil {
+		return fmt.Errorf("could not install The Observability Stack: %w", err)
+	}
 	return nil
 }
@@ -0,0 +1,219 @@
+package cmd
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"helm.sh/helm/v3/pkg/release"
+	"helm.sh/helm/v3/pkg/releaseutil"
+	"sigs.k8s.io/yaml"
+)
+
+// helmRollbackCmd represents the helm rollback command
+var helmRollbackCmd = &cobra.Command{
+	Use:   "rollback [revision]",
+	Short: "Rolls The Observability Stack back to a revision, the previous one by default",
+	Args:  cobra.MaximumNArgs(1),
+	RunE:  helmRollback,
+}
+
+func init() {
+	helmCmd.AddCommand(helmRollbackCmd)
+	helmRollbackCmd.Flags().DurationP("wait-timeout", "", DEFAULT_TIMEOUT, "How long to wait for all pods to become ready")
+}
+
+func helmRollback(cmd *cobra.Command, args []string) error {
+	var err error
+
+	revision := 0
+	if len(args) == 1 {
+		revision, err = strconv.Atoi(args[0])
+		if err != nil || revision < 1 {
+			return fmt.Errorf("could not roll back The Observability Stack: invalid revision %v", args[0])
+		}
+	}
+
+	var waitTimeout time.Duration
+	waitTimeout, err = cmd.Flags().GetDuration("wait-timeout")
+	if err != nil {
+		return fmt.Errorf("could not roll back The Observability Stack: %w", err)
+	}
+
+	ctx := cmd.Context()
+	client, err := getKubeClient()
+	if err != nil {
+		return fmt.Errorf("could not roll back The Observability Stack: %w", err)
+	}
+
+	helm, err := getHelmClient()
+	if err != nil {
+		return fmt.Errorf("could not roll back The Observability Stack: %w", err)
+	}
+
+	current, err := helm.HelmStatus(ctx, name)
+	if err != nil {
+		return fmt.Errorf("could not roll back The Observability Stack: %w", err)
+	}
+
+	fmt.Println("Rolling back The Observability Stack")
+	err = helm.HelmRollback(ctx, name, revision)
+	if err != nil {
+		return fmt.Errorf("could not roll back The Observability Stack: %w", err)
+	}
+
+	rolledBack, err := helm.HelmStatus(ctx, name)
+	if err != nil {
+		return fmt.Errorf("could not roll back The Observability Stack: %w", err)
+	}
+
+	fmt.Println("Waiting for pods to become ready...")
+	_, err = waitForStack(ctx, client, waitTimeout)
+	if err != nil {
+		return fmt.Errorf("could not roll back The Observability Stack: %w", err)
+	}
+
+	fmt.Printf("The Observability Stack has been rolled back from revision %d, now revision %d\n", current.Version, rolledBack.Version)
+	changes, err := releaseChanges(current, rolledBack)
+	if err != nil {
+		return fmt.Errorf("could not roll back The Observability Stack: %w", err)
+	}
+	if len(changes) == 0 {
+		fmt.Println("Nothing changed")
+	}
+	for _, change := range changes {
+		fmt.Println("  " + change)
+	}
+
+	return nil
+}
+
+// releaseChanges summarizes the differences between two revisions of a
+// release: the chart version, the user supplied values and the resources.
+// Values of secret-looking keys, like passwords, are masked.
+func releaseChanges(from, to *release.Release) ([]string, error) {
+	var changes []string
+
+	fromChart := from.Chart.Metadata.Name + "-" + from.Chart.Metadata.Version
+	toChart := to.Chart.Metadata.Name + "-" + to.Chart.Metadata.Version
+	if fromChart != toChart {
+		changes = append(changes, fmt.Sprintf("chart: %v -> %v", fromChart, toChart))
+	}
+
+	fromValues := make(map[string]string)
+	flattenValues("", from.Config, fromValues)
+	toValues := make(map[string]string)
+	flattenValues("", to.Config, toValues)
+	for _, key := range unionKeys(fromValues, toValues) {
+		fromValue, inFrom := fromValues[key]
+		toValue, inTo := toValues[key]
+		if secretValueKey(key) {
+			fromValue, toValue = secretMask, secretMask
+			if inFrom && inTo && fromValues[key] != toValues[key] {
+				fromValue, toValue = secretMask+" (before)", secretMask+" (after)"
+			}
+		}
+		switch {
+		case !inFrom:
+			changes = append(changes, fmt.Sprintf("value %v: set to %v", key, toValue))
+		case !inTo:
+			changes = append(changes, fmt.Sprintf("value %v: unset, was %v", key, fromValue))
+		case fromValue != toValue:
+			changes = append(changes, fmt.Sprintf("value %v: %v -> %v", key, fromValue, toValue))
+		}
+	}
+
+	fromResources, err := manifestResources(from.Manifest)
+	if err != nil {
+		return nil, err
+	}
+	toResources, err := manifestResources(to.Manifest)
+	if err != nil {
+		return nil, err
+	}
+	for _, key := range unionKeys(fromResources, toResources) {
+		fromResource, inFrom := fromResources[key]
+		toResource, inTo := toResources[key]
+		switch {
+		case !inFrom:
+			changes = append(changes, fmt.Sprintf("%v: created", key))
+		case !inTo:
+			changes = append(changes, fmt.Sprintf("%v: deleted", key))
+		case fromResource != toResource:
+			changes = append(changes, fmt.Sprintf("%v: changed", key))
+		}
+	}
+
+	return changes, nil
+}
+
+// flattenValues stores nested values into out, keyed by their dotted path.
+func flattenValues(prefix string, values map[string]interface{}, out map[string]string) {
+	for key, value := range values {
+		if prefix != "" {
+			key = prefix + "." + key
+		}
+		if nested, ok := value.(map[string]interface{}); ok && len(nested) != 0 {
+			flattenValues(key, nested, out)
+			continue
+		}
+		out[key] = fmt.Sprint(value)
+	}
+}
+
+// secretValueKeys are the parts of value keys, in lower case, that mark
+// values like grafana.timescale.database.pass as secret.
+var secretValueKeys = []string{"pass", "secret", "token", "credential", "apikey", "privatekey"}
+
+// secretValueKey reports whether the dotted key of a value looks like it
+// holds a secret.
+func secretValueKey(key string) bool {
+	parts := strings.Split(key, ".")
+	last := strings.ToLower(parts[len(parts)-1])
+	for _, secret := range secretValueKeys {
+		if strings.Contains(last, secret) {
+			return true
+		}
+	}
+	return false
+}
+
+// manifestResources splits a release manifest into its resources, keyed by
+// <kind>/<name>.
+func manifestResources(manifest string) (map[string]string, error) {
+	resources := make(map[string]string)
+	for _, doc := range releaseutil.SplitManifests(manifest) {
+		var resource struct {
+			Kind     string `json:"kind"`
+			Metadata struct {
+				Name string `json:"name"`
+			} `json:"metadata"`
+		}
+		err := yaml.Unmarshal([]byte(doc), &resource)
+		if err != nil {
+			return nil, fmt.Errorf("could not parse the release manifest: %w", err)
+		}
+		if resource.Kind == "" {
+			continue
+		}
+		resources[resource.Kind+"/"+resource.Metadata.Name] = doc
+	}
+	return resources, nil
+}
+
+func unionKeys(a, b map[string]string) []string {
+	var keys []string
+	for key := range a {
+		keys = append(keys, key)
+	}
+	for key := range b {
+		if _, exists := a[key]; !exists {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
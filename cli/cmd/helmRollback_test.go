@@ -0,0 +1,59 @@
+package cmd
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"helm.sh/helm/v3/pkg/chart"
+	"helm.sh/helm/v3/pkg/release"
+)
+
+func TestReleaseChanges(t *testing.T) {
+	configMap := func(name, data string) string {
+		return "---\n# Source: tobs/templates/" + name + ".yaml\napiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: " + name + "\ndata:\n  value: " + data + "\n"
+	}
+	from := &release.Release{
+		Chart:    &chart.Chart{Metadata: &chart.Metadata{Name: "tobs", Version: "0.2.0"}},
+		Config:   map[string]interface{}{"cli": true, "promscale": map[string]interface{}{"image": "timescale/promscale:0.1.1"}, "grafana": map[string]interface{}{"timescale": map[string]interface{}{"database": map[string]interface{}{"pass": "hunter2"}}}},
+		Manifest: configMap("a", "1") + configMap("b", "1"),
+	}
+	to := &release.Release{
+		Chart:    &chart.Chart{Metadata: &chart.Metadata{Name: "tobs", Version: "0.1.0"}},
+		Config:   map[string]interface{}{"promscale": map[string]interface{}{"image": "timescale/promscale:0.1.0", "connection": map[string]interface{}{"password": map[string]interface{}{"secretTemplate": "gg-creds"}}}, "grafana": map[string]interface{}{"enabled": false, "timescale": map[string]interface{}{"database": map[string]interface{}{"pass": "letmein"}}}},
+		Manifest: configMap("a", "2") + configMap("c", "1"),
+	}
+
+	changes, err := releaseChanges(from, to)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{
+		"chart: tobs-0.2.0 -> tobs-0.1.0",
+		"value cli: unset, was true",
+		"value grafana.enabled: set to false",
+		"value grafana.timescale.database.pass: *** (before) -> *** (after)",
+		"value promscale.connection.password.secretTemplate: set to ***",
+		"value promscale.image: timescale/promscale:0.1.1 -> timescale/promscale:0.1.0",
+		"ConfigMap/a: changed",
+		"ConfigMap/b: deleted",
+		"ConfigMap/c: created",
+	}
+	if !reflect.DeepEqual(changes, expected) {
+		t.Fatalf("expected %q, got %q", expected, changes)
+	}
+
+	for _, change := range changes {
+		if strings.Contains(change, "hunter2") || strings.Contains(change, "letmein") {
+			t.Fatalf("expected passwords to be masked, got %q", change)
+		}
+	}
+
+	changes, err = releaseChanges(from, from)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(changes) != 0 {
+		t.Fatalf("expected no changes, got %q", changes)
+	}
+}
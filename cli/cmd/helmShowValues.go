@@ -2,7 +2,6 @@ package cmd
 
 import (
 	"fmt"
-	"os/exec"
 
 	"github.com/spf13/cobra"
 )
@@ -17,25 +16,30 @@ var helmShowValuesCmd = &cobra.Command{
 
 func init() {
 	helmCmd.AddCommand(helmShowValuesCmd)
+	addHelmChartFlags(helmShowValuesCmd)
 }
 
 func helmShowValues(cmd *cobra.Command, args []string) error {
 	var err error
 
-	var showvalues *exec.Cmd
-	if DEVEL {
-		showvalues = exec.Command("helm", "show", "values", "timescale/tobs", "--devel")
-	} else {
-		showvalues = exec.Command("helm", "show", "values", "timescale/tobs")
+	var source *ChartSource
+	source, err = getChartSource(cmd)
+	if err != nil {
+		return fmt.Errorf("could not get Helm values: %w", err)
+	}
+
+	ctx := cmd.Context()
+	helm, err := getHelmClient()
+	if err != nil {
+		return fmt.Errorf("could not get Helm values: %w", err)
 	}
 
-	var out []byte
-	out, err = showvalues.CombinedOutput()
+	out, err := helm.HelmShowValues(ctx, source)
 	if err != nil {
 		return fmt.Errorf("could not get Helm values: %w", err)
 	}
 
-	fmt.Print(string(out))
+	fmt.Print(out)
 
 	return nil
 }
@@ -0,0 +1,236 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"helm.sh/helm/v3/pkg/cli/values"
+	"helm.sh/helm/v3/pkg/releaseutil"
+)
+
+// helmTemplateCmd represents the helm template command
+var helmTemplateCmd = &cobra.Command{
+	Use:   "template",
+	Short: "Renders the Kubernetes manifests of The Observability Stack without installing it",
+	Args:  cobra.ExactArgs(0),
+	RunE:  helmTemplate,
+}
+
+// componentCharts maps the subcharts whose name differs from the component
+// they deploy.
+var componentCharts = map[string]string{
+	"timescaledb-single": "timescaledb",
+}
+
+func init() {
+	helmCmd.AddCommand(helmTemplateCmd)
+	addHelmValuesFlags(helmTemplateCmd)
+	addHelmChartFlags(helmTemplateCmd)
+	helmTemplateCmd.Flags().StringP("output-dir", "", "", "Write one manifest per component into this directory instead of printing them")
+	helmTemplateCmd.Flags().BoolP("include-namespace", "", false, "Include the namespace in the manifests")
+	helmTemplateCmd.Flags().BoolP("dependency-update", "", false, "Download the dependencies of a local chart before rendering it")
+}
+
+func helmTemplate(cmd *cobra.Command, args []string) error {
+	var err error
+
+	var valuesOptions *values.Options
+	valuesOptions, err = getHelmValuesOptions(cmd)
+	if err != nil {
+		return fmt.Errorf("could not render The Observability Stack: %w", err)
+	}
+
+	var source *ChartSource
+	source, err = getChartSource(cmd)
+	if err != nil {
+		return fmt.Errorf("could not render The Observability Stack: %w", err)
+	}
+
+	var outputDir string
+	outputDir, err = cmd.Flags().GetString("output-dir")
+	if err != nil {
+		return fmt.Errorf("could not render The Observability Stack: %w", err)
+	}
+
+	var includeNamespace, dependencyUpdate bool
+	includeNamespace, err = cmd.Flags().GetBool("include-namespace")
+	if err != nil {
+		return fmt.Errorf("could not render The Observability Stack: %w", err)
+	}
+	dependencyUpdate, err = cmd.Flags().GetBool("dependency-update")
+	if err != nil {
+		return fmt.Errorf("could not render The Observability Stack: %w", err)
+	}
+
+	ctx := cmd.Context()
+	helm, err := getHelmClient()
+	if err != nil {
+		return fmt.Errorf("could not render The Observability Stack: %w", err)
+	}
+
+	if dependencyUpdate {
+		path, err := helm.HelmLocateChart(ctx, source)
+		if err != nil {
+			return fmt.Errorf("could not render The Observability Stack: %w", err)
+		}
+		err = helm.HelmDependencyUpdate(ctx, path)
+		if err != nil {
+			return fmt.Errorf("could not render The Observability Stack: %w", err)
+		}
+	}
+
+	chart, err := helm.HelmLoadChart(ctx, source)
+	if err != nil {
+		return fmt.Errorf("could not render The Observability Stack: %w", err)
+	}
+
+	vals, err := helm.HelmMergeValues(valuesOptions)
+	if err != nil {
+		return fmt.Errorf("could not render The Observability Stack: %w", err)
+	}
+
+	release, err := helm.HelmTemplate(ctx, name, chart, vals)
+	if err != nil {
+		return fmt.Errorf("could not render The Observability Stack: %w", err)
+	}
+
+	if outputDir == "" {
+		if includeNamespace {
+			fmt.Println(namespaceManifest())
+		}
+		fmt.Print(release.Manifest)
+		return nil
+	}
+
+	manifests, err := splitManifestByComponent(release.Manifest)
+	if err != nil {
+		return fmt.Errorf("could not render The Observability Stack: %w", err)
+	}
+	if includeNamespace {
+		manifests["namespace"] = namespaceManifest()
+	}
+
+	err = writeManifests(os.Stdout, outputDir, manifests)
+	if err != nil {
+		return fmt.Errorf("could not render The Observability Stack: %w", err)
+	}
+
+	return nil
+}
+
+// namespaceManifest returns the manifest of the namespace the release is
+// rendered for.
+func namespaceManifest() string {
+	return fmt.Sprintf(`apiVersion: v1
+kind: Namespace
+metadata:
+  name: %v
+  labels:
+    app.kubernetes.io/name: %v
+    app.kubernetes.io/instance: %v
+`, namespace, name, name)
+}
+
+// splitManifestByComponent groups the documents of a rendered manifest by the
+// stack component their template belongs to, keeping their order.
+func splitManifestByComponent(manifest string) (map[string]string, error) {
+	components, err := stackComponents(name, namespace)
+	if err != nil {
+		return nil, err
+	}
+	var ids []string
+	for _, component := range components {
+		ids = append(ids, component.ID)
+	}
+
+	docs := releaseutil.SplitManifests(manifest)
+	keys := make([]string, 0, len(docs))
+	for key := range docs {
+		keys = append(keys, key)
+	}
+	sort.Sort(releaseutil.BySplitManifestsOrder(keys))
+
+	manifests := make(map[string]string)
+	for _, key := range keys {
+		doc := strings.TrimSpace(docs[key])
+		if doc == "" {
+			continue
+		}
+		id := componentForSource(manifestSource(doc), ids)
+		manifests[id] += "---\n" + doc + "\n"
+	}
+	return manifests, nil
+}
+
+// manifestSource returns the template path of the "# Source:" comment Helm
+// puts at the top of every rendered document.
+func manifestSource(doc string) string {
+	for _, line := range strings.Split(doc, "\n") {
+		if strings.HasPrefix(line, "# Source: ") {
+			return strings.TrimPrefix(line, "# Source: ")
+		}
+	}
+	return ""
+}
+
+// componentForSource returns the component a template belongs to: the
+// longest component ID its file name starts with, e.g. node-exporter for the
+// node-exporter templates of the prometheus chart, or else the innermost
+// (sub)chart containing it.
+func componentForSource(source string, ids []string) string {
+	parts := strings.Split(source, "/")
+	base := strings.TrimSuffix(parts[len(parts)-1], filepath.Ext(parts[len(parts)-1]))
+
+	match := ""
+	for _, id := range ids {
+		if (base == id || strings.HasPrefix(base, id+"-")) && len(id) > len(match) {
+			match = id
+		}
+	}
+	if match != "" {
+		return match
+	}
+
+	chart := parts[0]
+	for i := len(parts) - 2; i >= 0; i-- {
+		if parts[i] == "charts" {
+			chart = parts[i+1]
+			break
+		}
+	}
+	if id, exists := componentCharts[chart]; exists {
+		return id
+	}
+	return chart
+}
+
+// writeManifests writes every manifest to <dir>/<component>.yaml and lists the
+// written files.
+func writeManifests(out io.Writer, dir string, manifests map[string]string) error {
+	err := os.MkdirAll(dir, 0755)
+	if err != nil {
+		return err
+	}
+
+	ids := make([]string, 0, len(manifests))
+	for id := range manifests {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	for _, id := range ids {
+		path := filepath.Join(dir, id+".yaml")
+		err = ioutil.WriteFile(path, []byte(manifests[id]), 0644)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(out, "wrote "+path)
+	}
+	return nil
+}
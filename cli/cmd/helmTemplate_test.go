@@ -0,0 +1,117 @@
+package cmd
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"helm.sh/helm/v3/pkg/chartutil"
+	"sigs.k8s.io/yaml"
+)
+
+func TestComponentForSource(t *testing.T) {
+	ids := []string{"timescaledb", "promscale", "prometheus", "grafana", "promlens", "grafana-db", "node-exporter", "kube-state-metrics"}
+
+	for source, expected := range map[string]string{
+		"tobs/charts/timescaledb-single/templates/statefulset-timescaledb.yaml":   "timescaledb",
+		"tobs/charts/timescaledb-single/templates/svc-prometheus.yaml":            "timescaledb",
+		"tobs/charts/promscale/templates/deployment-connector.yaml":               "promscale",
+		"tobs/charts/prometheus/templates/server-deployment.yaml":                 "prometheus",
+		"tobs/charts/prometheus/templates/node-exporter-daemonset.yaml":           "node-exporter",
+		"tobs/charts/prometheus/charts/kube-state-metrics/templates/service.yaml": "kube-state-metrics",
+		"tobs/charts/grafana/templates/tests/test.yaml":                           "grafana",
+		"tobs/templates/grafana-datasources-sec.yaml":                             "grafana",
+		"tobs/templates/grafana-db-user-job.yaml":                                 "grafana-db",
+		"tobs/templates/prometheus-conf.yaml":                                     "prometheus",
+		"tobs/templates/promlens-svc.yaml":                                        "promlens",
+		"tobs/templates/other.yaml":                                               "tobs",
+	} {
+		if id := componentForSource(source, ids); id != expected {
+			t.Errorf("expected %v to belong to %v, got %v", source, expected, id)
+		}
+	}
+}
+
+func TestSplitManifestByComponent(t *testing.T) {
+	name, namespace = "gg", "ns"
+	defer func() { name, namespace = "", "" }()
+
+	manifest := `---
+# Source: tobs/charts/grafana/templates/service.yaml
+kind: Service
+---
+# Source: tobs/templates/grafana-db-user-job.yaml
+kind: Job
+---
+# Source: tobs/charts/grafana/templates/deployment.yaml
+kind: Deployment
+`
+	manifests, err := splitManifestByComponent(manifest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(manifests) != 2 {
+		t.Fatalf("expected 2 components, got %v", manifests)
+	}
+	expected := "---\n# Source: tobs/charts/grafana/templates/service.yaml\nkind: Service\n---\n# Source: tobs/charts/grafana/templates/deployment.yaml\nkind: Deployment\n"
+	if manifests["grafana"] != expected {
+		t.Fatalf("expected the grafana documents in order, got:\n%v", manifests["grafana"])
+	}
+}
+
+// TestHelmTemplateStdout checks that nothing but the manifests goes to
+// stdout, which scripts redirect into a file to apply.
+func TestHelmTemplateStdout(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tobs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	err = chartutil.SaveDir(testChart(), dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	config := filepath.Join(dir, ".tobs.yaml")
+	err = ioutil.WriteFile(config, []byte("kube:\n  context: test\n"), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	helmClient = newMemoryHelmClient()
+	defer func() { helmClient = nil }()
+	oldCfgFile := cfgFile
+	defer func() { cfgFile = oldCfgFile }()
+
+	rootCmd.SetArgs([]string{"--config", config, "helm", "template", "-n", "gg", "--namespace", "ns", "--include-namespace", "--chart-reference", filepath.Join(dir, "tobs")})
+	defer rootCmd.SetArgs(nil)
+
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout := os.Stdout
+	os.Stdout = w
+	err = rootCmd.ExecuteContext(context.Background())
+	os.Stdout = stdout
+	w.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	out, err := ioutil.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(string(out), "apiVersion: v1\nkind: Namespace\n") {
+		t.Fatalf("expected the output to start with the namespace, got\n%v", string(out))
+	}
+	for _, doc := range strings.Split(string(out), "\n---\n") {
+		var object map[string]interface{}
+		if err = yaml.Unmarshal([]byte(doc), &object); err != nil || object["kind"] == nil {
+			t.Fatalf("expected only manifests on stdout, got %q: %v", doc, err)
+		}
+	}
+}
@@ -1,11 +1,7 @@
 package cmd
 
 import (
-	"bytes"
 	"fmt"
-	"io"
-	"os"
-	"os/exec"
 	"time"
 
 	"github.com/spf13/cobra"
@@ -33,61 +29,68 @@ func helmUninstall(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("could not uninstall The Observability Stack: %w", err)
 	}
 
-	var stdbuf bytes.Buffer
-	mw := io.MultiWriter(os.Stdout, &stdbuf)
+	ctx := cmd.Context()
+	client, err := getKubeClient()
+	if err != nil {
+		return fmt.Errorf("could not uninstall The Observability Stack: %w", err)
+	}
 
-	var uninstall *exec.Cmd
-	if namespace == "default" {
-		uninstall = exec.Command("helm", "uninstall", name)
-	} else {
-		uninstall = exec.Command("helm", "uninstall", name, "--namespace", namespace)
+	helm, err := getHelmClient()
+	if err != nil {
+		return fmt.Errorf("could not uninstall The Observability Stack: %w", err)
 	}
 
-	uninstall.Stdout = mw
-	uninstall.Stderr = mw
 	fmt.Println("Uninstalling The Observability Stack")
-	err = uninstall.Run()
+	res, err := helm.HelmUninstall(ctx, name)
 	if err != nil {
 		return fmt.Errorf("could not uninstall The Observability Stack: %w", err)
 	}
+	if res.Info != "" {
+		fmt.Println(res.Info)
+	}
+	fmt.Printf("release \"%v\" uninstalled\n", name)
 
 	fmt.Println("Waiting for pods to terminate...")
 	for i := 0; i < 1000; i++ {
-		pods, err := KubeGetAllPods(namespace, name)
+		pods, err := client.KubeGetAllPods(ctx, namespace, name)
 		if err != nil {
-		    return fmt.Errorf("could not uninstall The Observability Stack: %w", err)
+			return fmt.Errorf("could not uninstall The Observability Stack: %w", err)
 		}
 		if len(pods) == 0 {
 			break
 		} else if i == 999 {
 			fmt.Println("WARNING: pods did not terminate in 100 seconds")
 		}
-		time.Sleep(100 * time.Millisecond)
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("could not uninstall The Observability Stack: %w", ctx.Err())
+		case <-time.After(100 * time.Millisecond):
+		}
 	}
 
 	fmt.Println("Deleting remaining artifacts")
-	err = KubeDeleteService(namespace, name+"-config")
+	err = client.KubeDeleteService(ctx, namespace, name+"-config")
 	if err != nil {
 		fmt.Println(err, ", skipping")
 	}
 
-	err = KubeDeleteEndpoint(namespace, name)
+	err = client.KubeDeleteEndpoint(ctx, namespace, name)
 	if err != nil {
 		fmt.Println(err, ", skipping")
 	}
 
 	if deleteData {
 		fmt.Println("Checking Persistent Volume Claims")
-		pvcnames, err := KubeGetPVCNames(namespace, map[string]string{"release": name})
+		pvcnames, err := client.KubeGetAllPVCNames(ctx, namespace, name)
 		if err != nil {
-		    return fmt.Errorf("could not uninstall The Observability Stack: %w", err)
+			return fmt.Errorf("could not uninstall The Observability Stack: %w", err)
 		}
 
 		fmt.Println("Removing Persistent Volume Claims")
 		for _, s := range pvcnames {
-			err = KubeDeletePVC(namespace, s)
+			err = client.KubeDeletePVC(ctx, namespace, s)
 			if err != nil {
-		        return fmt.Errorf("could not uninstall The Observability Stack: %w", err)
+				return fmt.Errorf("could not uninstall The Observability Stack: %w", err)
 			}
 		}
 	} else {
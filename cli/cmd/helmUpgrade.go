@@ -0,0 +1,155 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/spf13/cobra"
+	"helm.sh/helm/v3/pkg/chartutil"
+	"helm.sh/helm/v3/pkg/cli/values"
+	"helm.sh/helm/v3/pkg/release"
+)
+
+// helmUpgradeCmd represents the helm upgrade command
+var helmUpgradeCmd = &cobra.Command{
+	Use:   "upgrade",
+	Short: "Upgrades The Observability Stack",
+	Args:  cobra.ExactArgs(0),
+	RunE:  helmUpgrade,
+}
+
+func init() {
+	helmCmd.AddCommand(helmUpgradeCmd)
+	addHelmUpgradeFlags(helmUpgradeCmd)
+}
+
+func addHelmUpgradeFlags(cmd *cobra.Command) {
+	addHelmValuesFlags(cmd)
+	addHelmChartFlags(cmd)
+	cmd.Flags().BoolP("reuse-values", "", false, "Reuse the values of the current release and merge in overrides")
+	cmd.Flags().BoolP("skip-checks", "", false, "Upgrade even if the pre-upgrade checks fail")
+	cmd.Flags().DurationP("wait-timeout", "", DEFAULT_TIMEOUT, "How long to wait for all pods to become ready")
+}
+
+func helmUpgrade(cmd *cobra.Command, args []string) error {
+	var err error
+
+	var valuesOptions *values.Options
+	valuesOptions, err = getHelmValuesOptions(cmd)
+	if err != nil {
+		return fmt.Errorf("could not upgrade The Observability Stack: %w", err)
+	}
+
+	var source *ChartSource
+	source, err = getChartSource(cmd)
+	if err != nil {
+		return fmt.Errorf("could not upgrade The Observability Stack: %w", err)
+	}
+
+	var reuseValues, skipChecks bool
+	reuseValues, err = cmd.Flags().GetBool("reuse-values")
+	if err != nil {
+		return fmt.Errorf("could not upgrade The Observability Stack: %w", err)
+	}
+	skipChecks, err = cmd.Flags().GetBool("skip-checks")
+	if err != nil {
+		return fmt.Errorf("could not upgrade The Observability Stack: %w", err)
+	}
+
+	var waitTimeout time.Duration
+	waitTimeout, err = cmd.Flags().GetDuration("wait-timeout")
+	if err != nil {
+		return fmt.Errorf("could not upgrade The Observability Stack: %w", err)
+	}
+
+	ctx := cmd.Context()
+	client, err := getKubeClient()
+	if err != nil {
+		return fmt.Errorf("could not upgrade The Observability Stack: %w", err)
+	}
+
+	helm, err := getHelmClient()
+	if err != nil {
+		return fmt.Errorf("could not upgrade The Observability Stack: %w", err)
+	}
+
+	current, err := helm.HelmStatus(ctx, name)
+	if errors.Is(err, ErrReleaseNotFound) {
+		return fmt.Errorf("could not upgrade The Observability Stack: %w, use tobs install to install it", err)
+	}
+	if err != nil {
+		return fmt.Errorf("could not upgrade The Observability Stack: %w", err)
+	}
+
+	chart, err := helm.HelmLoadChart(ctx, source)
+	if err != nil {
+		return fmt.Errorf("could not upgrade The Observability Stack: %w", err)
+	}
+
+	valuesOptions.Values = append([]string{"cli=true"}, valuesOptions.Values...)
+	vals, err := helm.HelmMergeValues(valuesOptions)
+	if err != nil {
+		return fmt.Errorf("could not upgrade The Observability Stack: %w", err)
+	}
+
+	vals = upgradeValues(vals, current, reuseValues)
+	err = checkValues(chart, vals)
+	if err != nil {
+		return fmt.Errorf("could not upgrade The Observability Stack: %w", err)
+	}
+
+	planned, err := helm.HelmUpgrade(ctx, name, chart, vals, false, true)
+	if err != nil {
+		return fmt.Errorf("could not upgrade The Observability Stack: %w", err)
+	}
+
+	fmt.Printf("Checking the upgrade from chart %v to %v\n", current.Chart.Metadata.Version, planned.Chart.Metadata.Version)
+	extVersion, err := getTimescaleDBExtVersion(ctx, client)
+	if err != nil {
+		fmt.Printf("Skipping the TimescaleDB extension checks, the installed version is unknown: %v\n", err)
+	}
+	checks, err := runUpgradeChecks(current, planned, extVersion)
+	if err != nil {
+		return fmt.Errorf("could not upgrade The Observability Stack: %w", err)
+	}
+
+	failed := 0
+	for _, check := range checks {
+		if check.Err != nil {
+			fmt.Printf("  %v: %v\n", check.Name, check.Err)
+			failed++
+		} else {
+			fmt.Printf("  %v: ok\n", check.Name)
+		}
+	}
+	if failed != 0 && !skipChecks {
+		return fmt.Errorf("could not upgrade The Observability Stack: %d pre-upgrade checks failed, use --skip-checks to upgrade anyway", failed)
+	}
+
+	fmt.Println("Upgrading The Observability Stack")
+	release, err := helm.HelmUpgrade(ctx, name, chart, vals, false, false)
+	if err != nil {
+		return fmt.Errorf("could not upgrade The Observability Stack: %w", err)
+	}
+
+	fmt.Println("Waiting for pods to become ready...")
+	_, err = waitForStack(ctx, client, waitTimeout)
+	if err != nil {
+		return fmt.Errorf("could not upgrade The Observability Stack: %w", err)
+	}
+
+	fmt.Printf("The Observability Stack has been upgraded to revision %d\n", release.Version)
+	return nil
+}
+
+// upgradeValues returns the values to upgrade a release with, merging the
+// overrides into the values of the current release when reusing them. The
+// result already contains the reused values, so Helm must not merge them a
+// second time: that would bring back keys the overrides removed with null.
+func upgradeValues(vals map[string]interface{}, current *release.Release, reuseValues bool) map[string]interface{} {
+	if !reuseValues {
+		return vals
+	}
+	return chartutil.CoalesceTables(vals, current.Config)
+}
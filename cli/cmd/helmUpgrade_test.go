@@ -0,0 +1,39 @@
+package cmd
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestUpgradeValues(t *testing.T) {
+	ctx := context.Background()
+	helm := newMemoryHelmClient()
+
+	_, err := helm.HelmInstall(ctx, "gg", testChart(), map[string]interface{}{"cli": true, "extra": "kept"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	current, err := helm.HelmStatus(ctx, "gg")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Removing the override with null falls back to the chart default.
+	vals := upgradeValues(map[string]interface{}{"cli": nil}, current, true)
+	if _, exists := vals["cli"]; exists || vals["extra"] != "kept" {
+		t.Fatalf("expected cli to be removed and extra to be reused, got %v", vals)
+	}
+	planned, err := helm.HelmUpgrade(ctx, "gg", testChart(), vals, false, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(planned.Manifest, `cli: "false"`) {
+		t.Fatalf("expected the removed override to fall back to the chart default:\n%v", planned.Manifest)
+	}
+
+	vals = upgradeValues(map[string]interface{}{"cli": false}, current, false)
+	if len(vals) != 1 || vals["cli"] != false {
+		t.Fatalf("expected only the overrides without reusing values, got %v", vals)
+	}
+}
@@ -0,0 +1,179 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/xeipuuv/gojsonschema"
+	"helm.sh/helm/v3/pkg/chart"
+	"helm.sh/helm/v3/pkg/chartutil"
+	"helm.sh/helm/v3/pkg/cli/values"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+// helmValidateValuesCmd represents the helm validate-values command
+var helmValidateValuesCmd = &cobra.Command{
+	Use:   "validate-values",
+	Short: "Checks values files against the schema of the Helm chart for The Observability Stack",
+	Args:  cobra.ExactArgs(0),
+	RunE:  helmValidateValues,
+}
+
+func init() {
+	helmCmd.AddCommand(helmValidateValuesCmd)
+	addHelmValuesFlags(helmValidateValuesCmd)
+	addHelmChartFlags(helmValidateValuesCmd)
+}
+
+func helmValidateValues(cmd *cobra.Command, args []string) error {
+	var err error
+
+	var valuesOptions *values.Options
+	valuesOptions, err = getHelmValuesOptions(cmd)
+	if err != nil {
+		return fmt.Errorf("could not validate values: %w", err)
+	}
+
+	var source *ChartSource
+	source, err = getChartSource(cmd)
+	if err != nil {
+		return fmt.Errorf("could not validate values: %w", err)
+	}
+
+	ctx := cmd.Context()
+	helm, err := getHelmClient()
+	if err != nil {
+		return fmt.Errorf("could not validate values: %w", err)
+	}
+
+	chart, err := helm.HelmLoadChart(ctx, source)
+	if err != nil {
+		return fmt.Errorf("could not validate values: %w", err)
+	}
+	if chart.Schema == nil {
+		fmt.Printf("Chart %v-%v has no values schema, only checking the values against each other\n", chart.Metadata.Name, chart.Metadata.Version)
+	}
+
+	vals, err := helm.HelmMergeValues(valuesOptions)
+	if err != nil {
+		return fmt.Errorf("could not validate values: %w", err)
+	}
+
+	err = checkValues(chart, vals)
+	if err != nil {
+		return fmt.Errorf("could not validate values: %w", err)
+	}
+
+	fmt.Println("The values are valid")
+	return nil
+}
+
+// checkValues validates vals and prints the problems found, if any.
+func checkValues(chrt *chart.Chart, vals map[string]interface{}) error {
+	problems, err := validateValues(chrt, vals)
+	if err != nil {
+		return err
+	}
+	if len(problems) == 0 {
+		return nil
+	}
+
+	fmt.Println("The values have the following problems:")
+	for _, problem := range problems {
+		fmt.Println("  - " + problem)
+	}
+	return fmt.Errorf("%d problems found in the values", len(problems))
+}
+
+// validateValues checks the values, merged with the chart defaults like Helm
+// merges them, against the schema of the chart and against each other.
+func validateValues(chrt *chart.Chart, vals map[string]interface{}) ([]string, error) {
+	coalesced, err := chartutil.CoalesceValues(chrt, vals)
+	if err != nil {
+		return nil, err
+	}
+
+	var problems []string
+	if chrt.Schema != nil {
+		problems, err = schemaProblems(chrt.Schema, coalesced)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return append(problems, crossFieldProblems(coalesced)...), nil
+}
+
+// schemaProblems returns the violations of a JSON schema, e.g. unknown keys
+// and values of the wrong type.
+func schemaProblems(schema []byte, vals chartutil.Values) ([]string, error) {
+	valuesJSON, err := json.Marshal(vals)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := gojsonschema.Validate(gojsonschema.NewBytesLoader(schema), gojsonschema.NewBytesLoader(valuesJSON))
+	if err != nil {
+		return nil, fmt.Errorf("could not load the values schema: %w", err)
+	}
+
+	var problems []string
+	for _, desc := range result.Errors() {
+		problems = append(problems, desc.Field()+": "+desc.Description())
+	}
+	return problems, nil
+}
+
+// crossFieldProblems returns settings that are valid on their own but do not
+// work together.
+func crossFieldProblems(vals chartutil.Values) []string {
+	var problems []string
+
+	enabled := func(path string) bool {
+		value, err := vals.PathValue(path)
+		b, ok := value.(bool)
+		return err == nil && ok && b
+	}
+	str := func(path string) string {
+		value, err := vals.PathValue(path)
+		if err != nil || value == nil {
+			return ""
+		}
+		return fmt.Sprint(value)
+	}
+
+	if !enabled("promscale.enabled") {
+		if enabled("promlens.enabled") {
+			problems = append(problems, "promlens.enabled: PromLens queries Promscale, which is disabled by promscale.enabled")
+		}
+		if enabled("prometheus.enabled") && enabled("prometheus.server.timescaleRemote.write.enabled") {
+			problems = append(problems, "prometheus.server.timescaleRemote.write.enabled: Prometheus writes to Promscale, which is disabled by promscale.enabled")
+		}
+		if enabled("prometheus.enabled") && enabled("prometheus.server.timescaleRemote.read.enabled") {
+			problems = append(problems, "prometheus.server.timescaleRemote.read.enabled: Prometheus reads from Promscale, which is disabled by promscale.enabled")
+		}
+		if enabled("grafana.enabled") && enabled("grafana.prometheus.datasource.enabled") && strings.Contains(str("grafana.prometheus.datasource.url"), "promscale") {
+			problems = append(problems, "grafana.prometheus.datasource.url: the Grafana data source points to Promscale, which is disabled by promscale.enabled")
+		}
+	}
+
+	if enabled("promscale.enabled") && enabled("grafana.enabled") && enabled("grafana.timescale.datasource.enabled") {
+		if metricsDB, datasourceDB := str("promscale.connection.dbName"), str("grafana.timescale.datasource.dbName"); metricsDB != "" && datasourceDB != "" && metricsDB != datasourceDB {
+			problems = append(problems, fmt.Sprintf("grafana.timescale.datasource.dbName: the Grafana data source reads database %v, but Promscale writes to %v", datasourceDB, metricsDB))
+		}
+	}
+
+	if enabled("timescaledb-single.enabled") {
+		for _, path := range []string{"timescaledb-single.persistentVolumes.data.size", "timescaledb-single.persistentVolumes.wal.size"} {
+			if size := str(path); size != "" {
+				if _, err := resource.ParseQuantity(size); err != nil {
+					problems = append(problems, fmt.Sprintf("%v: %v is not a valid size, e.g. 150Gi", path, size))
+				}
+			}
+		}
+	}
+
+	return problems
+}
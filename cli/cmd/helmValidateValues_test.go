@@ -0,0 +1,93 @@
+package cmd
+
+import (
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"helm.sh/helm/v3/pkg/chart"
+	"helm.sh/helm/v3/pkg/chartutil"
+)
+
+// loadTobsChart returns the tobs chart of this repository without its
+// dependencies, which are not vendored.
+func loadTobsChart(t *testing.T) *chart.Chart {
+	values, err := chartutil.ReadValuesFile("../../chart/values.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	schema, err := ioutil.ReadFile("../../chart/values.schema.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &chart.Chart{
+		Metadata: &chart.Metadata{APIVersion: "v2", Name: "tobs", Version: "0.1.0"},
+		Values:   values,
+		Schema:   schema,
+	}
+}
+
+func TestValidateValues(t *testing.T) {
+	chrt := loadTobsChart(t)
+
+	problems, err := validateValues(chrt, map[string]interface{}{"cli": true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(problems) != 0 {
+		t.Fatalf("expected the chart defaults to be valid, got %q", problems)
+	}
+
+	// Sections this chart does not define are left to the sub-charts.
+	for _, values := range []string{
+		"timescaledb-single:\n  patroni:\n    log:\n      level: DEBUG\n",
+		"grafana:\n  adminUser: admin\n",
+		"tags:\n  monitoring: true\n",
+	} {
+		vals, err := chartutil.ReadValues([]byte(values))
+		if err != nil {
+			t.Fatal(err)
+		}
+		problems, err = validateValues(chrt, vals)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(problems) != 0 {
+			t.Errorf("expected values to be accepted for\n%v\ngot %q", values, problems)
+		}
+	}
+
+	for _, test := range []struct {
+		values   string
+		expected string
+	}{
+		{"timescaledb-single:\n  persistentVolumes:\n    data:\n      sise: 100Gi\n", "timescaledb-single.persistentVolumes.data: Additional property sise is not allowed"},
+		{"promscale:\n  connection:\n    usr: metrics\n", "promscale.connection: Additional property usr is not allowed"},
+		{"grafana:\n  timescale:\n    database:\n      usr: grafanadb\n", "grafana.timescale.database: Additional property usr is not allowed"},
+		{"promlens:\n  loadBalancer:\n    enable: true\n", "promlens.loadBalancer: Additional property enable is not allowed"},
+		{"prometheus:\n  server:\n    timescaleRemote:\n      write:\n        queue:\n          max_shard: 10\n", "prometheus.server.timescaleRemote.write.queue: Additional property max_shard is not allowed"},
+		{"promscal:\n  enabled: false\n", "(root): Additional property promscal is not allowed"},
+		{"timescaledb-single:\n  replicaCount: three\n", "timescaledb-single.replicaCount: Invalid type"},
+		{"timescaledb-single:\n  persistentVolumes:\n    data:\n      size: 150 GB\n", "timescaledb-single.persistentVolumes.data.size: 150 GB is not a valid size"},
+		{"promscale:\n  enabled: false\nprometheus:\n  enabled: false\ngrafana:\n  enabled: false\n", "promlens.enabled: PromLens queries Promscale"},
+		{"promscale:\n  enabled: false\npromlens:\n  enabled: false\n", "prometheus.server.timescaleRemote.write.enabled: Prometheus writes to Promscale"},
+		{"grafana:\n  timescale:\n    datasource:\n      dbName: metrics\n", "grafana.timescale.datasource.dbName: the Grafana data source reads database metrics, but Promscale writes to postgres"},
+	} {
+		vals, err := chartutil.ReadValues([]byte(test.values))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		problems, err := validateValues(chrt, vals)
+		if err != nil {
+			t.Fatal(err)
+		}
+		found := false
+		for _, problem := range problems {
+			found = found || strings.HasPrefix(problem, test.expected)
+		}
+		if !found {
+			t.Errorf("expected a problem starting with %q for\n%v\ngot %q", test.expected, test.values, problems)
+		}
+	}
+}
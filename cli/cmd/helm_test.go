@@ -0,0 +1,50 @@
+package cmd
+
+import (
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+func TestGetChartSource(t *testing.T) {
+	defer viper.Reset()
+
+	cmd := &cobra.Command{}
+	addHelmChartFlags(cmd)
+	source, err := getChartSource(cmd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if source.Ref != "timescale/tobs" || source.RepoName != REPO_NAME || source.RepoURL != REPO_LOCATION || source.Devel {
+		t.Fatalf("expected the flag defaults, got %+v", source)
+	}
+
+	viper.Set("chart.reference", "mirror/tobs")
+	viper.Set("chart.devel", true)
+	viper.Set("chart.repository.name", "mirror")
+	viper.Set("chart.repository.url", "https://charts.example.com")
+	viper.Set("chart.repository.insecureSkipTLSVerify", true)
+	err = cmd.Flags().Parse([]string{"--repo-url", "https://mirror.example.com", "--version", "0.1.2"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	source, err = getChartSource(cmd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := ChartSource{
+		Ref:                   "mirror/tobs",
+		Version:               "0.1.2",
+		Devel:                 true,
+		RepoName:              "mirror",
+		RepoURL:               "https://mirror.example.com",
+		InsecureSkipTLSVerify: true,
+	}
+	if *source != expected {
+		t.Fatalf("expected %+v, got %+v", expected, *source)
+	}
+	if source.version() != "0.1.2" {
+		t.Fatalf("expected the pinned version to win over --devel, got %v", source.version())
+	}
+}
@@ -2,75 +2,145 @@ package cmd
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io"
-	"log"
 	"net/http"
 	"os"
-	"time"
+	"sort"
+	"sync"
 
+	batchv1 "k8s.io/api/batch/v1"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
 	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/apimachinery/pkg/watch"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/kubernetes/scheme"
 	_ "k8s.io/client-go/plugin/pkg/client/auth"
 	rest "k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/cache"
 	"k8s.io/client-go/tools/clientcmd"
+	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
 	"k8s.io/client-go/tools/portforward"
 	"k8s.io/client-go/tools/remotecommand"
+	watchtools "k8s.io/client-go/tools/watch"
 	"k8s.io/client-go/transport/spdy"
 )
 
-var HOME = os.Getenv("HOME")
+// KubeClient is the Kubernetes client shared by every helper a command calls.
+// It embeds kubernetes.Interface so that client-go's fake clientset can stand
+// in for a real cluster in tests.
+type KubeClient struct {
+	kubernetes.Interface
+	config *rest.Config
+}
+
+// kubeClient is the client of the running command, built on first use by
+// getKubeClient. Tests may set it directly.
+var kubeClient *KubeClient
+
+// NewKubeClient wraps an existing clientset. config is only needed for
+// streaming operations (exec and port-forward) and may be nil otherwise.
+func NewKubeClient(client kubernetes.Interface, config *rest.Config) *KubeClient {
+	return &KubeClient{Interface: client, config: config}
+}
 
-func KubeInit() (kubernetes.Interface, *rest.Config) {
+// KubeInit builds a Kubernetes client using the standard client-go loading
+// rules: $KUBECONFIG (merged if it lists several files), falling back to
+// $HOME/.kube/config. The --kubeconfig, --context and --as/--as-group global
+// flags are applied on top. If no kubeconfig can be found at all, the
+// in-cluster service account configuration is used instead.
+func KubeInit() (*KubeClient, error) {
 	var err error
 
-	config, err := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(
-		&clientcmd.ClientConfigLoadingRules{ExplicitPath: HOME + "/.kube/config"},
-		&clientcmd.ConfigOverrides{}).ClientConfig()
+	config, err := kubeRestConfig()
 	if err != nil {
-		log.Fatal(err)
+		return nil, err
 	}
 
 	client, err := kubernetes.NewForConfig(config)
 	if err != nil {
-		log.Fatal(err)
+		return nil, err
 	}
 
-	return client, config
+	return NewKubeClient(client, config), nil
 }
 
-func KubeGetPodName(namespace string, labelmap map[string]string) (string, error) {
-	var err error
+func getKubeClient() (*KubeClient, error) {
+	if kubeClient == nil {
+		client, err := KubeInit()
+		if err != nil {
+			return nil, fmt.Errorf("could not create Kubernetes client: %w", err)
+		}
+		kubeClient = client
+	}
 
-	client, _ := KubeInit()
+	return kubeClient, nil
+}
+
+func kubeRestConfig() (*rest.Config, error) {
+	rules := clientcmd.NewDefaultClientConfigLoadingRules()
+	if kubeconfig != "" {
+		rules.ExplicitPath = kubeconfig
+	}
+
+	overrides := &clientcmd.ConfigOverrides{
+		CurrentContext: kubeContext,
+		AuthInfo: clientcmdapi.AuthInfo{
+			Impersonate:       kubeAs,
+			ImpersonateGroups: kubeAsGroups,
+		},
+	}
+
+	config, err := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(rules, overrides).ClientConfig()
+	if clientcmd.IsEmptyConfig(err) && kubeconfig == "" && kubeContext == "" {
+		config, err = rest.InClusterConfig()
+		if err != nil {
+			return nil, fmt.Errorf("no kubeconfig found and not running inside a cluster: %w", err)
+		}
+		config.Impersonate = rest.ImpersonationConfig{UserName: kubeAs, Groups: kubeAsGroups}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return config, nil
+}
+
+func (k *KubeClient) KubeGetPodName(ctx context.Context, namespace string, labelmap map[string]string) (string, error) {
+	var err error
 
 	labelSelector := metav1.LabelSelector{MatchLabels: labelmap}
 	listOptions := metav1.ListOptions{
 		LabelSelector: labels.Set(labelSelector.MatchLabels).String(),
 	}
 
-	pods, err := client.CoreV1().Pods(namespace).List(context.Background(), listOptions)
+	pods, err := k.CoreV1().Pods(namespace).List(ctx, listOptions)
 	if err != nil {
 		return "", err
 	}
 
+	if len(pods.Items) < 1 {
+		return "", fmt.Errorf("No such pod found")
+	}
+
 	return pods.Items[0].Name, nil
 }
 
-func KubeGetServiceName(namespace string, labelmap map[string]string) (string, error) {
+func (k *KubeClient) KubeGetServiceName(ctx context.Context, namespace string, labelmap map[string]string) (string, error) {
 	var err error
 
-	client, _ := KubeInit()
-
 	labelSelector := metav1.LabelSelector{MatchLabels: labelmap}
 	listOptions := metav1.ListOptions{
 		LabelSelector: labels.Set(labelSelector.MatchLabels).String(),
 	}
 
-	services, err := client.CoreV1().Services(namespace).List(context.Background(), listOptions)
+	services, err := k.CoreV1().Services(namespace).List(ctx, listOptions)
 	if err != nil {
 		return "", err
 	}
@@ -86,17 +156,15 @@ func KubeGetServiceName(namespace string, labelmap map[string]string) (string, e
 	return services.Items[0].Name, nil
 }
 
-func KubeGetPVCNames(namespace string, labelmap map[string]string) ([]string, error) {
+func (k *KubeClient) KubeGetPVCNames(ctx context.Context, namespace string, labelmap map[string]string) ([]string, error) {
 	var err error
 
-	client, _ := KubeInit()
-
 	labelSelector := metav1.LabelSelector{MatchLabels: labelmap}
 	listOptions := metav1.ListOptions{
 		LabelSelector: labels.Set(labelSelector.MatchLabels).String(),
 	}
 
-	pvcs, err := client.CoreV1().PersistentVolumeClaims(namespace).List(context.Background(), listOptions)
+	pvcs, err := k.CoreV1().PersistentVolumeClaims(namespace).List(ctx, listOptions)
 	if err != nil {
 		return nil, err
 	}
@@ -109,17 +177,15 @@ func KubeGetPVCNames(namespace string, labelmap map[string]string) ([]string, er
 	return names, nil
 }
 
-func KubeGetPods(namespace string, labelmap map[string]string) ([]corev1.Pod, error) {
+func (k *KubeClient) KubeGetPods(ctx context.Context, namespace string, labelmap map[string]string) ([]corev1.Pod, error) {
 	var err error
 
-	client, _ := KubeInit()
-
 	labelSelector := metav1.LabelSelector{MatchLabels: labelmap}
 	listOptions := metav1.ListOptions{
 		LabelSelector: labels.Set(labelSelector.MatchLabels).String(),
 	}
 
-	pods, err := client.CoreV1().Pods(namespace).List(context.Background(), listOptions)
+	pods, err := k.CoreV1().Pods(namespace).List(ctx, listOptions)
 	if err != nil {
 		return nil, err
 	}
@@ -127,12 +193,53 @@ func KubeGetPods(namespace string, labelmap map[string]string) ([]corev1.Pod, er
 	return pods.Items, nil
 }
 
-func KubeGetSecret(namespace string, secretName string) (*corev1.Secret, error) {
+func (k *KubeClient) KubeGetServices(ctx context.Context, namespace string, labelmap map[string]string) ([]corev1.Service, error) {
 	var err error
 
-	client, _ := KubeInit()
+	labelSelector := metav1.LabelSelector{MatchLabels: labelmap}
+	listOptions := metav1.ListOptions{
+		LabelSelector: labels.Set(labelSelector.MatchLabels).String(),
+	}
+
+	services, err := k.CoreV1().Services(namespace).List(ctx, listOptions)
+	if err != nil {
+		return nil, err
+	}
+
+	return services.Items, nil
+}
+
+func (k *KubeClient) KubeGetEndpoints(ctx context.Context, namespace string, endpointName string) (*corev1.Endpoints, error) {
+	var err error
 
-	secret, err := client.CoreV1().Secrets(namespace).Get(context.Background(), secretName, metav1.GetOptions{})
+	endpoints, err := k.CoreV1().Endpoints(namespace).Get(ctx, endpointName, metav1.GetOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	return endpoints, nil
+}
+
+func (k *KubeClient) KubeGetPVCs(ctx context.Context, namespace string, labelmap map[string]string) ([]corev1.PersistentVolumeClaim, error) {
+	var err error
+
+	labelSelector := metav1.LabelSelector{MatchLabels: labelmap}
+	listOptions := metav1.ListOptions{
+		LabelSelector: labels.Set(labelSelector.MatchLabels).String(),
+	}
+
+	pvcs, err := k.CoreV1().PersistentVolumeClaims(namespace).List(ctx, listOptions)
+	if err != nil {
+		return nil, err
+	}
+
+	return pvcs.Items, nil
+}
+
+func (k *KubeClient) KubeGetSecret(ctx context.Context, namespace string, secretName string) (*corev1.Secret, error) {
+	var err error
+
+	secret, err := k.CoreV1().Secrets(namespace).Get(ctx, secretName, metav1.GetOptions{})
 	if err != nil {
 		return nil, err
 	}
@@ -140,49 +247,128 @@ func KubeGetSecret(namespace string, secretName string) (*corev1.Secret, error)
 	return secret, nil
 }
 
-func KubeGetAllPods(namespace string, name string) ([]corev1.Pod, error) {
+func (k *KubeClient) KubeGetConfigMap(ctx context.Context, namespace string, configMapName string) (*corev1.ConfigMap, error) {
 	var err error
-	var allpods []corev1.Pod
 
-	pods, err := KubeGetPods(namespace, map[string]string{"release": name})
+	configMap, err := k.CoreV1().ConfigMaps(namespace).Get(ctx, configMapName, metav1.GetOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	return configMap, nil
+}
+
+func (k *KubeClient) KubeGetPod(ctx context.Context, namespace string, podName string) (*corev1.Pod, error) {
+	var err error
+
+	pod, err := k.CoreV1().Pods(namespace).Get(ctx, podName, metav1.GetOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	return pod, nil
+}
+
+func (k *KubeClient) KubeGetJob(ctx context.Context, namespace string, jobName string) (*batchv1.Job, error) {
+	var err error
+
+	job, err := k.BatchV1().Jobs(namespace).Get(ctx, jobName, metav1.GetOptions{})
 	if err != nil {
 		return nil, err
 	}
-	allpods = append(allpods, pods...)
 
-	pods, err = KubeGetPods(namespace, map[string]string{"app.kubernetes.io/instance": name})
+	return job, nil
+}
+
+func (k *KubeClient) KubeGetEvents(ctx context.Context, namespace string) ([]corev1.Event, error) {
+	var err error
+
+	events, err := k.CoreV1().Events(namespace).List(ctx, metav1.ListOptions{})
 	if err != nil {
 		return nil, err
 	}
-	allpods = append(allpods, pods...)
 
-	pods, err = KubeGetPods(namespace, map[string]string{"app": name + "-promscale"})
+	return events.Items, nil
+}
+
+// KubeGetAllPVCNames returns the names of the PVCs of every component of the
+// release.
+func (k *KubeClient) KubeGetAllPVCNames(ctx context.Context, namespace string, name string) ([]string, error) {
+	var err error
+	var allnames []string
+
+	components, err := stackComponents(name, namespace)
 	if err != nil {
 		return nil, err
 	}
-	allpods = append(allpods, pods...)
 
-	pods, err = KubeGetPods(namespace, map[string]string{"job-name": name + "-grafana-db"})
+	seen := make(map[string]bool)
+	for _, component := range components {
+		names, err := k.KubeGetPVCNames(ctx, namespace, component.Selector)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, n := range names {
+			if !seen[n] {
+				seen[n] = true
+				allnames = append(allnames, n)
+			}
+		}
+	}
+
+	return allnames, nil
+}
+
+// KubeGetAllPods returns the pods of every component of the release.
+func (k *KubeClient) KubeGetAllPods(ctx context.Context, namespace string, name string) ([]corev1.Pod, error) {
+	var err error
+	var allpods []corev1.Pod
+
+	components, err := stackComponents(name, namespace)
 	if err != nil {
 		return nil, err
 	}
-	allpods = append(allpods, pods...)
+
+	seen := make(map[string]bool)
+	for _, component := range components {
+		pods, err := k.KubeGetPods(ctx, namespace, component.Selector)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, pod := range pods {
+			if !seen[pod.Name] {
+				seen[pod.Name] = true
+				allpods = append(allpods, pod)
+			}
+		}
+	}
 
 	return allpods, nil
 }
 
-// ExecCmd exec command on specific pod and wait the command's output.
-func KubeExecCmd(namespace string, podName string, container string, command string, stdin io.Reader, tty bool) error {
+func (k *KubeClient) KubeGetPodLogs(ctx context.Context, namespace string, podName string, options *corev1.PodLogOptions) (io.ReadCloser, error) {
 	var err error
 
-	client, config := KubeInit()
+	logs, err := k.CoreV1().Pods(namespace).GetLogs(podName, options).Stream(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return logs, nil
+}
+
+// ExecCmd exec command on specific pod and wait the command's output.
+func (k *KubeClient) KubeExecCmd(ctx context.Context, namespace string, podName string, container string, command string, stdin io.Reader, tty bool) error {
+	var err error
 
 	shcmd := []string{
 		"/bin/sh",
 		"-c",
 		command,
 	}
-	req := client.CoreV1().RESTClient().Post().Resource("pods").Namespace(namespace).
+	req := k.CoreV1().RESTClient().Post().Resource("pods").Namespace(namespace).
 		Name(podName).SubResource("exec")
 	option := &corev1.PodExecOptions{
 		Container: container,
@@ -200,36 +386,71 @@ func KubeExecCmd(namespace string, podName string, container string, command str
 		scheme.ParameterCodec,
 	)
 
-	exec, err := remotecommand.NewSPDYExecutor(config, "POST", req.URL())
+	exec, err := remotecommand.NewSPDYExecutor(k.config, "POST", req.URL())
 	if err != nil {
 		return err
 	}
 
-	err = exec.Stream(remotecommand.StreamOptions{
-		Stdin:  stdin,
-		Stdout: os.Stdout,
-		Stderr: os.Stdout,
-	})
-	if err != nil {
+	// Stream can not be cancelled, so stop waiting for it once the context is
+	// done to let the caller clean up.
+	streamErr := make(chan error, 1)
+	go func() {
+		streamErr <- exec.Stream(remotecommand.StreamOptions{
+			Stdin:  stdin,
+			Stdout: os.Stdout,
+			Stderr: os.Stdout,
+		})
+	}()
+
+	select {
+	case err = <-streamErr:
 		return err
+	case <-ctx.Done():
+		return ctx.Err()
 	}
+}
 
-	return nil
+func (k *KubeClient) KubePortForwardPod(ctx context.Context, namespace string, podName string, local int, remote int) (*portforward.PortForwarder, error) {
+	session, err := k.kubeStartPortForward(ctx, namespace, podName, local, remote, os.Stdout)
+	if err != nil {
+		return nil, err
+	}
+
+	return session.PortForwarder, nil
 }
 
-func KubePortForwardPod(namespace string, podName string, local int, remote int) (*portforward.PortForwarder, error) {
-	var err error
+// portForwardSession is a running port-forward. done is closed once
+// ForwardPorts has returned, which happens when the session is stopped, its
+// context is done or the connection to the pod is lost. err holds the error
+// ForwardPorts returned, if any.
+type portForwardSession struct {
+	*portforward.PortForwarder
+	stop     chan struct{}
+	stopOnce sync.Once
+	done     chan struct{}
+	err      error
+}
 
-	client, config := KubeInit()
+// Stop tears the session down and waits for its local port to be released.
+func (s *portForwardSession) Stop() {
+	s.stopOnce.Do(func() { close(s.stop) })
+	<-s.done
+}
+
+// kubeStartPortForward forwards local to remote on a pod, reporting the
+// forwarded ports to out. Commands whose output is data, like a database
+// query, pass ioutil.Discard to keep their stdout clean.
+func (k *KubeClient) kubeStartPortForward(ctx context.Context, namespace string, podName string, local int, remote int, out io.Writer) (*portForwardSession, error) {
+	var err error
 
-	fmt.Printf("Listening to pod %v from port %d\n", podName, local)
-	url := client.CoreV1().RESTClient().Post().
+	fmt.Fprintf(out, "Listening to pod %v from port %d\n", podName, local)
+	url := k.CoreV1().RESTClient().Post().
 		Resource("pods").
 		Namespace(namespace).
 		Name(podName).
 		SubResource("portforward").URL()
 
-	transport, upgrader, err := spdy.RoundTripperFor(config)
+	transport, upgrader, err := spdy.RoundTripperFor(k.config)
 	if err != nil {
 		return nil, err
 	}
@@ -238,53 +459,126 @@ func KubePortForwardPod(namespace string, podName string, local int, remote int)
 
 	ports := []string{fmt.Sprintf("%d:%d", local, remote)}
 
-	pf, err := portforward.New(dialer, ports, make(chan struct{}, 1), make(chan struct{}, 1), os.Stdout, os.Stderr)
+	session := &portForwardSession{stop: make(chan struct{}), done: make(chan struct{})}
+	session.PortForwarder, err = portforward.New(dialer, ports, session.stop, make(chan struct{}, 1), out, os.Stderr)
 	if err != nil {
 		return nil, err
 	}
 
-	errChan := make(chan error)
 	go func() {
-		errChan <- pf.ForwardPorts()
+		session.err = session.ForwardPorts()
+		close(session.done)
+	}()
+	go func() {
+		select {
+		case <-ctx.Done():
+			session.Stop()
+		case <-session.done:
+		}
 	}()
 
 	select {
-	case err = <-errChan:
-		return nil, err
-	case <-pf.Ready:
-		return pf, nil
+	case <-session.done:
+		if session.err == nil {
+			session.err = ctx.Err()
+		}
+		return nil, session.err
+	case <-session.Ready:
+		return session, nil
 	}
 }
 
-func KubePortForwardService(namespace string, serviceName string, local int, remote int) (*portforward.PortForwarder, error) {
+// KubePortForwardService forwards local to a Ready pod backing the service.
+// port selects the service port, either by its port number or by its target
+// port number. The container port is resolved through the service's
+// Endpoints, so named target ports work as well. The forwarder is returned
+// once its connection to the pod is established and the local port listens.
+func (k *KubeClient) KubePortForwardService(ctx context.Context, namespace string, serviceName string, local int, port int) (*portforward.PortForwarder, error) {
 	var err error
 
-	client, _ := KubeInit()
-
-	service, err := client.CoreV1().Services(namespace).Get(context.Background(), serviceName, metav1.GetOptions{})
-
-	set := labels.Set(service.Spec.Selector)
-	listOptions := metav1.ListOptions{LabelSelector: set.AsSelector().String()}
-	pods, err := client.CoreV1().Pods(namespace).List(context.Background(), listOptions)
-
-	podName := pods.Items[0].Name
+	podNames, remote, err := k.KubeResolveServicePods(ctx, namespace, serviceName, port)
+	if err != nil {
+		return nil, err
+	}
 
-	pf, err := KubePortForwardPod(namespace, podName, local, remote)
+	pf, err := k.KubePortForwardPod(ctx, namespace, podNames[0], local, remote)
 	if err != nil {
 		return nil, err
 	}
 
-	time.Sleep(1 * time.Second)
 	return pf, nil
 }
 
-func KubeCreatePod(pod *corev1.Pod) error {
+// KubeResolveServicePods returns the Ready pods behind the service together
+// with the container port that the given service port maps to.
+func (k *KubeClient) KubeResolveServicePods(ctx context.Context, namespace string, serviceName string, port int) ([]string, int, error) {
 	var err error
 
-	client, _ := KubeInit()
+	service, err := k.CoreV1().Services(namespace).Get(ctx, serviceName, metav1.GetOptions{})
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var servicePort *corev1.ServicePort
+	for i, sp := range service.Spec.Ports {
+		if int(sp.Port) == port {
+			servicePort = &service.Spec.Ports[i]
+			break
+		}
+	}
+	if servicePort == nil {
+		for i, sp := range service.Spec.Ports {
+			if sp.TargetPort.Type == intstr.Int && int(sp.TargetPort.IntVal) == port {
+				servicePort = &service.Spec.Ports[i]
+				break
+			}
+		}
+	}
+	if servicePort == nil {
+		return nil, 0, fmt.Errorf("service %v has no port %d", serviceName, port)
+	}
+
+	endpoints, err := k.CoreV1().Endpoints(namespace).Get(ctx, serviceName, metav1.GetOptions{})
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var podNames []string
+	remote := 0
+	for _, subset := range endpoints.Subsets {
+		subsetRemote := 0
+		for _, ep := range subset.Ports {
+			if ep.Name == servicePort.Name {
+				subsetRemote = int(ep.Port)
+				break
+			}
+		}
+		if subsetRemote == 0 || (remote != 0 && subsetRemote != remote) {
+			continue
+		}
+
+		// Addresses only lists endpoints that passed their readiness probe,
+		// NotReadyAddresses is deliberately ignored.
+		for _, address := range subset.Addresses {
+			if address.TargetRef != nil && address.TargetRef.Kind == "Pod" {
+				podNames = append(podNames, address.TargetRef.Name)
+				remote = subsetRemote
+			}
+		}
+	}
+
+	if len(podNames) != 0 {
+		return podNames, remote, nil
+	}
+
+	return nil, 0, fmt.Errorf("service %v has no ready pods for port %d", serviceName, port)
+}
+
+func (k *KubeClient) KubeCreatePod(ctx context.Context, pod *corev1.Pod) error {
+	var err error
 
 	fmt.Println("Creating pod...")
-	_, err = client.CoreV1().Pods(pod.Namespace).Create(context.Background(), pod, metav1.CreateOptions{})
+	_, err = k.CoreV1().Pods(pod.Namespace).Create(ctx, pod, metav1.CreateOptions{})
 	if err != nil {
 		return err
 	}
@@ -292,13 +586,11 @@ func KubeCreatePod(pod *corev1.Pod) error {
 	return nil
 }
 
-func KubeDeletePod(namespace string, podName string) error {
+func (k *KubeClient) KubeDeletePod(ctx context.Context, namespace string, podName string) error {
 	var err error
 
-	client, _ := KubeInit()
-
 	fmt.Printf("Deleting pod %v...\n", podName)
-	err = client.CoreV1().Pods(namespace).Delete(context.Background(), podName, metav1.DeleteOptions{})
+	err = k.CoreV1().Pods(namespace).Delete(ctx, podName, metav1.DeleteOptions{})
 	if err != nil {
 		return err
 	}
@@ -306,36 +598,177 @@ func KubeDeletePod(namespace string, podName string) error {
 	return nil
 }
 
-func KubeWaitOnPod(namespace string, podName string) error {
-	client, _ := KubeInit()
+// PodWaitError is returned by KubeWaitOnPod when a pod does not become ready.
+// Reason is the container waiting reason (e.g. CrashLoopBackOff), the pod
+// phase if the pod failed, or "Timeout". Events holds the most recent events
+// recorded for the pod.
+type PodWaitError struct {
+	Pod     string
+	Reason  string
+	Message string
+	Events  []string
+}
+
+func (e *PodWaitError) Error() string {
+	msg := fmt.Sprintf("pod %v is not ready: %v", e.Pod, e.Reason)
+	if e.Message != "" {
+		msg += ": " + e.Message
+	}
+	for _, event := range e.Events {
+		msg += "\n  " + event
+	}
+	return msg
+}
+
+// podFailureReasons are container waiting reasons that do not resolve on their
+// own, so waiting on the pod is aborted as soon as one of them shows up.
+var podFailureReasons = map[string]bool{
+	"ErrImagePull":               true,
+	"ImagePullBackOff":           true,
+	"InvalidImageName":           true,
+	"CrashLoopBackOff":           true,
+	"CreateContainerConfigError": true,
+}
 
+// KubeWaitOnPod watches a pod until its Ready condition is true (or, for Job
+// pods, until it has completed). It fails fast if a container is stuck in one
+// of podFailureReasons and gives up after the global --timeout.
+func (k *KubeClient) KubeWaitOnPod(ctx context.Context, namespace string, podName string) error {
 	fmt.Printf("Waiting on pod %v...\n", podName)
-	for i := 0; i < 6000; i++ {
-		pod, err := client.CoreV1().Pods(namespace).Get(context.Background(), podName, metav1.GetOptions{})
+
+	parent := ctx
+	ctx, cancel := context.WithTimeout(parent, timeout)
+	defer cancel()
+
+	fieldSelector := fields.OneTermEqualSelector("metadata.name", podName).String()
+	lw := &cache.ListWatch{
+		ListFunc: func(options metav1.ListOptions) (runtime.Object, error) {
+			options.FieldSelector = fieldSelector
+			return k.CoreV1().Pods(namespace).List(ctx, options)
+		},
+		WatchFunc: func(options metav1.ListOptions) (watch.Interface, error) {
+			options.FieldSelector = fieldSelector
+			return k.CoreV1().Pods(namespace).Watch(ctx, options)
+		},
+	}
+
+	// A pod deleted before the watch started would otherwise be waited on
+	// until the timeout.
+	precondition := func(store cache.Store) (bool, error) {
+		_, exists, err := store.Get(&corev1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: podName}})
 		if err != nil {
-			return err
+			return true, err
+		}
+		if !exists {
+			return true, &PodWaitError{Pod: podName, Reason: "Deleted"}
 		}
+		return false, nil
+	}
 
-		podStatus := pod.Status.Phase
-		if podStatus != corev1.PodPending && podStatus != corev1.PodFailed && podStatus != corev1.PodUnknown {
-			fmt.Printf("Pod %v has started\n", podName)
-			break
-		} else if i == 5999 {
-			fmt.Println("WARNING: pod did not come up in 10 minutes")
+	_, err := watchtools.UntilWithSync(ctx, lw, &corev1.Pod{}, precondition, func(event watch.Event) (bool, error) {
+		pod, ok := event.Object.(*corev1.Pod)
+		if !ok || pod.Name != podName {
+			return false, nil
+		}
+		if event.Type == watch.Deleted {
+			return false, &PodWaitError{Pod: podName, Reason: "Deleted"}
 		}
-		time.Sleep(100 * time.Millisecond)
+		return podReady(pod)
+	})
+	if err != nil && parent.Err() != nil {
+		// Interrupted rather than timed out.
+		return parent.Err()
+	}
+	if err == wait.ErrWaitTimeout {
+		err = &PodWaitError{Pod: podName, Reason: "Timeout", Message: fmt.Sprintf("did not become ready within %v", timeout)}
+	}
+
+	var waitErr *PodWaitError
+	if errors.As(err, &waitErr) {
+		// After a timeout ctx has expired, so the events need a context of
+		// their own.
+		eventsCtx, cancelEvents := cleanupContext()
+		defer cancelEvents()
+		waitErr.Events = formatEvents(k.kubeGetObjectEvents(eventsCtx, namespace, podName))
+		return waitErr
+	}
+	if err != nil {
+		return err
 	}
 
+	fmt.Printf("Pod %v is ready\n", podName)
 	return nil
 }
 
-func KubeDeleteService(namespace string, serviceName string) error {
-	var err error
+// podReady reports whether the pod is ready, or returns a PodWaitError if it
+// has failed or one of its containers can not start.
+func podReady(pod *corev1.Pod) (bool, error) {
+	switch pod.Status.Phase {
+	case corev1.PodSucceeded:
+		return true, nil
+	case corev1.PodFailed:
+		return false, &PodWaitError{Pod: pod.Name, Reason: string(corev1.PodFailed), Message: pod.Status.Message}
+	}
+
+	var statuses []corev1.ContainerStatus
+	statuses = append(statuses, pod.Status.InitContainerStatuses...)
+	statuses = append(statuses, pod.Status.ContainerStatuses...)
+	for _, status := range statuses {
+		if waiting := status.State.Waiting; waiting != nil && podFailureReasons[waiting.Reason] {
+			return false, &PodWaitError{
+				Pod:     pod.Name,
+				Reason:  waiting.Reason,
+				Message: fmt.Sprintf("container %v: %v", status.Name, waiting.Message),
+			}
+		}
+	}
+
+	for _, condition := range pod.Status.Conditions {
+		if condition.Type == corev1.PodReady {
+			return condition.Status == corev1.ConditionTrue, nil
+		}
+	}
+
+	return false, nil
+}
+
+// kubeGetObjectEvents returns the last few events recorded for an object
+// such as a pod or PVC, oldest first. Errors are ignored as the events only
+// add context.
+func (k *KubeClient) kubeGetObjectEvents(ctx context.Context, namespace string, objectName string) []corev1.Event {
+	events, err := k.CoreV1().Events(namespace).List(ctx, metav1.ListOptions{
+		FieldSelector: fields.OneTermEqualSelector("involvedObject.name", objectName).String(),
+	})
+	if err != nil {
+		return nil
+	}
 
-	client, _ := KubeInit()
+	items := events.Items
+	sort.Slice(items, func(i, j int) bool {
+		return items[i].LastTimestamp.Before(&items[j].LastTimestamp)
+	})
+	if len(items) > 10 {
+		items = items[len(items)-10:]
+	}
+
+	return items
+}
+
+// formatEvents formats events one per line.
+func formatEvents(events []corev1.Event) []string {
+	var lines []string
+	for _, event := range events {
+		lines = append(lines, fmt.Sprintf("%v %v: %v", event.Type, event.Reason, event.Message))
+	}
+
+	return lines
+}
+
+func (k *KubeClient) KubeDeleteService(ctx context.Context, namespace string, serviceName string) error {
+	var err error
 
 	fmt.Printf("Deleting service %v...\n", serviceName)
-	err = client.CoreV1().Services(namespace).Delete(context.Background(), serviceName, metav1.DeleteOptions{})
+	err = k.CoreV1().Services(namespace).Delete(ctx, serviceName, metav1.DeleteOptions{})
 	if err != nil {
 		return err
 	}
@@ -343,13 +776,11 @@ func KubeDeleteService(namespace string, serviceName string) error {
 	return nil
 }
 
-func KubeDeleteEndpoint(namespace string, endpointName string) error {
+func (k *KubeClient) KubeDeleteEndpoint(ctx context.Context, namespace string, endpointName string) error {
 	var err error
 
-	client, _ := KubeInit()
-
 	fmt.Printf("Deleting endpoint %v...\n", endpointName)
-	err = client.CoreV1().Endpoints(namespace).Delete(context.Background(), endpointName, metav1.DeleteOptions{})
+	err = k.CoreV1().Endpoints(namespace).Delete(ctx, endpointName, metav1.DeleteOptions{})
 	if err != nil {
 		return err
 	}
@@ -357,13 +788,11 @@ func KubeDeleteEndpoint(namespace string, endpointName string) error {
 	return nil
 }
 
-func KubeDeletePVC(namespace string, PVCName string) error {
+func (k *KubeClient) KubeDeletePVC(ctx context.Context, namespace string, PVCName string) error {
 	var err error
 
-	client, _ := KubeInit()
-
 	fmt.Printf("Deleting PVC %v...\n", PVCName)
-	err = client.CoreV1().PersistentVolumeClaims(namespace).Delete(context.Background(), PVCName, metav1.DeleteOptions{})
+	err = k.CoreV1().PersistentVolumeClaims(namespace).Delete(ctx, PVCName, metav1.DeleteOptions{})
 	if err != nil {
 		return err
 	}
@@ -371,13 +800,11 @@ func KubeDeletePVC(namespace string, PVCName string) error {
 	return nil
 }
 
-func KubeUpdateSecret(namespace string, secret *corev1.Secret) error {
+func (k *KubeClient) KubeUpdateSecret(ctx context.Context, namespace string, secret *corev1.Secret) error {
 	var err error
 
-	client, _ := KubeInit()
-
 	fmt.Println("Updating secret...")
-	_, err = client.CoreV1().Secrets(namespace).Update(context.Background(), secret, metav1.UpdateOptions{})
+	_, err = k.CoreV1().Secrets(namespace).Update(ctx, secret, metav1.UpdateOptions{})
 	if err != nil {
 		return err
 	}
@@ -0,0 +1,321 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/client-go/kubernetes/fake"
+	typedcorev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+)
+
+func newFakeKubeClient(objects ...runtime.Object) *KubeClient {
+	return NewKubeClient(fake.NewSimpleClientset(objects...), nil)
+}
+
+func testPod(name string, labels map[string]string) *corev1.Pod {
+	return &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "ns", Labels: labels}}
+}
+
+func TestKubeGetPodName(t *testing.T) {
+	client := newFakeKubeClient(
+		testPod("gg-timescaledb-0", map[string]string{"release": "gg", "role": "master"}),
+		testPod("gg-timescaledb-1", map[string]string{"release": "gg", "role": "replica"}),
+	)
+
+	podName, err := client.KubeGetPodName(context.Background(), "ns", map[string]string{"release": "gg", "role": "master"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if podName != "gg-timescaledb-0" {
+		t.Fatalf("expected gg-timescaledb-0, got %v", podName)
+	}
+
+	_, err = client.KubeGetPodName(context.Background(), "other", map[string]string{"release": "gg", "role": "master"})
+	if err == nil {
+		t.Fatal("expected an error when no pod matches")
+	}
+}
+
+func TestKubeGetServiceName(t *testing.T) {
+	service := func(name string) *corev1.Service {
+		return &corev1.Service{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "ns", Labels: map[string]string{"release": "gg"}}}
+	}
+
+	client := newFakeKubeClient(service("gg-promlens"))
+	serviceName, err := client.KubeGetServiceName(context.Background(), "ns", map[string]string{"release": "gg"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if serviceName != "gg-promlens" {
+		t.Fatalf("expected gg-promlens, got %v", serviceName)
+	}
+
+	client = newFakeKubeClient()
+	if _, err = client.KubeGetServiceName(context.Background(), "ns", map[string]string{"release": "gg"}); err == nil {
+		t.Fatal("expected an error when no service matches")
+	}
+
+	client = newFakeKubeClient(service("a"), service("b"))
+	if _, err = client.KubeGetServiceName(context.Background(), "ns", map[string]string{"release": "gg"}); err == nil {
+		t.Fatal("expected an error when several services match")
+	}
+}
+
+func TestKubeGetAllPods(t *testing.T) {
+	client := newFakeKubeClient(
+		testPod("gg-timescaledb-0", map[string]string{"release": "gg", "cluster-name": "gg", "role": "master"}),
+		testPod("gg-grafana", map[string]string{"app.kubernetes.io/instance": "gg", "app.kubernetes.io/name": "grafana"}),
+		testPod("gg-promscale", map[string]string{"app": "gg-promscale"}),
+		testPod("gg-grafana-db", map[string]string{"job-name": "gg-grafana-db"}),
+		testPod("other", map[string]string{"release": "other", "cluster-name": "other"}),
+	)
+
+	pods, err := client.KubeGetAllPods(context.Background(), "ns", "gg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pods) != 4 {
+		t.Fatalf("expected 4 pods, got %d", len(pods))
+	}
+}
+
+func TestKubeDeletePVC(t *testing.T) {
+	pvc := func(name, release string) *corev1.PersistentVolumeClaim {
+		return &corev1.PersistentVolumeClaim{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "ns", Labels: map[string]string{"release": release}}}
+	}
+	client := newFakeKubeClient(pvc("storage-volume-gg-timescaledb-0", "gg"), pvc("data-other", "other"))
+
+	names, err := client.KubeGetPVCNames(context.Background(), "ns", map[string]string{"release": "gg"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(names) != 1 {
+		t.Fatalf("expected 1 PVC, got %d", len(names))
+	}
+
+	err = client.KubeDeletePVC(context.Background(), "ns", names[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	names, err = client.KubeGetPVCNames(context.Background(), "ns", map[string]string{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(names) != 1 || names[0] != "data-other" {
+		t.Fatalf("expected only data-other to remain, got %v", names)
+	}
+}
+
+func TestKubeGetAllPVCNames(t *testing.T) {
+	viper.Reset()
+	defer viper.Reset()
+
+	viper.SetConfigType("yaml")
+	err := viper.ReadConfig(bytes.NewBufferString(`
+components:
+  timescaledb:
+    selector: "app={{ .Release.Name }}-db"
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pvc := func(name string, labels map[string]string) *corev1.PersistentVolumeClaim {
+		return &corev1.PersistentVolumeClaim{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "ns", Labels: labels}}
+	}
+	client := newFakeKubeClient(
+		pvc("storage-volume-gg-db-0", map[string]string{"app": "gg-db"}),
+		pvc("gg-prometheus-server", map[string]string{"release": "gg", "app": "prometheus", "component": "server"}),
+		pvc("gg-grafana", map[string]string{"app.kubernetes.io/instance": "gg", "app.kubernetes.io/name": "grafana"}),
+		pvc("data-other", map[string]string{"release": "other"}),
+	)
+
+	names, err := client.KubeGetAllPVCNames(context.Background(), "ns", "gg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(names)
+	if strings.Join(names, ",") != "gg-grafana,gg-prometheus-server,storage-volume-gg-db-0" {
+		t.Fatalf("unexpected PVCs %v", names)
+	}
+}
+
+func TestTimescaledbGetPasswordUnknownUser(t *testing.T) {
+	kubeClient = newFakeKubeClient(&corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "gg-timescaledb-passwords", Namespace: "ns"},
+		Data:       map[string][]byte{"postgres": []byte("secret")},
+	})
+	defer func() { kubeClient = nil }()
+
+	rootCmd.SetArgs([]string{"timescaledb", "get-password", "-n", "gg", "--namespace", "ns", "-U", "nobody"})
+	defer rootCmd.SetArgs(nil)
+
+	err := rootCmd.ExecuteContext(context.Background())
+	if err == nil {
+		t.Fatal("expected an error for an unknown user")
+	}
+}
+
+func TestKubeWaitOnPod(t *testing.T) {
+	ready := testPod("ready", nil)
+	ready.Status.Phase = corev1.PodRunning
+	ready.Status.Conditions = []corev1.PodCondition{{Type: corev1.PodReady, Status: corev1.ConditionTrue}}
+
+	completed := testPod("completed", nil)
+	completed.Status.Phase = corev1.PodSucceeded
+
+	crashing := testPod("crashing", nil)
+	crashing.Status.Phase = corev1.PodRunning
+	crashing.Status.ContainerStatuses = []corev1.ContainerStatus{{
+		Name:  "promscale",
+		State: corev1.ContainerState{Waiting: &corev1.ContainerStateWaiting{Reason: "CrashLoopBackOff"}},
+	}}
+
+	pending := testPod("pending", nil)
+	pending.Status.Phase = corev1.PodPending
+
+	event := &corev1.Event{
+		ObjectMeta:     metav1.ObjectMeta{Name: "crashing.1", Namespace: "ns"},
+		InvolvedObject: corev1.ObjectReference{Kind: "Pod", Name: "crashing", Namespace: "ns"},
+		Type:           corev1.EventTypeWarning,
+		Reason:         "BackOff",
+		Message:        "Back-off restarting failed container",
+	}
+
+	client := newFakeKubeClient(ready, completed, crashing, pending, event)
+
+	oldTimeout := timeout
+	timeout = 500 * time.Millisecond
+	defer func() { timeout = oldTimeout }()
+
+	for _, podName := range []string{"ready", "completed"} {
+		if err := client.KubeWaitOnPod(context.Background(), "ns", podName); err != nil {
+			t.Fatalf("expected %v to be ready: %v", podName, err)
+		}
+	}
+
+	var waitErr *PodWaitError
+	err := client.KubeWaitOnPod(context.Background(), "ns", "crashing")
+	if !errors.As(err, &waitErr) || waitErr.Reason != "CrashLoopBackOff" {
+		t.Fatalf("expected a CrashLoopBackOff error, got %v", err)
+	}
+	if len(waitErr.Events) != 1 {
+		t.Fatalf("expected the pod events to be attached, got %v", waitErr.Events)
+	}
+
+	err = client.KubeWaitOnPod(context.Background(), "ns", "pending")
+	if !errors.As(err, &waitErr) || waitErr.Reason != "Timeout" {
+		t.Fatalf("expected a timeout error, got %v", err)
+	}
+}
+
+// contextClientset fails listing events with a done context, which the fake
+// clientset would otherwise ignore.
+type contextClientset struct{ *fake.Clientset }
+
+func (c contextClientset) CoreV1() typedcorev1.CoreV1Interface {
+	return contextCoreV1{c.Clientset.CoreV1()}
+}
+
+type contextCoreV1 struct{ typedcorev1.CoreV1Interface }
+
+func (c contextCoreV1) Events(namespace string) typedcorev1.EventInterface {
+	return contextEvents{c.CoreV1Interface.Events(namespace)}
+}
+
+type contextEvents struct{ typedcorev1.EventInterface }
+
+func (e contextEvents) List(ctx context.Context, opts metav1.ListOptions) (*corev1.EventList, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return e.EventInterface.List(ctx, opts)
+}
+
+func TestKubeWaitOnPodTimeout(t *testing.T) {
+	pending := testPod("pending", nil)
+	pending.Status.Phase = corev1.PodPending
+	event := &corev1.Event{
+		ObjectMeta:     metav1.ObjectMeta{Name: "pending.1", Namespace: "ns"},
+		InvolvedObject: corev1.ObjectReference{Kind: "Pod", Name: "pending", Namespace: "ns"},
+		Type:           corev1.EventTypeWarning,
+		Reason:         "FailedScheduling",
+		Message:        "0/1 nodes are available: 1 Insufficient memory.",
+	}
+	client := NewKubeClient(contextClientset{fake.NewSimpleClientset(pending, event)}, nil)
+
+	oldTimeout := timeout
+	defer func() { timeout = oldTimeout }()
+
+	timeout = 200 * time.Millisecond
+	var waitErr *PodWaitError
+	err := client.KubeWaitOnPod(context.Background(), "ns", "pending")
+	if !errors.As(err, &waitErr) || waitErr.Reason != "Timeout" {
+		t.Fatalf("expected a timeout error, got %v", err)
+	}
+	if len(waitErr.Events) != 1 || waitErr.Events[0] != "Warning FailedScheduling: 0/1 nodes are available: 1 Insufficient memory." {
+		t.Fatalf("expected the pod events to be attached after the timeout, got %v", waitErr.Events)
+	}
+
+	timeout = time.Minute
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	err = client.KubeWaitOnPod(ctx, "ns", "pending")
+	if errors.As(err, &waitErr) || !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected the cancellation of the parent context, got %v", err)
+	}
+}
+
+func TestKubeResolveServicePods(t *testing.T) {
+	service := &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{Name: "gg-grafana", Namespace: "ns"},
+		Spec: corev1.ServiceSpec{Ports: []corev1.ServicePort{
+			{Name: "service", Port: 80, TargetPort: intstr.FromString("grafana")},
+		}},
+	}
+	podRef := func(podName string) *corev1.ObjectReference {
+		return &corev1.ObjectReference{Kind: "Pod", Name: podName, Namespace: "ns"}
+	}
+	endpoints := &corev1.Endpoints{
+		ObjectMeta: metav1.ObjectMeta{Name: "gg-grafana", Namespace: "ns"},
+		Subsets: []corev1.EndpointSubset{{
+			Addresses:         []corev1.EndpointAddress{{IP: "10.0.0.2", TargetRef: podRef("gg-grafana-ready")}},
+			NotReadyAddresses: []corev1.EndpointAddress{{IP: "10.0.0.1", TargetRef: podRef("gg-grafana-starting")}},
+			Ports:             []corev1.EndpointPort{{Name: "service", Port: 3000}},
+		}},
+	}
+	client := newFakeKubeClient(service, endpoints)
+
+	podNames, remote, err := client.KubeResolveServicePods(context.Background(), "ns", "gg-grafana", 80)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(podNames) != 1 || podNames[0] != "gg-grafana-ready" || remote != 3000 {
+		t.Fatalf("expected [gg-grafana-ready]:3000, got %v:%d", podNames, remote)
+	}
+
+	if _, _, err = client.KubeResolveServicePods(context.Background(), "ns", "gg-grafana", 8080); err == nil {
+		t.Fatal("expected an error for an unknown service port")
+	}
+
+	endpoints.Subsets[0].Addresses = nil
+	client = newFakeKubeClient(service, endpoints)
+	if _, _, err = client.KubeResolveServicePods(context.Background(), "ns", "gg-grafana", 80); err == nil {
+		t.Fatal("expected an error when no pod is ready")
+	}
+
+	if _, _, err = client.KubeResolveServicePods(context.Background(), "ns", "missing", 80); err == nil {
+		t.Fatal("expected an error for a missing service")
+	}
+}
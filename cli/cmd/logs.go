@@ -0,0 +1,261 @@
+package cmd
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// logsCmd represents the logs command
+var logsCmd = &cobra.Command{
+	Use:   "logs [component...]",
+	Short: "Prints the logs of The Observability Stack components",
+	Long: `Prints the logs of all pods and containers of the given components, or of
+every component if none are given. Components are timescaledb, promscale,
+prometheus, grafana, promlens, grafana-db, node-exporter and
+kube-state-metrics.`,
+	RunE: logs,
+}
+
+func init() {
+	rootCmd.AddCommand(logsCmd)
+	logsCmd.Flags().BoolP("follow", "f", false, "Keep streaming the logs")
+	logsCmd.Flags().Duration("since", 0, "Only print logs newer than a relative duration like 5s, 2m, or 3h")
+	logsCmd.Flags().Int64("tail", -1, "Number of recent lines to print per container, -1 prints all lines")
+	logsCmd.Flags().BoolP("previous", "p", false, "Print the logs of the previous instance of the containers")
+	logsCmd.Flags().String("grep", "", "Only print lines matching this regular expression")
+	logsCmd.Flags().BoolP("invert-match", "v", false, "Only print lines not matching --grep")
+}
+
+// logSource is a single container whose logs are streamed.
+type logSource struct {
+	pod       string
+	container string
+	prefix    string
+}
+
+// logFilter decides whether a log line is printed.
+type logFilter struct {
+	pattern *regexp.Regexp
+	invert  bool
+}
+
+func (f logFilter) match(line string) bool {
+	if f.pattern == nil {
+		return true
+	}
+	return f.pattern.MatchString(line) != f.invert
+}
+
+func logs(cmd *cobra.Command, args []string) error {
+	var err error
+
+	var follow bool
+	follow, err = cmd.Flags().GetBool("follow")
+	if err != nil {
+		return fmt.Errorf("could not get logs: %w", err)
+	}
+
+	var since time.Duration
+	since, err = cmd.Flags().GetDuration("since")
+	if err != nil {
+		return fmt.Errorf("could not get logs: %w", err)
+	}
+
+	var tail int64
+	tail, err = cmd.Flags().GetInt64("tail")
+	if err != nil {
+		return fmt.Errorf("could not get logs: %w", err)
+	}
+
+	var previous bool
+	previous, err = cmd.Flags().GetBool("previous")
+	if err != nil {
+		return fmt.Errorf("could not get logs: %w", err)
+	}
+
+	var grep string
+	grep, err = cmd.Flags().GetString("grep")
+	if err != nil {
+		return fmt.Errorf("could not get logs: %w", err)
+	}
+
+	var filter logFilter
+	filter.invert, err = cmd.Flags().GetBool("invert-match")
+	if err != nil {
+		return fmt.Errorf("could not get logs: %w", err)
+	}
+	if grep != "" {
+		filter.pattern, err = regexp.Compile(grep)
+		if err != nil {
+			return fmt.Errorf("could not get logs: invalid --grep pattern: %w", err)
+		}
+	}
+
+	components, err := selectComponents(args)
+	if err != nil {
+		return fmt.Errorf("could not get logs: %w", err)
+	}
+
+	options := corev1.PodLogOptions{Follow: follow, Previous: previous}
+	if since > 0 {
+		seconds := int64(since.Seconds())
+		options.SinceSeconds = &seconds
+	}
+	if tail >= 0 {
+		options.TailLines = &tail
+	}
+
+	ctx := cmd.Context()
+	client, err := getKubeClient()
+	if err != nil {
+		return fmt.Errorf("could not get logs: %w", err)
+	}
+
+	sources, err := getLogSources(ctx, client, components)
+	if err != nil {
+		return fmt.Errorf("could not get logs: %w", err)
+	}
+	if len(sources) == 0 {
+		return fmt.Errorf("could not get logs: no pods found for release %v in namespace %v", name, namespace)
+	}
+
+	err = streamLogs(ctx, client, os.Stdout, sources, options, filter)
+	if err != nil {
+		return fmt.Errorf("could not get logs: %w", err)
+	}
+
+	return nil
+}
+
+// selectComponents returns the components named in args, or all of them.
+func selectComponents(args []string) ([]stackComponent, error) {
+	all, err := stackComponents(name, namespace)
+	if err != nil {
+		return nil, err
+	}
+	if len(args) == 0 {
+		return all, nil
+	}
+
+	var ids []string
+	for _, component := range all {
+		ids = append(ids, component.ID)
+	}
+
+	var components []stackComponent
+	for _, arg := range args {
+		found := false
+		for _, component := range all {
+			if component.ID == strings.ToLower(arg) {
+				components = append(components, component)
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("unknown component %v, must be one of %v", arg, strings.Join(ids, ", "))
+		}
+	}
+
+	return components, nil
+}
+
+func getLogSources(ctx context.Context, client *KubeClient, components []stackComponent) ([]logSource, error) {
+	var sources []logSource
+
+	for _, component := range components {
+		pods, err := client.KubeGetPods(ctx, namespace, component.Selector)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, pod := range pods {
+			for _, container := range pod.Spec.Containers {
+				prefix := "[" + component.ID + "/" + pod.Name
+				if len(pod.Spec.Containers) > 1 {
+					prefix += "/" + container.Name
+				}
+				sources = append(sources, logSource{
+					pod:       pod.Name,
+					container: container.Name,
+					prefix:    prefix + "] ",
+				})
+			}
+		}
+	}
+
+	return sources, nil
+}
+
+// streamLogs prints the logs of all sources concurrently. A container that
+// can not be streamed (e.g. one that has not started yet) is reported on
+// stderr without interrupting the others; an error is only returned if no
+// container could be streamed at all.
+func streamLogs(ctx context.Context, client *KubeClient, out io.Writer, sources []logSource, options corev1.PodLogOptions, filter logFilter) error {
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	errs := make([]error, len(sources))
+
+	for i, source := range sources {
+		wg.Add(1)
+		go func(i int, source logSource) {
+			defer wg.Done()
+
+			opts := options
+			opts.Container = source.container
+			stream, err := client.KubeGetPodLogs(ctx, namespace, source.pod, &opts)
+			if err != nil {
+				errs[i] = err
+				fmt.Fprintf(os.Stderr, "%vcould not stream logs: %v\n", source.prefix, err)
+				return
+			}
+			defer stream.Close()
+
+			err = copyLogLines(out, &mu, source.prefix, stream, filter)
+			if err != nil && ctx.Err() == nil {
+				fmt.Fprintf(os.Stderr, "%vlog stream broke: %v\n", source.prefix, err)
+			}
+		}(i, source)
+	}
+	wg.Wait()
+
+	for _, err := range errs {
+		if err == nil {
+			return nil
+		}
+	}
+	return errs[0]
+}
+
+// copyLogLines writes every matching line of r to out with the prefix. The
+// mutex keeps lines of concurrent streams from interleaving.
+func copyLogLines(out io.Writer, mu *sync.Mutex, prefix string, r io.Reader, filter logFilter) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+
+	for scanner.Scan() {
+		line := scanner.Text()
+		if !filter.match(line) {
+			continue
+		}
+		mu.Lock()
+		_, err := fmt.Fprintln(out, prefix+line)
+		mu.Unlock()
+		if err != nil {
+			return err
+		}
+	}
+
+	return scanner.Err()
+}
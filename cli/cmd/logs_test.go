@@ -0,0 +1,59 @@
+package cmd
+
+import (
+	"bytes"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestCopyLogLines(t *testing.T) {
+	var out bytes.Buffer
+	var mu sync.Mutex
+
+	input := "level=info msg=started\nlevel=error msg=\"could not write\"\nlevel=info msg=done\n"
+
+	err := copyLogLines(&out, &mu, "[promscale/gg-promscale-0] ", strings.NewReader(input), logFilter{pattern: regexp.MustCompile("level=error")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out.String() != "[promscale/gg-promscale-0] level=error msg=\"could not write\"\n" {
+		t.Fatalf("unexpected output %q", out.String())
+	}
+
+	out.Reset()
+	err = copyLogLines(&out, &mu, "[p] ", strings.NewReader(input), logFilter{pattern: regexp.MustCompile("level=error"), invert: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out.String() != "[p] level=info msg=started\n[p] level=info msg=done\n" {
+		t.Fatalf("unexpected output %q", out.String())
+	}
+}
+
+func TestSelectComponents(t *testing.T) {
+	oldName := name
+	defer func() { name = oldName }()
+	name = "gg"
+
+	components, err := selectComponents(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(components) != len(defaultStackComponents) {
+		t.Fatalf("expected all components, got %v", len(components))
+	}
+
+	components, err = selectComponents([]string{"Promscale", "timescaledb"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(components) != 2 || components[0].ID != "promscale" || components[1].ID != "timescaledb" {
+		t.Fatalf("unexpected components %+v", components)
+	}
+
+	if _, err = selectComponents([]string{"alertmanager"}); err == nil {
+		t.Fatal("expected an error for an unknown component")
+	}
+}
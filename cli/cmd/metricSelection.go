@@ -0,0 +1,177 @@
+package cmd
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// metricSelection is the metric named by the first argument of a command, or
+// the metrics selected by its --match or --all flags.
+type metricSelection struct {
+	Metric  string
+	Pattern string
+	All     bool
+	Yes     bool
+
+	matches func(string) bool
+}
+
+func addMetricSelectionFlags(cmd *cobra.Command) {
+	cmd.Flags().StringP("match", "m", "", "Apply to all metrics matching a glob, or a regular expression wrapped in slashes, instead of the given metric")
+	cmd.Flags().BoolP("all", "", false, "Apply to all metrics instead of the given metric")
+	cmd.Flags().BoolP("yes", "y", false, "Do not ask for confirmation before changing the matched metrics")
+}
+
+// metricSelectionArgs accepts a metric name followed by n arguments, or just
+// the n arguments when metrics are selected by --match or --all.
+func metricSelectionArgs(n int) cobra.PositionalArgs {
+	return func(cmd *cobra.Command, args []string) error {
+		match, _ := cmd.Flags().GetString("match")
+		all, _ := cmd.Flags().GetBool("all")
+		if match != "" && all {
+			return errors.New("--match and --all cannot be used together")
+		}
+		if match != "" || all {
+			return cobra.ExactArgs(n)(cmd, args)
+		}
+		return cobra.ExactArgs(n+1)(cmd, args)
+	}
+}
+
+// getMetricSelection reads the selected metrics and returns the arguments
+// following the metric name.
+func getMetricSelection(cmd *cobra.Command, args []string) (*metricSelection, []string, error) {
+	var selection metricSelection
+	var err error
+
+	selection.Pattern, err = cmd.Flags().GetString("match")
+	if err != nil {
+		return nil, nil, err
+	}
+	selection.All, err = cmd.Flags().GetBool("all")
+	if err != nil {
+		return nil, nil, err
+	}
+	selection.Yes, err = cmd.Flags().GetBool("yes")
+	if err != nil {
+		return nil, nil, err
+	}
+
+	switch {
+	case selection.All:
+		selection.matches = func(string) bool { return true }
+	case selection.Pattern != "":
+		selection.matches, err = metricNameMatcher(selection.Pattern)
+		if err != nil {
+			return nil, nil, err
+		}
+	default:
+		selection.Metric = args[0]
+		args = args[1:]
+	}
+
+	return &selection, args, nil
+}
+
+func (s *metricSelection) String() string {
+	switch {
+	case s.All:
+		return "all metrics"
+	case s.Pattern != "":
+		return "metrics matching " + s.Pattern
+	default:
+		return s.Metric
+	}
+}
+
+// single is whether the selection is a metric named on the command line.
+func (s *metricSelection) single() bool {
+	return s.matches == nil
+}
+
+// expand lists the names of the selected metrics.
+func (s *metricSelection) expand(ctx context.Context, pool *DBPool) ([]string, error) {
+	if s.single() {
+		return []string{s.Metric}, nil
+	}
+
+	rows, err := pool.Query(ctx, "SELECT metric_name FROM _prom_catalog.metric ORDER BY metric_name")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var metrics []string
+	for rows.Next() {
+		var metric string
+		err = rows.Scan(&metric)
+		if err != nil {
+			return nil, err
+		}
+		if s.matches(metric) {
+			metrics = append(metrics, metric)
+		}
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(metrics) == 0 {
+		return nil, fmt.Errorf("no %v", s)
+	}
+	return metrics, nil
+}
+
+// confirm lists the selected metrics and asks whether to change them, unless
+// a single metric was named or --yes was given.
+func (s *metricSelection) confirm(in io.Reader, out io.Writer, metrics []string, change string) (bool, error) {
+	if s.single() {
+		return true, nil
+	}
+
+	fmt.Fprintf(out, "This will %v for %d metrics:\n", change, len(metrics))
+	for _, metric := range metrics {
+		fmt.Fprintf(out, "  %v\n", metric)
+	}
+	if s.Yes {
+		return true, nil
+	}
+
+	fmt.Fprint(out, "Continue? [y/N] ")
+	answer, err := bufio.NewReader(in).ReadString('\n')
+	if err != nil && err != io.EOF {
+		return false, err
+	}
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes", nil
+}
+
+// apply changes every selected metric, reporting the outcome for each one
+// when more than the single named metric is changed.
+func (s *metricSelection) apply(out io.Writer, metrics []string, change func(metric string) error) error {
+	if s.single() {
+		return change(s.Metric)
+	}
+
+	failed := 0
+	for _, metric := range metrics {
+		err := change(metric)
+		if err != nil {
+			fmt.Fprintf(out, "  %v: failed: %v\n", metric, err)
+			failed++
+		} else {
+			fmt.Fprintf(out, "  %v: ok\n", metric)
+		}
+	}
+
+	if failed != 0 {
+		return fmt.Errorf("%d of %d metrics failed", failed, len(metrics))
+	}
+	return nil
+}
@@ -0,0 +1,111 @@
+package cmd
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/spf13/cobra"
+)
+
+func newMetricSelectionCmd(flags ...string) *cobra.Command {
+	cmd := &cobra.Command{Use: "set"}
+	addMetricSelectionFlags(cmd)
+	cmd.Flags().Parse(flags)
+	return cmd
+}
+
+func TestMetricSelectionArgs(t *testing.T) {
+	tests := []struct {
+		flags []string
+		args  []string
+		valid bool
+	}{
+		{nil, []string{"up", "30"}, true},
+		{nil, []string{"30"}, false},
+		{[]string{"--match", "node_*"}, []string{"30"}, true},
+		{[]string{"--match", "node_*"}, []string{"up", "30"}, false},
+		{[]string{"--all"}, []string{"30"}, true},
+		{[]string{"--all", "--match", "node_*"}, []string{"30"}, false},
+	}
+	for _, test := range tests {
+		err := metricSelectionArgs(1)(newMetricSelectionCmd(test.flags...), test.args)
+		if (err == nil) != test.valid {
+			t.Fatalf("expected %v %v to be valid: %v, got %v", test.flags, test.args, test.valid, err)
+		}
+	}
+}
+
+func TestGetMetricSelection(t *testing.T) {
+	selection, args, err := getMetricSelection(newMetricSelectionCmd(), []string{"up", "30"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !selection.single() || selection.String() != "up" || len(args) != 1 || args[0] != "30" {
+		t.Fatalf("unexpected selection %v of %v", selection, args)
+	}
+
+	selection, args, err = getMetricSelection(newMetricSelectionCmd("--match", "/^node_/"), []string{"30"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if selection.single() || selection.String() != "metrics matching /^node_/" || !selection.matches("node_load1") || selection.matches("up") || len(args) != 1 {
+		t.Fatalf("unexpected selection %v of %v", selection, args)
+	}
+
+	_, _, err = getMetricSelection(newMetricSelectionCmd("--match", "node_["), []string{"30"})
+	if err == nil {
+		t.Fatal("expected an invalid pattern to be refused")
+	}
+}
+
+func TestMetricSelectionConfirm(t *testing.T) {
+	selection, _, err := getMetricSelection(newMetricSelectionCmd("--all"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	metrics := []string{"node_load1", "up"}
+
+	answers := map[string]bool{"y\n": true, "YES\n": true, "n\n": false, "\n": false, "": false}
+	for answer, expected := range answers {
+		var out bytes.Buffer
+		proceed, err := selection.confirm(strings.NewReader(answer), &out, metrics, "reset the retention period")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if proceed != expected {
+			t.Fatalf("expected answering %q to give %v", answer, expected)
+		}
+		if !strings.Contains(out.String(), "This will reset the retention period for 2 metrics:\n  node_load1\n  up\n") {
+			t.Fatalf("expected the metrics to be listed, got %q", out.String())
+		}
+	}
+
+	selection.Yes = true
+	proceed, err := selection.confirm(strings.NewReader(""), &bytes.Buffer{}, metrics, "reset the retention period")
+	if err != nil || !proceed {
+		t.Fatalf("expected --yes to skip the confirmation, got %v %v", proceed, err)
+	}
+}
+
+func TestMetricSelectionApply(t *testing.T) {
+	selection, _, err := getMetricSelection(newMetricSelectionCmd("--match", "*"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+	err = selection.apply(&out, []string{"node_load1", "up"}, func(metric string) error {
+		if metric == "up" {
+			return errors.New("permission denied")
+		}
+		return nil
+	})
+	if err == nil || err.Error() != "1 of 2 metrics failed" {
+		t.Fatalf("expected the failure to be counted, got %v", err)
+	}
+	if out.String() != "  node_load1: ok\n  up: failed: permission denied\n" {
+		t.Fatalf("unexpected report %q", out.String())
+	}
+}
@@ -0,0 +1,117 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// metricSettings holds the storage settings of a metric. Custom marks
+// settings that override the default.
+type metricSettings struct {
+	Name                string
+	Retention           time.Duration
+	CustomRetention     bool
+	ChunkInterval       time.Duration
+	CustomChunkInterval bool
+	Compression         bool
+}
+
+// storageDefaults holds the storage settings of metrics without overrides.
+type storageDefaults struct {
+	Retention     time.Duration
+	ChunkInterval time.Duration
+	Compression   bool
+}
+
+func getStorageDefaults(ctx context.Context, pool *DBPool) (storageDefaults, error) {
+	var defaults storageDefaults
+	var retentionSecs, chunkSecs int64
+	err := pool.QueryRow(ctx,
+		`SELECT EXTRACT(epoch FROM _prom_catalog.get_default_retention_period())::bigint,
+	    EXTRACT(epoch FROM _prom_catalog.get_default_chunk_interval())::bigint,
+	    _prom_catalog.get_default_compression_setting()`).Scan(&retentionSecs, &chunkSecs, &defaults.Compression)
+	if err != nil {
+		return defaults, err
+	}
+
+	defaults.Retention = time.Duration(retentionSecs) * time.Second
+	defaults.ChunkInterval = time.Duration(chunkSecs) * time.Second
+	return defaults, nil
+}
+
+// getMetricSettings reads the storage settings of all metrics, ordered by
+// name, from the Promscale catalog and the hypertables storing the metrics.
+func getMetricSettings(ctx context.Context, pool *DBPool) ([]metricSettings, error) {
+	rows, err := pool.Query(ctx,
+		`SELECT m.metric_name,
+	    EXTRACT(epoch FROM _prom_catalog.get_metric_retention_period(m.metric_name))::bigint,
+	    m.retention_period IS NOT NULL,
+	    COALESCE(d.interval_length, 0),
+	    NOT m.default_chunk_interval,
+	    COALESCE(h.compressed_hypertable_id IS NOT NULL, false)
+	 FROM _prom_catalog.metric m
+	 LEFT JOIN _timescaledb_catalog.hypertable h
+	    ON (h.schema_name = 'prom_data' AND h.table_name = m.table_name)
+	 LEFT JOIN LATERAL
+	 (SELECT dim.interval_length FROM _timescaledb_catalog.dimension dim WHERE dim.hypertable_id = h.id ORDER BY dim.id LIMIT 1) d
+	    ON (true)
+	 ORDER BY m.metric_name`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var metrics []metricSettings
+	for rows.Next() {
+		var metric metricSettings
+		var retentionSecs, chunkMicrosecs int64
+		err = rows.Scan(&metric.Name, &retentionSecs, &metric.CustomRetention, &chunkMicrosecs,
+			&metric.CustomChunkInterval, &metric.Compression)
+		if err != nil {
+			return nil, err
+		}
+		metric.Retention = time.Duration(retentionSecs) * time.Second
+		metric.ChunkInterval = time.Duration(chunkMicrosecs) * time.Microsecond
+		metrics = append(metrics, metric)
+	}
+
+	return metrics, rows.Err()
+}
+
+func retentionDays(retention time.Duration) int {
+	return int(retention / (24 * time.Hour))
+}
+
+// fractionalDays returns a retention period in days, e.g. 1.5 for 36h, for
+// output that must not lose the hours of a retention like formatDays.
+func fractionalDays(retention time.Duration) float64 {
+	return retention.Hours() / 24
+}
+
+func daysDuration(days int) time.Duration {
+	return time.Duration(days) * 24 * time.Hour
+}
+
+// formatDays prints a retention period in days, or as a duration if it is
+// not a whole number of days.
+func formatDays(retention time.Duration) string {
+	if retention%(24*time.Hour) != 0 {
+		return retention.String()
+	}
+	return fmt.Sprintf("%d days", retentionDays(retention))
+}
+
+func withOverride(setting string, custom bool) string {
+	if custom {
+		return setting + " (override)"
+	}
+	return setting + " (default)"
+}
+
+func enabledString(enabled bool) string {
+	if enabled {
+		return "enabled"
+	}
+	return "disabled"
+}
@@ -2,6 +2,9 @@ package cmd
 
 import (
 	"fmt"
+	"path"
+	"regexp"
+	"strings"
 
 	"github.com/spf13/cobra"
 )
@@ -40,3 +43,24 @@ func init() {
 	metricsCmd.PersistentFlags().StringP("user", "U", "postgres", "database user name")
 	metricsCmd.PersistentFlags().StringP("dbname", "d", "postgres", "database name to connect to")
 }
+
+// metricNameMatcher returns whether metric names match a pattern. Patterns
+// wrapped in slashes, like /^node_.*_bytes$/, are regular expressions, all
+// others are globs like node_*_bytes.
+func metricNameMatcher(pattern string) (func(string) bool, error) {
+	if len(pattern) > 1 && strings.HasPrefix(pattern, "/") && strings.HasSuffix(pattern, "/") {
+		re, err := regexp.Compile(pattern[1 : len(pattern)-1])
+		if err != nil {
+			return nil, fmt.Errorf("invalid metric name pattern %v: %w", pattern, err)
+		}
+		return re.MatchString, nil
+	}
+
+	if _, err := path.Match(pattern, ""); err != nil {
+		return nil, fmt.Errorf("invalid metric name pattern %v: %w", pattern, err)
+	}
+	return func(metric string) bool {
+		matched, _ := path.Match(pattern, metric)
+		return matched
+	}, nil
+}
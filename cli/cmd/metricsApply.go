@@ -0,0 +1,375 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"sigs.k8s.io/yaml"
+)
+
+// metricsApplyCmd represents the metrics apply command
+var metricsApplyCmd = &cobra.Command{
+	Use:   "apply",
+	Short: "Applies a policy file of default and per-metric retention, chunk interval and compression settings",
+	Args:  cobra.ExactArgs(0),
+	RunE:  metricsApply,
+}
+
+func init() {
+	metricsCmd.AddCommand(metricsApplyCmd)
+	metricsApplyCmd.Flags().StringP("filename", "f", "", "Policy file to apply, - to read it from standard input")
+	metricsApplyCmd.MarkFlagRequired("filename")
+	metricsApplyCmd.Flags().BoolP("prune", "", false, "Reset the settings of metrics not matched by any rule of the policy to the defaults")
+	metricsApplyCmd.Flags().BoolP("dry-run", "", false, "Only show the changes the policy would make")
+}
+
+// metricPolicy sets the default storage settings and those of the metrics
+// matching its rules. The first rule matching a metric applies to it, and
+// settings left out of a rule are reset to the default.
+type metricPolicy struct {
+	Defaults policySettings     `json:"defaults"`
+	Metrics  []metricPolicyRule `json:"metrics,omitempty"`
+}
+
+// metricPolicyRule matches metric names with a glob, or a regular expression
+// wrapped in slashes.
+type metricPolicyRule struct {
+	Match string `json:"match"`
+	policySettings
+}
+
+// policySettings sets the retention either in whole days or, for retention
+// periods like 36h, as a duration.
+type policySettings struct {
+	RetentionDays *int   `json:"retentionDays,omitempty"`
+	Retention     string `json:"retention,omitempty"`
+	ChunkInterval string `json:"chunkInterval,omitempty"`
+	Compression   *bool  `json:"compression,omitempty"`
+
+	retention     time.Duration
+	chunkInterval time.Duration
+}
+
+// settingChange is a change of a storage setting, of the defaults if Metric
+// is empty, along with the prom_api call making it.
+type settingChange struct {
+	Metric  string
+	Setting string
+	From    string
+	To      string
+	Query   string
+	Args    []interface{}
+}
+
+func (c settingChange) String() string {
+	target := c.Metric
+	if target == "" {
+		target = "default"
+	}
+	return fmt.Sprintf("%v %v: %v => %v", target, c.Setting, c.From, c.To)
+}
+
+// SQL renders the prom_api call with its arguments inlined, for printing.
+func (c settingChange) SQL() string {
+	query := c.Query
+	for i := len(c.Args); i > 0; i-- {
+		query = strings.ReplaceAll(query, fmt.Sprintf("$%d", i), sqlLiteral(c.Args[i-1]))
+	}
+	return query
+}
+
+func sqlLiteral(arg interface{}) string {
+	switch arg := arg.(type) {
+	case string:
+		return "'" + strings.ReplaceAll(arg, "'", "''") + "'"
+	case time.Duration:
+		return fmt.Sprintf("'%d microseconds'", arg.Microseconds())
+	default:
+		return fmt.Sprint(arg)
+	}
+}
+
+func metricsApply(cmd *cobra.Command, args []string) error {
+	var err error
+
+	var filename string
+	filename, err = cmd.Flags().GetString("filename")
+	if err != nil {
+		return fmt.Errorf("could not apply metric policy: %w", err)
+	}
+
+	var prune, dryRun bool
+	prune, err = cmd.Flags().GetBool("prune")
+	if err != nil {
+		return fmt.Errorf("could not apply metric policy: %w", err)
+	}
+	dryRun, err = cmd.Flags().GetBool("dry-run")
+	if err != nil {
+		return fmt.Errorf("could not apply metric policy: %w", err)
+	}
+
+	policy, err := readMetricPolicy(filename)
+	if err != nil {
+		return fmt.Errorf("could not apply metric policy: %w", err)
+	}
+
+	ctx := cmd.Context()
+	client, err := getKubeClient()
+	if err != nil {
+		return fmt.Errorf("could not apply metric policy: %w", err)
+	}
+
+	pool, err := OpenConnectionToDB(ctx, client, namespace, name, user, dbname)
+	if err != nil {
+		return fmt.Errorf("could not apply metric policy: %w", err)
+	}
+	defer pool.Close()
+
+	defaults, err := getStorageDefaults(ctx, pool)
+	if err != nil {
+		return fmt.Errorf("could not apply metric policy: %w", err)
+	}
+	metrics, err := getMetricSettings(ctx, pool)
+	if err != nil {
+		return fmt.Errorf("could not apply metric policy: %w", err)
+	}
+
+	changes, err := planMetricPolicy(policy, defaults, metrics, prune)
+	if err != nil {
+		return fmt.Errorf("could not apply metric policy: %w", err)
+	}
+
+	if len(changes) == 0 {
+		fmt.Println("The metric settings already match the policy")
+		return nil
+	}
+	printSettingChanges(os.Stdout, changes)
+	if dryRun {
+		return nil
+	}
+
+	err = applySettingChanges(ctx, pool, changes)
+	if err != nil {
+		return fmt.Errorf("could not apply metric policy: %w", err)
+	}
+
+	fmt.Printf("Applied %d changes\n", len(changes))
+	return nil
+}
+
+func readMetricPolicy(filename string) (*metricPolicy, error) {
+	var data []byte
+	var err error
+	if filename == "-" {
+		data, err = ioutil.ReadAll(os.Stdin)
+	} else {
+		data, err = ioutil.ReadFile(filename)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return parseMetricPolicy(data)
+}
+
+func parseMetricPolicy(data []byte) (*metricPolicy, error) {
+	var policy metricPolicy
+	err := yaml.UnmarshalStrict(data, &policy)
+	if err != nil {
+		return nil, fmt.Errorf("could not parse the policy: %w", err)
+	}
+
+	err = policy.Defaults.validate()
+	if err != nil {
+		return nil, fmt.Errorf("invalid defaults: %w", err)
+	}
+	for i := range policy.Metrics {
+		rule := &policy.Metrics[i]
+		if rule.Match == "" {
+			return nil, fmt.Errorf("rule %d does not match any metric", i+1)
+		}
+		_, err = metricNameMatcher(rule.Match)
+		if err != nil {
+			return nil, fmt.Errorf("invalid rule %d: %w", i+1, err)
+		}
+		err = rule.validate()
+		if err != nil {
+			return nil, fmt.Errorf("invalid rule for %v: %w", rule.Match, err)
+		}
+	}
+
+	return &policy, nil
+}
+
+func (s *policySettings) validate() error {
+	if s.RetentionDays != nil && s.Retention != "" {
+		return errors.New("retentionDays and retention cannot be used together")
+	}
+	if s.RetentionDays != nil {
+		if *s.RetentionDays < 1 {
+			return errors.New("retention must be at least 1 day")
+		}
+		s.retention = daysDuration(*s.RetentionDays)
+	}
+	if s.Retention != "" {
+		var err error
+		s.retention, err = time.ParseDuration(s.Retention)
+		if err != nil {
+			return err
+		}
+		if s.retention.Hours() < 1.0 {
+			return errors.New("retention must be at least 1 hour")
+		}
+	}
+	if s.ChunkInterval != "" {
+		var err error
+		s.chunkInterval, err = time.ParseDuration(s.ChunkInterval)
+		if err != nil {
+			return err
+		}
+		if s.chunkInterval.Minutes() < 1.0 {
+			return errors.New("chunk interval must be at least 1 minute")
+		}
+	}
+	return nil
+}
+
+// planMetricPolicy lists the changes making the live settings match a policy.
+// Metrics not matched by any rule keep their settings, unless pruning resets
+// them to the defaults.
+func planMetricPolicy(policy *metricPolicy, defaults storageDefaults, metrics []metricSettings, prune bool) ([]settingChange, error) {
+	var changes []settingChange
+
+	retention := defaults.Retention
+	if period := policy.Defaults.retention; period != 0 && period != retention {
+		retention = period
+		changes = append(changes, settingChange{
+			Setting: "retention", From: formatDays(defaults.Retention), To: formatDays(retention),
+			Query: "SELECT prom_api.set_default_retention_period($1::INTERVAL)", Args: []interface{}{period},
+		})
+	}
+	chunkInterval := defaults.ChunkInterval
+	if interval := policy.Defaults.chunkInterval; interval != 0 && interval != chunkInterval {
+		chunkInterval = interval
+		changes = append(changes, settingChange{
+			Setting: "chunk interval", From: defaults.ChunkInterval.String(), To: interval.String(),
+			Query: "SELECT prom_api.set_default_chunk_interval($1::INTERVAL)", Args: []interface{}{interval},
+		})
+	}
+	compression := defaults.Compression
+	if enabled := policy.Defaults.Compression; enabled != nil && *enabled != compression {
+		compression = *enabled
+		changes = append(changes, settingChange{
+			Setting: "compression", From: enabledString(defaults.Compression), To: enabledString(compression),
+			Query: "SELECT prom_api.set_default_compression_setting($1)", Args: []interface{}{compression},
+		})
+	}
+
+	matchers := make([]func(string) bool, len(policy.Metrics))
+	for i, rule := range policy.Metrics {
+		var err error
+		matchers[i], err = metricNameMatcher(rule.Match)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	for _, metric := range metrics {
+		var settings *policySettings
+		for i := range policy.Metrics {
+			if matchers[i](metric.Name) {
+				settings = &policy.Metrics[i].policySettings
+				break
+			}
+		}
+		if settings == nil {
+			if !prune {
+				continue
+			}
+			settings = &policySettings{}
+		}
+
+		if period := settings.retention; period != 0 {
+			if !metric.CustomRetention || metric.Retention != period {
+				changes = append(changes, settingChange{
+					Metric: metric.Name, Setting: "retention",
+					From: withOverride(formatDays(metric.Retention), metric.CustomRetention), To: withOverride(formatDays(period), true),
+					Query: "SELECT prom_api.set_metric_retention_period($1, $2::INTERVAL)", Args: []interface{}{metric.Name, period},
+				})
+			}
+		} else if metric.CustomRetention {
+			changes = append(changes, settingChange{
+				Metric: metric.Name, Setting: "retention",
+				From: withOverride(formatDays(metric.Retention), true), To: withOverride(formatDays(retention), false),
+				Query: "SELECT prom_api.reset_metric_retention_period($1)", Args: []interface{}{metric.Name},
+			})
+		}
+
+		if interval := settings.chunkInterval; interval != 0 {
+			if !metric.CustomChunkInterval || metric.ChunkInterval != interval {
+				changes = append(changes, settingChange{
+					Metric: metric.Name, Setting: "chunk interval",
+					From: withOverride(metric.ChunkInterval.String(), metric.CustomChunkInterval), To: withOverride(interval.String(), true),
+					Query: "SELECT prom_api.set_metric_chunk_interval($1, $2::INTERVAL)", Args: []interface{}{metric.Name, interval},
+				})
+			}
+		} else if metric.CustomChunkInterval {
+			changes = append(changes, settingChange{
+				Metric: metric.Name, Setting: "chunk interval",
+				From: withOverride(metric.ChunkInterval.String(), true), To: withOverride(chunkInterval.String(), false),
+				Query: "SELECT prom_api.reset_metric_chunk_interval($1)", Args: []interface{}{metric.Name},
+			})
+		}
+
+		if enabled := settings.Compression; enabled != nil {
+			if metric.Compression != *enabled {
+				changes = append(changes, settingChange{
+					Metric: metric.Name, Setting: "compression",
+					From: enabledString(metric.Compression), To: enabledString(*enabled),
+					Query: "SELECT prom_api.set_metric_compression_setting($1, $2)", Args: []interface{}{metric.Name, *enabled},
+				})
+			}
+		} else if metric.Compression != compression {
+			changes = append(changes, settingChange{
+				Metric: metric.Name, Setting: "compression",
+				From: enabledString(metric.Compression), To: enabledString(compression),
+				Query: "SELECT prom_api.reset_metric_compression_setting($1)", Args: []interface{}{metric.Name},
+			})
+		}
+	}
+
+	return changes, nil
+}
+
+func printSettingChanges(w io.Writer, changes []settingChange) {
+	for _, change := range changes {
+		fmt.Fprintf(w, "  %v\n", change)
+	}
+	fmt.Fprintf(w, "%d changes\n", len(changes))
+}
+
+// applySettingChanges makes all changes in a single transaction, so either
+// all or none of them are made.
+func applySettingChanges(ctx context.Context, pool *DBPool, changes []settingChange) error {
+	tx, err := pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	for _, change := range changes {
+		_, err = tx.Exec(ctx, change.Query, change.Args...)
+		if err != nil {
+			return fmt.Errorf("could not change %v: %w", change, err)
+		}
+	}
+
+	return tx.Commit(ctx)
+}
@@ -0,0 +1,125 @@
+package cmd
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseMetricPolicy(t *testing.T) {
+	policy, err := parseMetricPolicy([]byte(`
+defaults:
+  retentionDays: 90
+  chunkInterval: 8h
+metrics:
+- match: node_*
+  retentionDays: 30
+  compression: false
+- match: /^go_.*$/
+  chunkInterval: 1h
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *policy.Defaults.RetentionDays != 90 || policy.Defaults.chunkInterval != 8*time.Hour || policy.Defaults.Compression != nil {
+		t.Fatalf("unexpected defaults %+v", policy.Defaults)
+	}
+	if len(policy.Metrics) != 2 || *policy.Metrics[0].RetentionDays != 30 || *policy.Metrics[0].Compression || policy.Metrics[1].chunkInterval != time.Hour {
+		t.Fatalf("unexpected rules %+v", policy.Metrics)
+	}
+
+	invalid := map[string]string{
+		"metrics:\n- retentionDays: 30\n":                   "does not match any metric",
+		"metrics:\n- match: up\n  chunkInterval: 30s\n":     "chunk interval must be at least 1 minute",
+		"defaults:\n  retentionDays: 0\n":                   "at least 1 day",
+		"metrics:\n- match: /(/\n":                          "invalid metric name pattern",
+		"metrics:\n- match: up\n  retentionDay: 30\n":       "unknown field",
+		"metrics:\n- match: up\n  retention: 30m\n":         "at least 1 hour",
+		"defaults:\n  retention: 36h\n  retentionDays: 2\n": "cannot be used together",
+	}
+	for data, expected := range invalid {
+		_, err = parseMetricPolicy([]byte(data))
+		if err == nil || !strings.Contains(err.Error(), expected) {
+			t.Fatalf("expected %q to fail with %q, got %v", data, expected, err)
+		}
+	}
+}
+
+func TestPlanMetricPolicy(t *testing.T) {
+	policy, err := parseMetricPolicy([]byte(`
+defaults:
+  retentionDays: 60
+  compression: true
+metrics:
+- match: node_load*
+  retentionDays: 30
+- match: node_*
+  retentionDays: 7
+  chunkInterval: 1h
+  compression: false
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defaults := storageDefaults{Retention: daysDuration(90), ChunkInterval: 8 * time.Hour, Compression: true}
+	metrics := []metricSettings{
+		{Name: "node_cpu", Retention: daysDuration(7), CustomRetention: true, ChunkInterval: time.Hour, CustomChunkInterval: true},
+		{Name: "node_load1", Retention: daysDuration(90), ChunkInterval: 2 * time.Hour, CustomChunkInterval: true, Compression: true},
+		{Name: "up", Retention: daysDuration(14), CustomRetention: true, ChunkInterval: 8 * time.Hour, Compression: false},
+	}
+
+	changes, err := planMetricPolicy(policy, defaults, metrics, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{
+		"default retention: 90 days => 60 days",
+		"node_load1 retention: 90 days (default) => 30 days (override)",
+		"node_load1 chunk interval: 2h0m0s (override) => 8h0m0s (default)",
+	}
+	assertSettingChanges(t, changes, expected)
+	if changes[1].Query != "SELECT prom_api.set_metric_retention_period($1, $2::INTERVAL)" || changes[2].Query != "SELECT prom_api.reset_metric_chunk_interval($1)" {
+		t.Fatalf("unexpected queries %+v", changes)
+	}
+
+	changes, err = planMetricPolicy(policy, defaults, metrics, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected = append(expected,
+		"up retention: 14 days (override) => 60 days (default)",
+		"up compression: disabled => enabled",
+	)
+	assertSettingChanges(t, changes, expected)
+}
+
+func assertSettingChanges(t *testing.T, changes []settingChange, expected []string) {
+	t.Helper()
+	var actual []string
+	for _, change := range changes {
+		actual = append(actual, change.String())
+	}
+	if strings.Join(actual, "\n") != strings.Join(expected, "\n") {
+		t.Fatalf("expected changes\n%v\ngot\n%v", strings.Join(expected, "\n"), strings.Join(actual, "\n"))
+	}
+}
+
+func TestSettingChangeSQL(t *testing.T) {
+	tests := map[string]settingChange{
+		"SELECT prom_api.set_metric_retention_period('it''s', INTERVAL '1 day' * 30)": {
+			Query: "SELECT prom_api.set_metric_retention_period($1, INTERVAL '1 day' * $2)", Args: []interface{}{"it's", 30},
+		},
+		"SELECT prom_api.set_default_chunk_interval('3600000000 microseconds'::INTERVAL)": {
+			Query: "SELECT prom_api.set_default_chunk_interval($1::INTERVAL)", Args: []interface{}{time.Hour},
+		},
+		"SELECT prom_api.set_metric_compression_setting('up', false)": {
+			Query: "SELECT prom_api.set_metric_compression_setting($1, $2)", Args: []interface{}{"up", false},
+		},
+	}
+	for expected, change := range tests {
+		if change.SQL() != expected {
+			t.Fatalf("expected %v, got %v", expected, change.SQL())
+		}
+	}
+}
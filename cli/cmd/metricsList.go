@@ -0,0 +1,339 @@
+package cmd
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/jackc/pgx/v4"
+	"github.com/spf13/cobra"
+)
+
+// metricsListCmd represents the metrics list command
+var metricsListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "Lists the metrics with their retention, chunk interval, compression, series count, size and last sample",
+	Args:  cobra.ExactArgs(0),
+	RunE:  metricsList,
+}
+
+func init() {
+	metricsCmd.AddCommand(metricsListCmd)
+	metricsListCmd.Flags().StringP("match", "m", "", "Only list metrics matching a glob, or a regular expression wrapped in slashes")
+	metricsListCmd.Flags().StringP("sort-by", "s", "name", "Sort by name, retention, chunk-interval, series, size or last-sample, all but name largest first")
+	metricsListCmd.Flags().StringP("output", "o", "table", "Output format, one of table, json or csv")
+	metricsListCmd.Flags().BoolP("no-last-sample", "", false, "Do not show the time of the last sample of each metric, which reads the newest chunk of every listed metric")
+}
+
+// metricInfo holds the storage settings and statistics of a metric.
+type metricInfo struct {
+	metricSettings
+	Table      string
+	Series     int64
+	Size       int64
+	LastSample *time.Time
+}
+
+func (m metricInfo) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Name                string     `json:"name"`
+		RetentionDays       float64    `json:"retentionDays"`
+		CustomRetention     bool       `json:"customRetention"`
+		ChunkInterval       string     `json:"chunkInterval"`
+		CustomChunkInterval bool       `json:"customChunkInterval"`
+		Compression         bool       `json:"compression"`
+		Series              int64      `json:"series"`
+		SizeBytes           int64      `json:"sizeBytes"`
+		LastSample          *time.Time `json:"lastSample"`
+	}{
+		Name:                m.Name,
+		RetentionDays:       fractionalDays(m.Retention),
+		CustomRetention:     m.CustomRetention,
+		ChunkInterval:       m.ChunkInterval.String(),
+		CustomChunkInterval: m.CustomChunkInterval,
+		Compression:         m.Compression,
+		Series:              m.Series,
+		SizeBytes:           m.Size,
+		LastSample:          m.LastSample,
+	})
+}
+
+// metricSortKeys orders metrics for --sort-by, the largest value first for
+// everything but names.
+var metricSortKeys = map[string]func(a, b metricInfo) bool{
+	"name":           func(a, b metricInfo) bool { return a.Name < b.Name },
+	"retention":      func(a, b metricInfo) bool { return a.Retention > b.Retention },
+	"chunk-interval": func(a, b metricInfo) bool { return a.ChunkInterval > b.ChunkInterval },
+	"series":         func(a, b metricInfo) bool { return a.Series > b.Series },
+	"size":           func(a, b metricInfo) bool { return a.Size > b.Size },
+	"last-sample": func(a, b metricInfo) bool {
+		if a.LastSample == nil || b.LastSample == nil {
+			return a.LastSample != nil && b.LastSample == nil
+		}
+		return a.LastSample.After(*b.LastSample)
+	},
+}
+
+func metricsList(cmd *cobra.Command, args []string) error {
+	var err error
+
+	var match, sortBy, output string
+	var lastSample bool
+	match, err = cmd.Flags().GetString("match")
+	if err != nil {
+		return fmt.Errorf("could not list metrics: %w", err)
+	}
+	sortBy, err = cmd.Flags().GetString("sort-by")
+	if err != nil {
+		return fmt.Errorf("could not list metrics: %w", err)
+	}
+	if _, exists := metricSortKeys[sortBy]; !exists {
+		return fmt.Errorf("could not list metrics: unknown sort key %v", sortBy)
+	}
+	output, err = cmd.Flags().GetString("output")
+	if err != nil {
+		return fmt.Errorf("could not list metrics: %w", err)
+	}
+	if output != "table" && output != "json" && output != "csv" {
+		return fmt.Errorf("could not list metrics: unknown output format %v", output)
+	}
+	lastSample, err = cmd.Flags().GetBool("no-last-sample")
+	if err != nil {
+		return fmt.Errorf("could not list metrics: %w", err)
+	}
+	lastSample = !lastSample
+	if sortBy == "last-sample" && !lastSample {
+		return errors.New("could not list metrics: cannot sort by last-sample with --no-last-sample")
+	}
+
+	matches := func(string) bool { return true }
+	if match != "" {
+		matches, err = metricNameMatcher(match)
+		if err != nil {
+			return fmt.Errorf("could not list metrics: %w", err)
+		}
+	}
+
+	ctx := cmd.Context()
+	client, err := getKubeClient()
+	if err != nil {
+		return fmt.Errorf("could not list metrics: %w", err)
+	}
+
+	pool, err := OpenConnectionToDB(ctx, client, namespace, name, user, dbname)
+	if err != nil {
+		return fmt.Errorf("could not list metrics: %w", err)
+	}
+	defer pool.Close()
+
+	metrics, err := getMetricInfos(ctx, pool, matches, lastSample)
+	if err != nil {
+		return fmt.Errorf("could not list metrics: %w", err)
+	}
+	sortMetricInfos(metrics, sortBy)
+
+	switch output {
+	case "json":
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		err = encoder.Encode(metrics)
+	case "csv":
+		err = writeMetricInfosCSV(os.Stdout, metrics)
+	default:
+		err = printMetricInfos(os.Stdout, metrics, lastSample)
+	}
+	if err != nil {
+		return fmt.Errorf("could not list metrics: %w", err)
+	}
+
+	return nil
+}
+
+// getMetricInfos reads the settings of the metrics matching a filter along
+// with the size of the hypertables storing them, and optionally their last
+// sample.
+func getMetricInfos(ctx context.Context, pool *DBPool, matches func(string) bool, lastSample bool) ([]metricInfo, error) {
+	settings, err := getMetricSettings(ctx, pool)
+	if err != nil {
+		return nil, err
+	}
+
+	var metrics []metricInfo
+	index := make(map[string]int)
+	for _, setting := range settings {
+		if matches(setting.Name) {
+			index[setting.Name] = len(metrics)
+			metrics = append(metrics, metricInfo{metricSettings: setting})
+		}
+	}
+	if len(metrics) == 0 {
+		return nil, nil
+	}
+
+	rows, err := pool.Query(ctx,
+		`SELECT m.metric_name, m.table_name, COALESCE(s.series, 0), COALESCE(z.bytes, 0)
+	 FROM _prom_catalog.metric m
+	 LEFT JOIN _timescaledb_catalog.hypertable h
+	    ON (h.schema_name = 'prom_data' AND h.table_name = m.table_name)
+	 LEFT JOIN
+	 (SELECT metric_id, count(*) AS series FROM _prom_catalog.series GROUP BY metric_id) s
+	    ON (s.metric_id = m.id)
+	 LEFT JOIN LATERAL
+	 (SELECT sum(pg_total_relation_size(to_regclass(format('%I.%I', c.schema_name, c.table_name))))::bigint AS bytes
+	  FROM _timescaledb_catalog.chunk c WHERE c.hypertable_id IN (h.id, h.compressed_hypertable_id)) z
+	    ON (true)`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var name, table string
+		var series, size int64
+		err = rows.Scan(&name, &table, &series, &size)
+		if err != nil {
+			return nil, err
+		}
+		if i, ok := index[name]; ok {
+			metrics[i].Table, metrics[i].Series, metrics[i].Size = table, series, size
+		}
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if lastSample {
+		err = getLastSamples(ctx, pool, metrics)
+		if err != nil {
+			return nil, fmt.Errorf("could not get the last samples: %w", err)
+		}
+	}
+
+	return metrics, nil
+}
+
+// getLastSamples reads the time of the last sample of every metric in one
+// query. It still has to look into the newest chunk of each metric, which
+// --no-last-sample skips on large databases.
+func getLastSamples(ctx context.Context, pool *DBPool, metrics []metricInfo) error {
+	parts := make([]string, len(metrics))
+	for i, metric := range metrics {
+		table := pgx.Identifier{"prom_data", metric.Table}.Sanitize()
+		parts[i] = fmt.Sprintf("SELECT %d AS i, (SELECT max(time) FROM %v) AS last_sample", i, table)
+	}
+
+	rows, err := pool.Query(ctx, strings.Join(parts, "\nUNION ALL\n"))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var i int
+		var lastSample *time.Time
+		err = rows.Scan(&i, &lastSample)
+		if err != nil {
+			return err
+		}
+		metrics[i].LastSample = lastSample
+	}
+
+	return rows.Err()
+}
+
+func sortMetricInfos(metrics []metricInfo, sortBy string) {
+	less := metricSortKeys[sortBy]
+	sort.SliceStable(metrics, func(i, j int) bool {
+		if less(metrics[i], metrics[j]) {
+			return true
+		}
+		if less(metrics[j], metrics[i]) {
+			return false
+		}
+		return metrics[i].Name < metrics[j].Name
+	})
+}
+
+func printMetricInfos(w io.Writer, metrics []metricInfo, lastSample bool) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+
+	header := "METRIC\tRETENTION\tCHUNK INTERVAL\tCOMPRESSION\tSERIES\tSIZE"
+	if lastSample {
+		header += "\tLAST SAMPLE"
+	}
+	fmt.Fprintln(tw, header)
+	for _, metric := range metrics {
+		fmt.Fprintf(tw, "%v\t%v\t%v\t%v\t%d\t%v", metric.Name,
+			withOverride(formatDays(metric.Retention), metric.CustomRetention),
+			withOverride(metric.ChunkInterval.String(), metric.CustomChunkInterval),
+			enabledString(metric.Compression), metric.Series, formatBytes(metric.Size))
+		if lastSample {
+			fmt.Fprintf(tw, "\t%v", formatLastSample(metric.LastSample))
+		}
+		fmt.Fprintln(tw)
+	}
+
+	return tw.Flush()
+}
+
+func writeMetricInfosCSV(w io.Writer, metrics []metricInfo) error {
+	writer := csv.NewWriter(w)
+
+	err := writer.Write([]string{"metric", "retention_days", "custom_retention", "chunk_interval", "custom_chunk_interval", "compression", "series", "size_bytes", "last_sample"})
+	if err != nil {
+		return err
+	}
+	for _, metric := range metrics {
+		lastSample := ""
+		if metric.LastSample != nil {
+			lastSample = metric.LastSample.UTC().Format(time.RFC3339)
+		}
+		err = writer.Write([]string{
+			metric.Name,
+			strconv.FormatFloat(fractionalDays(metric.Retention), 'f', -1, 64),
+			strconv.FormatBool(metric.CustomRetention),
+			metric.ChunkInterval.String(),
+			strconv.FormatBool(metric.CustomChunkInterval),
+			strconv.FormatBool(metric.Compression),
+			strconv.FormatInt(metric.Series, 10),
+			strconv.FormatInt(metric.Size, 10),
+			lastSample,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
+
+func formatLastSample(lastSample *time.Time) string {
+	if lastSample == nil {
+		return "never"
+	}
+	return lastSample.Local().Format(time.ANSIC)
+}
+
+// formatBytes prints a size with binary prefixes, like 1.5 GiB.
+func formatBytes(bytes int64) string {
+	const unit = 1024
+	if bytes < unit {
+		return fmt.Sprintf("%d B", bytes)
+	}
+	div, exp := int64(unit), 0
+	for n := bytes / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(bytes)/float64(div), "KMGTPE"[exp])
+}
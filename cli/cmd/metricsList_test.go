@@ -0,0 +1,136 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMetricNameMatcher(t *testing.T) {
+	tests := []struct {
+		pattern string
+		metric  string
+		matches bool
+	}{
+		{"node_*_bytes", "node_memory_bytes", true},
+		{"node_*_bytes", "node_memory_total", false},
+		{"/^node_.*_bytes$/", "node_memory_bytes", true},
+		{"/^up$/", "upstream", false},
+		{"up", "up", true},
+	}
+	for _, test := range tests {
+		matches, err := metricNameMatcher(test.pattern)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if matches(test.metric) != test.matches {
+			t.Fatalf("expected %v matching %v to be %v", test.pattern, test.metric, test.matches)
+		}
+	}
+
+	if _, err := metricNameMatcher("/(/"); err == nil {
+		t.Fatal("expected an invalid regular expression to be refused")
+	}
+	if _, err := metricNameMatcher("node_["); err == nil {
+		t.Fatal("expected an invalid glob to be refused")
+	}
+}
+
+func TestSortMetricInfos(t *testing.T) {
+	recent := time.Now()
+	old := recent.Add(-time.Hour)
+	metrics := []metricInfo{
+		{metricSettings: metricSettings{Name: "c"}, Size: 10, LastSample: &old},
+		{metricSettings: metricSettings{Name: "a"}, Size: 20},
+		{metricSettings: metricSettings{Name: "b"}, Size: 20, LastSample: &recent},
+	}
+
+	expected := map[string]string{
+		"name":        "a b c",
+		"size":        "a b c",
+		"series":      "a b c",
+		"last-sample": "b c a",
+	}
+	for sortBy, order := range expected {
+		sortMetricInfos(metrics, sortBy)
+		names := make([]string, 0, len(metrics))
+		for _, metric := range metrics {
+			names = append(names, metric.Name)
+		}
+		if strings.Join(names, " ") != order {
+			t.Fatalf("expected sorting by %v to give %v, got %v", sortBy, order, names)
+		}
+	}
+}
+
+func TestMetricInfoOutput(t *testing.T) {
+	lastSample := time.Date(2020, 10, 1, 12, 0, 0, 0, time.UTC)
+	metrics := []metricInfo{{
+		metricSettings: metricSettings{
+			Name:            "up",
+			Retention:       30 * 24 * time.Hour,
+			CustomRetention: true,
+			ChunkInterval:   8 * time.Hour,
+			Compression:     true,
+		},
+		Series:     42,
+		Size:       3 * 1024 * 1024 / 2,
+		LastSample: &lastSample,
+	}, {
+		metricSettings: metricSettings{Name: "down", Retention: 90 * 24 * time.Hour},
+	}, {
+		metricSettings: metricSettings{Name: "go_goroutines", Retention: 36 * time.Hour, CustomRetention: true},
+	}}
+
+	var table bytes.Buffer
+	if err := printMetricInfos(&table, metrics, true); err != nil {
+		t.Fatal(err)
+	}
+	for _, expected := range []string{"30 days (override)", "8h0m0s (default)", "enabled", "1.5 MiB", "90 days (default)", "never", "36h0m0s (override)"} {
+		if !strings.Contains(table.String(), expected) {
+			t.Fatalf("expected %q in the table, got\n%v", expected, table.String())
+		}
+	}
+
+	table.Reset()
+	if err := printMetricInfos(&table, metrics, false); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(table.String(), "LAST SAMPLE") || strings.Contains(table.String(), "never") {
+		t.Fatalf("expected no last sample column unless asked for, got\n%v", table.String())
+	}
+
+	var csv bytes.Buffer
+	if err := writeMetricInfosCSV(&csv, metrics); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(csv.String()), "\n")
+	if len(lines) != 4 || lines[1] != "up,30,true,8h0m0s,false,true,42,1572864,2020-10-01T12:00:00Z" || !strings.HasPrefix(lines[3], "go_goroutines,1.5,true,") {
+		t.Fatalf("unexpected CSV output\n%v", csv.String())
+	}
+
+	data, err := json.Marshal(metrics[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded map[string]interface{}
+	if err = json.Unmarshal(data, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if decoded["retentionDays"] != float64(30) || decoded["chunkInterval"] != "8h0m0s" || decoded["sizeBytes"] != float64(1572864) {
+		t.Fatalf("unexpected JSON output %v", string(data))
+	}
+
+	data, err = json.Marshal(metrics[2])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = json.Unmarshal(data, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if decoded["retentionDays"] != 1.5 {
+		t.Fatalf("expected a retention of 1.5 days, got %v", string(data))
+	}
+}
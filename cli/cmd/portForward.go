@@ -56,47 +56,42 @@ func portForward(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("could not port-forward: %w", err)
 	}
 
-	// Port-forward TimescaleDB
-	podName, err := KubeGetPodName(namespace, map[string]string{"release": name, "role": "master"})
+	ctx := cmd.Context()
+	client, err := getKubeClient()
 	if err != nil {
 		return fmt.Errorf("could not port-forward: %w", err)
 	}
 
-	_, err = KubePortForwardPod(namespace, podName, timescaledb, FORWARD_PORT_TSDB)
+	// Port-forward TimescaleDB
+	timescaledbComponent, err := getStackComponent(name, namespace, "timescaledb")
 	if err != nil {
 		return fmt.Errorf("could not port-forward: %w", err)
 	}
-
-	// Port-forward Grafana
-	serviceName, err := KubeGetServiceName(namespace, map[string]string{"app.kubernetes.io/instance": name, "app.kubernetes.io/name": "grafana"})
-	if err != nil {
-		return fmt.Errorf("could not port-forward: %w", err)
+	forwards := []*portForwardSupervisor{
+		newPodPortForward(client, namespace, timescaledbComponent.Name, timescaledb, timescaledbComponent.Port, timescaledbComponent.Primary),
 	}
 
-	_, err = KubePortForwardService(namespace, serviceName, grafana, FORWARD_PORT_GRAFANA)
-	if err != nil {
-		return fmt.Errorf("could not port-forward: %w", err)
+	// Port-forward Grafana, Prometheus, PromLens and the connector
+	for _, target := range []struct {
+		id    string
+		local int
+	}{{"grafana", grafana}, {"prometheus", prometheus}, {"promlens", promlens}, {"promscale", connector}} {
+		component, err := getStackComponent(name, namespace, target.id)
+		if err != nil {
+			return fmt.Errorf("could not port-forward: %w", err)
+		}
+
+		forward, err := newComponentPortForward(ctx, client, namespace, component, target.local)
+		if err != nil {
+			return err
+		}
+		forwards = append(forwards, forward)
 	}
 
-	// Port-forward Prometheus
-	serviceName, err = KubeGetServiceName(namespace, map[string]string{"release": name, "app": "prometheus", "component": "server"})
+	err = runPortForwards(ctx, forwards...)
 	if err != nil {
 		return fmt.Errorf("could not port-forward: %w", err)
 	}
 
-	_, err = KubePortForwardService(namespace, serviceName, prometheus, FORWARD_PORT_PROM)
-	if err != nil {
-		return fmt.Errorf("could not port-forward: %w", err)
-	}
-
-	if err := portForwardPromlens(promlens); err != nil {
-		return err
-	}
-
-	if err := portForwardConnector(connector); err != nil {
-		return err
-	}
-	select {}
-
 	return nil
 }
@@ -0,0 +1,227 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+)
+
+const PORT_FORWARD_CHECK_INTERVAL = 5 * time.Second
+const PORT_FORWARD_MIN_BACKOFF = time.Second
+const PORT_FORWARD_MAX_BACKOFF = 30 * time.Second
+
+// portForwardTarget returns the pods a forward may connect to and the
+// container port to forward to. It is called periodically, so that a forward
+// can move to another pod when its current one goes away.
+type portForwardTarget func(ctx context.Context) ([]string, int, error)
+
+// portForwardSupervisor keeps a port-forward alive for as long as a command
+// runs. It reconnects on the same local port whenever the connection to the
+// pod is lost or the pod is no longer one of the targets, for example after
+// a Patroni failover moved the role=master label to another pod.
+type portForwardSupervisor struct {
+	client    *KubeClient
+	namespace string
+	component string
+	local     int
+	target    portForwardTarget
+
+	podName string
+	session *portForwardSession
+}
+
+// newPodPortForward supervises a forward to the first Ready pod matching labelmap.
+func newPodPortForward(client *KubeClient, namespace, component string, local, remote int, labelmap map[string]string) *portForwardSupervisor {
+	return &portForwardSupervisor{
+		client:    client,
+		namespace: namespace,
+		component: component,
+		local:     local,
+		target: func(ctx context.Context) ([]string, int, error) {
+			pods, err := client.KubeGetPods(ctx, namespace, labelmap)
+			if err != nil {
+				return nil, 0, err
+			}
+
+			var podNames []string
+			for i := range pods {
+				if ready, _ := podReady(&pods[i]); ready {
+					podNames = append(podNames, pods[i].Name)
+				}
+			}
+			if len(podNames) == 0 {
+				return nil, 0, fmt.Errorf("no ready %v pod found", component)
+			}
+
+			return podNames, remote, nil
+		},
+	}
+}
+
+// newServicePortForward supervises a forward to a Ready pod behind the service.
+func newServicePortForward(client *KubeClient, namespace, component, serviceName string, local, port int) *portForwardSupervisor {
+	return &portForwardSupervisor{
+		client:    client,
+		namespace: namespace,
+		component: component,
+		local:     local,
+		target: func(ctx context.Context) ([]string, int, error) {
+			return client.KubeResolveServicePods(ctx, namespace, serviceName, port)
+		},
+	}
+}
+
+// newComponentPortForward supervises a forward to the service of a component.
+func newComponentPortForward(ctx context.Context, client *KubeClient, namespace string, component stackComponent, local int) (*portForwardSupervisor, error) {
+	serviceName, err := client.KubeGetServiceName(ctx, namespace, component.Service)
+	if err != nil {
+		return nil, fmt.Errorf("could not port-forward %v: %w", component.Name, err)
+	}
+
+	return newServicePortForward(client, namespace, component.Name, serviceName, local, component.Port), nil
+}
+
+// connect resolves the target and starts forwarding to the first of its pods.
+func (s *portForwardSupervisor) connect(ctx context.Context) error {
+	podNames, remote, err := s.target(ctx)
+	if err != nil {
+		return err
+	}
+
+	session, err := s.client.kubeStartPortForward(ctx, s.namespace, podNames[0], s.local, remote, os.Stdout)
+	if err != nil {
+		return err
+	}
+
+	s.podName = podNames[0]
+	s.session = session
+	return nil
+}
+
+// disconnect stops the current forward and waits for its local port to be released.
+func (s *portForwardSupervisor) disconnect() {
+	s.session.Stop()
+}
+
+// healthy reports whether the current pod still exists, is ready and is
+// still one of the targets. Errors talking to the API server are not held
+// against the forward, which may well keep working through a brief outage of
+// the control plane; they are logged and the next check tries again.
+func (s *portForwardSupervisor) healthy(ctx context.Context) error {
+	pod, err := s.client.KubeGetPod(ctx, s.namespace, s.podName)
+	if apierrors.IsNotFound(err) {
+		return fmt.Errorf("pod %v is gone", s.podName)
+	}
+	if err != nil {
+		log.Printf("Could not check %v port-forward, keeping it: %v\n", s.component, err)
+		return nil
+	}
+	if ready, _ := podReady(pod); !ready {
+		return fmt.Errorf("pod %v is not ready", s.podName)
+	}
+
+	podNames, _, err := s.target(ctx)
+	if err != nil {
+		log.Printf("Could not check %v port-forward, keeping it: %v\n", s.component, err)
+		return nil
+	}
+
+	for _, podName := range podNames {
+		if podName == s.podName {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("pod %v is no longer a %v target", s.podName, s.component)
+}
+
+// reconnect retries connect with exponential backoff until it succeeds or
+// the context is done.
+func (s *portForwardSupervisor) reconnect(ctx context.Context) bool {
+	backoff := PORT_FORWARD_MIN_BACKOFF
+	for {
+		select {
+		case <-ctx.Done():
+			return false
+		case <-time.After(backoff):
+		}
+
+		err := s.connect(ctx)
+		if err == nil {
+			log.Printf("Reconnected %v on port %d to pod %v\n", s.component, s.local, s.podName)
+			return true
+		}
+
+		log.Printf("Could not reconnect %v, retrying in %v: %v\n", s.component, backoff, err)
+		backoff *= 2
+		if backoff > PORT_FORWARD_MAX_BACKOFF {
+			backoff = PORT_FORWARD_MAX_BACKOFF
+		}
+	}
+}
+
+// run watches the established forward until the context is done.
+func (s *portForwardSupervisor) run(ctx context.Context) {
+	ticker := time.NewTicker(PORT_FORWARD_CHECK_INTERVAL)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			s.disconnect()
+			return
+		case <-s.session.done:
+			if ctx.Err() != nil {
+				return
+			}
+			err := s.session.err
+			if err == nil {
+				err = fmt.Errorf("lost connection to pod %v", s.podName)
+			}
+			log.Printf("%v port-forward broke, reconnecting: %v\n", s.component, err)
+		case <-ticker.C:
+			err := s.healthy(ctx)
+			if err == nil || ctx.Err() != nil {
+				continue
+			}
+			log.Printf("%v port-forward is stale, reconnecting: %v\n", s.component, err)
+			s.disconnect()
+		}
+
+		if !s.reconnect(ctx) {
+			return
+		}
+	}
+}
+
+// runPortForwards establishes every forward and then supervises them until
+// the context is done. If any forward can not be established initially, the
+// ones already started are torn down and the error is returned.
+func runPortForwards(ctx context.Context, forwards ...*portForwardSupervisor) error {
+	for i, s := range forwards {
+		err := s.connect(ctx)
+		if err != nil {
+			for _, started := range forwards[:i] {
+				started.disconnect()
+			}
+			return fmt.Errorf("could not port-forward %v: %w", s.component, err)
+		}
+	}
+
+	var wg sync.WaitGroup
+	for _, s := range forwards {
+		wg.Add(1)
+		go func(s *portForwardSupervisor) {
+			defer wg.Done()
+			s.run(ctx)
+		}(s)
+	}
+	wg.Wait()
+
+	return nil
+}
@@ -0,0 +1,101 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func readyPod(name string, labels map[string]string) *corev1.Pod {
+	pod := testPod(name, labels)
+	pod.Status.Phase = corev1.PodRunning
+	pod.Status.Conditions = []corev1.PodCondition{{Type: corev1.PodReady, Status: corev1.ConditionTrue}}
+	return pod
+}
+
+func TestPortForwardSupervisorFollowsMaster(t *testing.T) {
+	client := newFakeKubeClient(
+		readyPod("gg-timescaledb-0", map[string]string{"release": "gg", "role": "master"}),
+		readyPod("gg-timescaledb-1", map[string]string{"release": "gg", "role": "replica"}),
+	)
+	s := newPodPortForward(client, "ns", "TimescaleDB", 5432, 5432, map[string]string{"release": "gg", "role": "master"})
+
+	podNames, remote, err := s.target(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(podNames) != 1 || podNames[0] != "gg-timescaledb-0" || remote != 5432 {
+		t.Fatalf("expected gg-timescaledb-0:5432, got %v:%d", podNames, remote)
+	}
+
+	s.podName = podNames[0]
+	if err = s.healthy(context.Background()); err != nil {
+		t.Fatalf("expected the forward to the master to be healthy: %v", err)
+	}
+
+	// Simulate a Patroni failover
+	for podName, role := range map[string]string{"gg-timescaledb-0": "replica", "gg-timescaledb-1": "master"} {
+		pod, err := client.CoreV1().Pods("ns").Get(context.Background(), podName, metav1.GetOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		pod.Labels["role"] = role
+		if _, err = client.CoreV1().Pods("ns").Update(context.Background(), pod, metav1.UpdateOptions{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err = s.healthy(context.Background()); err == nil {
+		t.Fatal("expected the forward to the old master to be stale")
+	}
+
+	podNames, _, err = s.target(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if podNames[0] != "gg-timescaledb-1" {
+		t.Fatalf("expected the new master gg-timescaledb-1, got %v", podNames)
+	}
+}
+
+func TestPortForwardSupervisorHealthy(t *testing.T) {
+	labels := map[string]string{"release": "gg", "role": "master"}
+	client := newFakeKubeClient(readyPod("gg-timescaledb-0", labels))
+	s := newPodPortForward(client, "ns", "TimescaleDB", 5432, 5432, labels)
+	s.podName = "gg-timescaledb-0"
+
+	// The API server being unreachable says nothing about the forward.
+	fakeClient := client.Interface.(*fake.Clientset)
+	fakeClient.PrependReactor("*", "pods", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		return true, nil, errors.New("connection refused")
+	})
+	if err := s.healthy(context.Background()); err != nil {
+		t.Fatalf("expected a transient API error to keep the forward, got %v", err)
+	}
+	fakeClient.ReactionChain = fakeClient.ReactionChain[1:]
+
+	pod, err := client.CoreV1().Pods("ns").Get(context.Background(), "gg-timescaledb-0", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	pod.Status.Conditions[0].Status = corev1.ConditionFalse
+	if _, err = client.CoreV1().Pods("ns").Update(context.Background(), pod, metav1.UpdateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if err = s.healthy(context.Background()); err == nil || err.Error() != "pod gg-timescaledb-0 is not ready" {
+		t.Fatalf("expected the forward to an unready pod to be stale, got %v", err)
+	}
+
+	if err = client.CoreV1().Pods("ns").Delete(context.Background(), "gg-timescaledb-0", metav1.DeleteOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if err = s.healthy(context.Background()); err == nil || err.Error() != "pod gg-timescaledb-0 is gone" {
+		t.Fatalf("expected the forward to a deleted pod to be stale, got %v", err)
+	}
+}
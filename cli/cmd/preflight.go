@@ -0,0 +1,102 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/spf13/cobra"
+	"helm.sh/helm/v3/pkg/chart"
+	"helm.sh/helm/v3/pkg/cli/values"
+)
+
+// preflightCmd represents the preflight command
+var preflightCmd = &cobra.Command{
+	Use:   "preflight",
+	Short: "Checks that the cluster can run The Observability Stack before installing it",
+	Args:  cobra.ExactArgs(0),
+	RunE:  preflight,
+}
+
+func init() {
+	rootCmd.AddCommand(preflightCmd)
+	addHelmValuesFlags(preflightCmd)
+	addHelmChartFlags(preflightCmd)
+}
+
+func preflight(cmd *cobra.Command, args []string) error {
+	var err error
+
+	var valuesOptions *values.Options
+	valuesOptions, err = getHelmValuesOptions(cmd)
+	if err != nil {
+		return fmt.Errorf("could not run the pre-flight checks: %w", err)
+	}
+
+	var source *ChartSource
+	source, err = getChartSource(cmd)
+	if err != nil {
+		return fmt.Errorf("could not run the pre-flight checks: %w", err)
+	}
+
+	ctx := cmd.Context()
+	client, err := getKubeClient()
+	if err != nil {
+		return fmt.Errorf("could not run the pre-flight checks: %w", err)
+	}
+
+	helm, err := getHelmClient()
+	if err != nil {
+		return fmt.Errorf("could not run the pre-flight checks: %w", err)
+	}
+
+	chart, err := helm.HelmLoadChart(ctx, source)
+	if err != nil {
+		return fmt.Errorf("could not run the pre-flight checks: %w", err)
+	}
+
+	valuesOptions.Values = append([]string{"cli=true"}, valuesOptions.Values...)
+	vals, err := helm.HelmMergeValues(valuesOptions)
+	if err != nil {
+		return fmt.Errorf("could not run the pre-flight checks: %w", err)
+	}
+
+	err = checkPreflight(ctx, client, helm, chart, vals)
+	if err != nil {
+		return fmt.Errorf("could not run the pre-flight checks: %w", err)
+	}
+
+	fmt.Println("The cluster is ready for The Observability Stack")
+	return nil
+}
+
+// checkPreflight renders the chart, runs the pre-flight checks against the
+// cluster and prints their results.
+func checkPreflight(ctx context.Context, client *KubeClient, helm *HelmClient, chrt *chart.Chart, vals map[string]interface{}) error {
+	rendered, err := helm.HelmTemplate(ctx, name, chrt, vals)
+	if err != nil {
+		return err
+	}
+
+	checks, err := runPreflightChecks(ctx, client, helm, rendered)
+	if err != nil {
+		return err
+	}
+
+	fmt.Println("Running pre-flight checks")
+	failed := 0
+	for _, check := range checks {
+		switch {
+		case check.Err != nil:
+			fmt.Printf("  %v: %v\n", check.Name, check.Err)
+			failed++
+		case check.Warning != "":
+			fmt.Printf("  %v: warning: %v\n", check.Name, check.Warning)
+		default:
+			fmt.Printf("  %v: ok\n", check.Name)
+		}
+	}
+	if failed != 0 {
+		return fmt.Errorf("%d pre-flight checks failed", failed)
+	}
+	return nil
+}
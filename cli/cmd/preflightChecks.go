@@ -0,0 +1,448 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/Masterminds/semver/v3"
+	"helm.sh/helm/v3/pkg/release"
+	"helm.sh/helm/v3/pkg/releaseutil"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/yaml"
+)
+
+// minKubeVersion is the oldest Kubernetes version the stack is tested on,
+// used unless the chart declares its own kubeVersion.
+const minKubeVersion = ">= 1.15.0-0"
+
+// defaultStorageClassAnnotations mark the default StorageClass.
+var defaultStorageClassAnnotations = []string{
+	"storageclass.kubernetes.io/is-default-class",
+	"storageclass.beta.kubernetes.io/is-default-class",
+}
+
+type preflightCheck struct {
+	Name string
+	// Warning describes a problem that does not block the install.
+	Warning string
+	Err     error
+}
+
+// manifestWorkload is the part of a workload, e.g. a Deployment, the
+// pre-flight checks look at.
+type manifestWorkload struct {
+	Kind     string `json:"kind"`
+	Metadata struct {
+		Name string `json:"name"`
+	} `json:"metadata"`
+	Spec struct {
+		Replicas             *int32                         `json:"replicas"`
+		Template             corev1.PodTemplateSpec         `json:"template"`
+		VolumeClaimTemplates []corev1.PersistentVolumeClaim `json:"volumeClaimTemplates"`
+	} `json:"spec"`
+}
+
+// runPreflightChecks checks that the cluster can run a release rendered with
+// HelmTemplate before it is installed.
+func runPreflightChecks(ctx context.Context, client *KubeClient, helm *HelmClient, rendered *release.Release) ([]preflightCheck, error) {
+	var workloads []manifestWorkload
+	var claims []corev1.PersistentVolumeClaim
+	for _, doc := range releaseutil.SplitManifests(rendered.Manifest) {
+		var workload manifestWorkload
+		err := yaml.Unmarshal([]byte(doc), &workload)
+		if err != nil {
+			return nil, fmt.Errorf("could not parse the release manifest: %w", err)
+		}
+
+		switch workload.Kind {
+		case "PersistentVolumeClaim":
+			var claim corev1.PersistentVolumeClaim
+			err = yaml.Unmarshal([]byte(doc), &claim)
+			if err != nil {
+				return nil, fmt.Errorf("could not parse the release manifest: %w", err)
+			}
+			claims = append(claims, claim)
+		case "Deployment", "StatefulSet", "DaemonSet", "Job":
+			workloads = append(workloads, workload)
+			claims = append(claims, workload.Spec.VolumeClaimTemplates...)
+		}
+	}
+
+	var checks []preflightCheck
+	add := func(name string, warning string, err error) {
+		checks = append(checks, preflightCheck{Name: name, Warning: warning, Err: err})
+	}
+
+	add("Kubernetes version", "", checkKubeVersion(client, rendered.Chart.Metadata.KubeVersion))
+	add("Release name", "", checkReleaseName(ctx, helm))
+
+	namespaceExists, warning, err := checkNamespace(ctx, client)
+	add("Namespace", warning, err)
+
+	resources, err := helm.HelmManifestResources(ctx, rendered.Manifest)
+	if err != nil {
+		add("Existing resources", "", err)
+	} else {
+		add("Existing resources", "", checkResourceConflicts(resources))
+	}
+
+	warning, err = checkStorageClasses(ctx, client, claims)
+	add("Storage classes", warning, err)
+
+	warning, err = checkCapacity(ctx, client, workloads)
+	add("Resource capacity", warning, err)
+
+	add("RBAC permissions", "", checkPermissions(ctx, client, resources, namespaceExists))
+
+	return checks, nil
+}
+
+// checkKubeVersion checks the version of the cluster against the constraint
+// of the chart, or minKubeVersion.
+func checkKubeVersion(client *KubeClient, constraint string) error {
+	if constraint == "" {
+		constraint = minKubeVersion
+	}
+	constraints, err := semver.NewConstraint(constraint)
+	if err != nil {
+		return fmt.Errorf("invalid Kubernetes version constraint %v: %w", constraint, err)
+	}
+
+	info, err := client.Discovery().ServerVersion()
+	if err != nil {
+		return fmt.Errorf("could not get the Kubernetes version: %w", err)
+	}
+	version, err := semver.NewVersion(info.GitVersion)
+	if err != nil {
+		return fmt.Errorf("could not parse the Kubernetes version %v: %w", info.GitVersion, err)
+	}
+
+	if !constraints.Check(version) {
+		return fmt.Errorf("Kubernetes %v does not satisfy %v", info.GitVersion, constraint)
+	}
+	return nil
+}
+
+// checkReleaseName refuses to install over an existing release, including a
+// failed or uninstalled one whose history was kept.
+func checkReleaseName(ctx context.Context, helm *HelmClient) error {
+	history, err := helm.HelmHistory(ctx, name, 1)
+	if errors.Is(err, ErrReleaseNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if len(history) == 0 {
+		return nil
+	}
+
+	return fmt.Errorf("release %v already exists in namespace %v with status %v, use tobs upgrade or pick another --name", name, namespace, history[0].Info.Status)
+}
+
+// checkNamespace reports whether the namespace exists. A missing namespace
+// is created by the install, while a terminating one can not be installed to.
+func checkNamespace(ctx context.Context, client *KubeClient) (bool, string, error) {
+	ns, err := client.CoreV1().Namespaces().Get(ctx, namespace, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return false, fmt.Sprintf("namespace %v does not exist and will be created", namespace), nil
+	}
+	if apierrors.IsForbidden(err) {
+		return true, fmt.Sprintf("could not check namespace %v: %v", namespace, err), nil
+	}
+	if err != nil {
+		return false, "", err
+	}
+
+	if ns.Status.Phase == corev1.NamespaceTerminating {
+		return true, "", fmt.Errorf("namespace %v is being deleted", namespace)
+	}
+	return true, "", nil
+}
+
+// checkResourceConflicts refuses objects that already exist and that Helm
+// would not adopt into the release, e.g. the cluster roles of a release with
+// the same name in another namespace.
+func checkResourceConflicts(resources []ManifestResource) error {
+	var conflicts []string
+	for _, r := range resources {
+		if !r.Exists || (r.OwnerRelease == name && r.OwnerNamespace == namespace) {
+			continue
+		}
+
+		conflict := strings.ToLower(r.Kind) + "/" + r.Name
+		if r.Namespace != "" {
+			conflict += " in namespace " + r.Namespace
+		}
+		if r.OwnerRelease != "" {
+			conflict += fmt.Sprintf(" (owned by release %v in namespace %v)", r.OwnerRelease, r.OwnerNamespace)
+		}
+		conflicts = append(conflicts, conflict)
+	}
+
+	if len(conflicts) != 0 {
+		return fmt.Errorf("already exist: %v", strings.Join(conflicts, ", "))
+	}
+	return nil
+}
+
+// checkStorageClasses checks that every PVC can be provisioned, either by its
+// StorageClass or by the default one, and warns about classes that do not
+// allow the volumes to be resized later.
+func checkStorageClasses(ctx context.Context, client *KubeClient, claims []corev1.PersistentVolumeClaim) (string, error) {
+	if len(claims) == 0 {
+		return "", nil
+	}
+
+	classes, err := client.StorageV1().StorageClasses().List(ctx, metav1.ListOptions{})
+	if apierrors.IsForbidden(err) {
+		return fmt.Sprintf("could not list storage classes: %v", err), nil
+	}
+	if err != nil {
+		return "", err
+	}
+
+	expandable := make(map[string]bool)
+	defaultClass := ""
+	for _, class := range classes.Items {
+		expandable[class.Name] = class.AllowVolumeExpansion != nil && *class.AllowVolumeExpansion
+		for _, annotation := range defaultStorageClassAnnotations {
+			if class.Annotations[annotation] == "true" {
+				defaultClass = class.Name
+			}
+		}
+	}
+
+	var problems []string
+	notExpandable := make(map[string]bool)
+	for _, claim := range claims {
+		className := defaultClass
+		if claim.Spec.StorageClassName != nil {
+			className = *claim.Spec.StorageClassName
+		}
+
+		switch _, exists := expandable[className]; {
+		case claim.Spec.StorageClassName != nil && className == "":
+			// The claim binds to a pre-provisioned volume.
+			continue
+		case className == "":
+			problems = append(problems, fmt.Sprintf("PVC %v needs a default StorageClass, but the cluster has none, set its storageClass in the values", claim.Name))
+		case !exists:
+			problems = append(problems, fmt.Sprintf("PVC %v uses StorageClass %v, which does not exist", claim.Name, className))
+		case !expandable[className]:
+			notExpandable[className] = true
+		}
+	}
+
+	if len(problems) != 0 {
+		return "", errors.New(strings.Join(problems, "; "))
+	}
+
+	var warnings []string
+	for className := range notExpandable {
+		warnings = append(warnings, fmt.Sprintf("StorageClass %v does not allow volume expansion, its PVCs can not be resized later", className))
+	}
+	sort.Strings(warnings)
+	return strings.Join(warnings, "; "), nil
+}
+
+// checkCapacity checks that the resources the workloads request fit into
+// what the schedulable nodes have left, both in total and for the largest
+// pod on a single node.
+func checkCapacity(ctx context.Context, client *KubeClient, workloads []manifestWorkload) (string, error) {
+	nodes, err := client.CoreV1().Nodes().List(ctx, metav1.ListOptions{})
+	if apierrors.IsForbidden(err) {
+		return fmt.Sprintf("could not list nodes: %v", err), nil
+	}
+	if err != nil {
+		return "", err
+	}
+
+	free := make(map[string]corev1.ResourceList)
+	for _, node := range nodes.Items {
+		if nodeSchedulable(node) {
+			free[node.Name] = node.Status.Allocatable.DeepCopy()
+		}
+	}
+	if len(free) == 0 {
+		return "", errors.New("no node is ready and schedulable")
+	}
+
+	warning := ""
+	pods, err := client.CoreV1().Pods("").List(ctx, metav1.ListOptions{FieldSelector: "status.phase!=Succeeded,status.phase!=Failed"})
+	if apierrors.IsForbidden(err) {
+		warning = "could not list the pods of other namespaces, only checking against the allocatable resources"
+	} else if err != nil {
+		return "", err
+	} else {
+		for _, pod := range pods.Items {
+			if list, ok := free[pod.Spec.NodeName]; ok && pod.Status.Phase != corev1.PodSucceeded && pod.Status.Phase != corev1.PodFailed {
+				subtractResources(list, podRequests(pod.Spec))
+			}
+		}
+	}
+
+	requested := corev1.ResourceList{}
+	largest := corev1.ResourceList{}
+	largestNames := make(map[corev1.ResourceName]string)
+	for _, workload := range workloads {
+		requests := podRequests(workload.Spec.Template.Spec)
+		if workload.Kind == "DaemonSet" {
+			for _, list := range free {
+				subtractResources(list, requests)
+			}
+			continue
+		}
+
+		replicas := int32(1)
+		if workload.Spec.Replicas != nil {
+			replicas = *workload.Spec.Replicas
+		}
+		for i := int32(0); i < replicas; i++ {
+			addResources(requested, requests)
+		}
+		for resourceName, quantity := range requests {
+			if quantity.Cmp(largest[resourceName]) > 0 {
+				largest[resourceName] = quantity.DeepCopy()
+				largestNames[resourceName] = strings.ToLower(workload.Kind) + "/" + workload.Metadata.Name
+			}
+		}
+	}
+
+	available := corev1.ResourceList{}
+	maxFree := corev1.ResourceList{}
+	for _, list := range free {
+		addResources(available, list)
+		for _, resourceName := range []corev1.ResourceName{corev1.ResourceCPU, corev1.ResourceMemory} {
+			if quantity, ok := list[resourceName]; ok && quantity.Cmp(maxFree[resourceName]) > 0 {
+				maxFree[resourceName] = quantity.DeepCopy()
+			}
+		}
+	}
+
+	var problems []string
+	for _, resourceName := range []corev1.ResourceName{corev1.ResourceCPU, corev1.ResourceMemory} {
+		want := requested[resourceName]
+		have := available[resourceName]
+		if want.Cmp(have) > 0 {
+			problems = append(problems, fmt.Sprintf("the stack requests %v %v, but the nodes have %v left", want.String(), resourceName, have.String()))
+			continue
+		}
+		want = largest[resourceName]
+		have = maxFree[resourceName]
+		if want.Cmp(have) > 0 {
+			problems = append(problems, fmt.Sprintf("a pod of %v requests %v %v, but no node has more than %v left", largestNames[resourceName], want.String(), resourceName, have.String()))
+		}
+	}
+
+	if len(problems) != 0 {
+		return warning, errors.New(strings.Join(problems, "; "))
+	}
+	return warning, nil
+}
+
+// checkPermissions checks that the current user may create every object of
+// the release, the secrets Helm stores the release in and, if missing, the
+// namespace.
+func checkPermissions(ctx context.Context, client *KubeClient, resources []ManifestResource, namespaceExists bool) error {
+	attributes := []authorizationv1.ResourceAttributes{{Namespace: namespace, Verb: "create", Resource: "secrets"}}
+	if !namespaceExists {
+		attributes = append(attributes, authorizationv1.ResourceAttributes{Verb: "create", Resource: "namespaces"})
+	}
+	seen := make(map[authorizationv1.ResourceAttributes]bool)
+	for _, r := range resources {
+		attribute := authorizationv1.ResourceAttributes{Namespace: r.Namespace, Verb: "create", Group: r.Group, Resource: r.Resource}
+		if !seen[attribute] {
+			seen[attribute] = true
+			attributes = append(attributes, attribute)
+		}
+	}
+
+	var denied []string
+	for i := range attributes {
+		review := &authorizationv1.SelfSubjectAccessReview{
+			Spec: authorizationv1.SelfSubjectAccessReviewSpec{ResourceAttributes: &attributes[i]},
+		}
+		review, err := client.AuthorizationV1().SelfSubjectAccessReviews().Create(ctx, review, metav1.CreateOptions{})
+		if err != nil {
+			return fmt.Errorf("could not check permissions: %w", err)
+		}
+		if review.Status.Allowed {
+			continue
+		}
+
+		resourceName := attributes[i].Resource
+		if attributes[i].Group != "" {
+			resourceName += "." + attributes[i].Group
+		}
+		if attributes[i].Namespace != "" {
+			resourceName += " in namespace " + attributes[i].Namespace
+		}
+		denied = append(denied, resourceName)
+	}
+
+	if len(denied) != 0 {
+		return fmt.Errorf("not allowed to create %v", strings.Join(denied, ", "))
+	}
+	return nil
+}
+
+// nodeSchedulable reports whether new pods can be scheduled on a node.
+func nodeSchedulable(node corev1.Node) bool {
+	if node.Spec.Unschedulable {
+		return false
+	}
+	for _, condition := range node.Status.Conditions {
+		if condition.Type == corev1.NodeReady {
+			return condition.Status == corev1.ConditionTrue
+		}
+	}
+	return false
+}
+
+// podRequests returns the resources the scheduler reserves for a pod: the
+// sum of its containers' requests, or the largest init container request if
+// that is higher.
+func podRequests(spec corev1.PodSpec) corev1.ResourceList {
+	requests := corev1.ResourceList{}
+	for _, container := range spec.Containers {
+		addResources(requests, container.Resources.Requests)
+	}
+	for _, container := range spec.InitContainers {
+		for resourceName, quantity := range container.Resources.Requests {
+			if quantity.Cmp(requests[resourceName]) > 0 {
+				requests[resourceName] = quantity.DeepCopy()
+			}
+		}
+	}
+	return requests
+}
+
+func addResources(list, add corev1.ResourceList) {
+	for resourceName, quantity := range add {
+		sum := list[resourceName]
+		sum.Add(quantity)
+		list[resourceName] = sum
+	}
+}
+
+func subtractResources(list, sub corev1.ResourceList) {
+	for resourceName, quantity := range sub {
+		if _, ok := list[resourceName]; !ok {
+			continue
+		}
+		difference := list[resourceName]
+		difference.Sub(quantity)
+		if difference.Sign() < 0 {
+			difference = resource.Quantity{Format: difference.Format}
+		}
+		list[resourceName] = difference
+	}
+}
@@ -0,0 +1,237 @@
+package cmd
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"helm.sh/helm/v3/pkg/chart"
+	appsv1 "k8s.io/api/apps/v1"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	corev1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/version"
+	fakediscovery "k8s.io/client-go/discovery/fake"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+	"sigs.k8s.io/yaml"
+)
+
+func TestCheckKubeVersion(t *testing.T) {
+	client := newFakeKubeClient()
+	discovery := client.Discovery().(*fakediscovery.FakeDiscovery)
+
+	discovery.FakedServerVersion = &version.Info{GitVersion: "v1.18.8-gke.1"}
+	if err := checkKubeVersion(client, ""); err != nil {
+		t.Fatalf("expected 1.18 to be supported: %v", err)
+	}
+
+	discovery.FakedServerVersion = &version.Info{GitVersion: "v1.14.3"}
+	if err := checkKubeVersion(client, ""); err == nil {
+		t.Fatal("expected 1.14 to be refused")
+	}
+	if err := checkKubeVersion(client, ">= 1.13.0"); err != nil {
+		t.Fatalf("expected the chart constraint to be used: %v", err)
+	}
+}
+
+func TestCheckReleaseName(t *testing.T) {
+	name, namespace = "gg", "ns"
+	helm := newMemoryHelmClient()
+
+	if err := checkReleaseName(context.Background(), helm); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := helm.HelmInstall(context.Background(), "gg", testChart(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = checkReleaseName(context.Background(), helm); err == nil || !strings.Contains(err.Error(), "already exists") {
+		t.Fatalf("expected the existing release to be reported, got %v", err)
+	}
+}
+
+func TestCheckResourceConflicts(t *testing.T) {
+	name, namespace = "gg", "ns"
+
+	err := checkResourceConflicts([]ManifestResource{
+		{Kind: "ConfigMap", Name: "gg-new", Namespace: "ns"},
+		{Kind: "Secret", Name: "gg-adopted", Namespace: "ns", Exists: true, OwnerRelease: "gg", OwnerNamespace: "ns"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = checkResourceConflicts([]ManifestResource{
+		{Kind: "ClusterRole", Name: "gg-prometheus", Exists: true, OwnerRelease: "gg", OwnerNamespace: "other"},
+	})
+	if err == nil || !strings.Contains(err.Error(), "clusterrole/gg-prometheus (owned by release gg in namespace other)") {
+		t.Fatalf("expected a conflict with the other release, got %v", err)
+	}
+}
+
+func TestCheckStorageClasses(t *testing.T) {
+	claim := func(name string, className *string) corev1.PersistentVolumeClaim {
+		return corev1.PersistentVolumeClaim{ObjectMeta: metav1.ObjectMeta{Name: name}, Spec: corev1.PersistentVolumeClaimSpec{StorageClassName: className}}
+	}
+	static, fast := "", "fast"
+	expandable := true
+	standard := &storagev1.StorageClass{
+		ObjectMeta: metav1.ObjectMeta{Name: "standard", Annotations: map[string]string{"storageclass.kubernetes.io/is-default-class": "true"}},
+	}
+	fastClass := &storagev1.StorageClass{ObjectMeta: metav1.ObjectMeta{Name: "fast"}, AllowVolumeExpansion: &expandable}
+
+	_, err := checkStorageClasses(context.Background(), newFakeKubeClient(), []corev1.PersistentVolumeClaim{claim("data", nil)})
+	if err == nil || !strings.Contains(err.Error(), "needs a default StorageClass") {
+		t.Fatalf("expected the missing default StorageClass to be reported, got %v", err)
+	}
+
+	warning, err := checkStorageClasses(context.Background(), newFakeKubeClient(), []corev1.PersistentVolumeClaim{claim("data", &static)})
+	if err != nil || warning != "" {
+		t.Fatalf("expected a pre-provisioned volume to pass, got %q %v", warning, err)
+	}
+
+	warning, err = checkStorageClasses(context.Background(), newFakeKubeClient(standard, fastClass), []corev1.PersistentVolumeClaim{claim("data", nil), claim("wal", &fast)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(warning, "StorageClass standard does not allow volume expansion") || strings.Contains(warning, "fast") {
+		t.Fatalf("expected a warning about standard only, got %q", warning)
+	}
+
+	_, err = checkStorageClasses(context.Background(), newFakeKubeClient(standard), []corev1.PersistentVolumeClaim{claim("wal", &fast)})
+	if err == nil || !strings.Contains(err.Error(), "StorageClass fast, which does not exist") {
+		t.Fatalf("expected the missing StorageClass to be reported, got %v", err)
+	}
+}
+
+func TestCheckCapacity(t *testing.T) {
+	node := &corev1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: "node"},
+		Status: corev1.NodeStatus{
+			Allocatable: corev1.ResourceList{
+				corev1.ResourceCPU:    resource.MustParse("2"),
+				corev1.ResourceMemory: resource.MustParse("3Gi"),
+			},
+			Conditions: []corev1.NodeCondition{{Type: corev1.NodeReady, Status: corev1.ConditionTrue}},
+		},
+	}
+	running := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "running", Namespace: "kube-system"},
+		Spec: corev1.PodSpec{NodeName: "node", Containers: []corev1.Container{{
+			Resources: corev1.ResourceRequirements{Requests: corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("512Mi")}},
+		}}},
+		Status: corev1.PodStatus{Phase: corev1.PodRunning},
+	}
+	workload := func(kind string, replicas int32, memory string) manifestWorkload {
+		var w manifestWorkload
+		w.Kind = kind
+		w.Metadata.Name = "gg-" + strings.ToLower(kind)
+		w.Spec.Replicas = &replicas
+		w.Spec.Template.Spec.Containers = []corev1.Container{{
+			Resources: corev1.ResourceRequirements{Requests: corev1.ResourceList{corev1.ResourceMemory: resource.MustParse(memory)}},
+		}}
+		return w
+	}
+	client := newFakeKubeClient(node, running)
+
+	_, err := checkCapacity(context.Background(), client, []manifestWorkload{workload("Deployment", 2, "1Gi")})
+	if err != nil {
+		t.Fatalf("expected 2Gi to fit into 2.5Gi: %v", err)
+	}
+
+	_, err = checkCapacity(context.Background(), client, []manifestWorkload{workload("Deployment", 1, "2Gi"), workload("DaemonSet", 1, "1Gi")})
+	if err == nil || !strings.Contains(err.Error(), "the stack requests 2Gi memory") {
+		t.Fatalf("expected the memory shortage to be reported, got %v", err)
+	}
+
+	unready := node.DeepCopy()
+	unready.Status.Conditions[0].Status = corev1.ConditionFalse
+	_, err = checkCapacity(context.Background(), newFakeKubeClient(unready), nil)
+	if err == nil {
+		t.Fatal("expected an error without schedulable nodes")
+	}
+}
+
+func TestCheckPermissions(t *testing.T) {
+	namespace = "ns"
+	client := newFakeKubeClient()
+	client.Interface.(*fake.Clientset).PrependReactor("create", "selfsubjectaccessreviews", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		review := action.(k8stesting.CreateAction).GetObject().(*authorizationv1.SelfSubjectAccessReview)
+		review.Status.Allowed = review.Spec.ResourceAttributes.Resource != "clusterroles"
+		return true, review, nil
+	})
+
+	resources := []ManifestResource{
+		{Kind: "Deployment", Name: "gg-promscale", Namespace: "ns", Group: "apps", Resource: "deployments"},
+		{Kind: "ClusterRole", Name: "gg-prometheus", Group: "rbac.authorization.k8s.io", Resource: "clusterroles"},
+	}
+	err := checkPermissions(context.Background(), client, resources[:1], true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = checkPermissions(context.Background(), client, resources, true)
+	if err == nil || err.Error() != "not allowed to create clusterroles.rbac.authorization.k8s.io" {
+		t.Fatalf("expected creating cluster roles to be denied, got %v", err)
+	}
+}
+
+func TestRunPreflightChecks(t *testing.T) {
+	name, namespace = "gg", "ns"
+	helm := newMemoryHelmClient()
+
+	chrt := testChart()
+	statefulSet := appsv1.StatefulSet{
+		TypeMeta:   metav1.TypeMeta{APIVersion: "apps/v1", Kind: "StatefulSet"},
+		ObjectMeta: metav1.ObjectMeta{Name: "gg-timescaledb"},
+		Spec: appsv1.StatefulSetSpec{VolumeClaimTemplates: []corev1.PersistentVolumeClaim{{
+			ObjectMeta: metav1.ObjectMeta{Name: "storage-volume"},
+		}}},
+	}
+	data, err := yaml.Marshal(statefulSet)
+	if err != nil {
+		t.Fatal(err)
+	}
+	chrt.Templates = append(chrt.Templates, &chart.File{Name: "templates/statefulset.yaml", Data: data})
+
+	rendered, err := helm.HelmTemplate(context.Background(), name, chrt, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	client := newFakeKubeClient()
+	client.Discovery().(*fakediscovery.FakeDiscovery).FakedServerVersion = &version.Info{GitVersion: "v1.18.8"}
+	client.Interface.(*fake.Clientset).PrependReactor("create", "selfsubjectaccessreviews", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		review := action.(k8stesting.CreateAction).GetObject().(*authorizationv1.SelfSubjectAccessReview)
+		review.Status.Allowed = review.Spec.ResourceAttributes.Resource != "namespaces"
+		return true, review, nil
+	})
+	checks, err := runPreflightChecks(context.Background(), client, helm, rendered)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	results := make(map[string]preflightCheck)
+	for _, check := range checks {
+		results[check.Name] = check
+	}
+	if results["Kubernetes version"].Err != nil || results["Release name"].Err != nil {
+		t.Fatalf("expected the version and name checks to pass, got %+v", checks)
+	}
+	if !strings.Contains(results["Namespace"].Warning, "will be created") {
+		t.Fatalf("expected a warning about the missing namespace, got %+v", results["Namespace"])
+	}
+	if err := results["Storage classes"].Err; err == nil || !strings.Contains(err.Error(), "PVC storage-volume needs a default StorageClass") {
+		t.Fatalf("expected the volume claim template to need a default StorageClass, got %v", err)
+	}
+	if results["Resource capacity"].Err == nil {
+		t.Fatal("expected the capacity check to fail without nodes")
+	}
+	if err := results["RBAC permissions"].Err; err == nil || !strings.Contains(err.Error(), "namespaces") {
+		t.Fatalf("expected creating the namespace to be denied, got %v", err)
+	}
+}
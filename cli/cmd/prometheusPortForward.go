@@ -7,7 +7,6 @@ import (
 )
 
 const LISTEN_PORT_PROM = 9090
-const FORWARD_PORT_PROM = 9090
 
 // prometheusPortForwardCmd represents the prometheus port-forward command
 var prometheusPortForwardCmd = &cobra.Command{
@@ -31,17 +30,26 @@ func prometheusPortForward(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("could not port-forward Prometheus: %w", err)
 	}
 
-	serviceName, err := KubeGetServiceName(namespace, map[string]string{"release": name, "app": "prometheus", "component": "server"})
+	ctx := cmd.Context()
+	client, err := getKubeClient()
 	if err != nil {
 		return fmt.Errorf("could not port-forward Prometheus: %w", err)
 	}
 
-	_, err = KubePortForwardService(namespace, serviceName, port, FORWARD_PORT_PROM)
+	component, err := getStackComponent(name, namespace, "prometheus")
 	if err != nil {
 		return fmt.Errorf("could not port-forward Prometheus: %w", err)
 	}
 
-	select {}
+	forward, err := newComponentPortForward(ctx, client, namespace, component, port)
+	if err != nil {
+		return err
+	}
+
+	err = runPortForwards(ctx, forward)
+	if err != nil {
+		return fmt.Errorf("could not port-forward Prometheus: %w", err)
+	}
 
 	return nil
 }
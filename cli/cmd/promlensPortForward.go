@@ -7,9 +7,7 @@ import (
 )
 
 const LISTEN_PORT_PROMLENS = 8081
-const FORWARD_PORT_PROMLENS = 8080
 const LISTEN_PORT_CONNECTOR = 9201
-const FORWARD_PORT_CONNECTOR = 9201
 
 // promlensPortForwardCmd represents the PromLens port-forward command
 var promlensPortForwardCmd = &cobra.Command{
@@ -25,34 +23,6 @@ func init() {
 	promlensPortForwardCmd.Flags().IntP("port-connector", "c", LISTEN_PORT_CONNECTOR, "Port to listen for the connector")
 }
 
-func portForwardPromlens(listenPort int) error {
-	serviceNamePromlens, err := KubeGetServiceName(namespace, map[string]string{"release": name, "component": "promlens"})
-	if err != nil {
-		return fmt.Errorf("could not port-forward PromLens: %w", err)
-	}
-
-	_, err = KubePortForwardService(namespace, serviceNamePromlens, listenPort, FORWARD_PORT_PROMLENS)
-	if err != nil {
-		return fmt.Errorf("could not port-forward PromLens: %w", err)
-	}
-
-	return nil
-}
-
-func portForwardConnector(listenPort int) error {
-	serviceNameConnector, err := KubeGetServiceName(namespace, map[string]string{"release": name, "app": name + "-promscale"})
-	if err != nil {
-		return fmt.Errorf("could not port-forward PromLens: %w", err)
-	}
-
-	_, err = KubePortForwardService(namespace, serviceNameConnector, listenPort, FORWARD_PORT_CONNECTOR)
-	if err != nil {
-		return fmt.Errorf("could not port-forward PromLens: %w", err)
-	}
-
-	return nil
-}
-
 func promlensPortForward(cmd *cobra.Command, args []string) error {
 	var err error
 
@@ -68,15 +38,33 @@ func promlensPortForward(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("could not port-forward PromLens: %w", err)
 	}
 
-	if err := portForwardPromlens(port); err != nil {
-		return err
+	ctx := cmd.Context()
+	client, err := getKubeClient()
+	if err != nil {
+		return fmt.Errorf("could not port-forward PromLens: %w", err)
 	}
 
-	if err := portForwardConnector(portConnector); err != nil {
-		return err
+	var forwards []*portForwardSupervisor
+	for _, target := range []struct {
+		id    string
+		local int
+	}{{"promlens", port}, {"promscale", portConnector}} {
+		component, err := getStackComponent(name, namespace, target.id)
+		if err != nil {
+			return fmt.Errorf("could not port-forward PromLens: %w", err)
+		}
+
+		forward, err := newComponentPortForward(ctx, client, namespace, component, target.local)
+		if err != nil {
+			return err
+		}
+		forwards = append(forwards, forward)
 	}
 
-	select {}
+	err = runPortForwards(ctx, forwards...)
+	if err != nil {
+		return fmt.Errorf("could not port-forward PromLens: %w", err)
+	}
 
 	return nil
 }
@@ -1,7 +1,6 @@
 package cmd
 
 import (
-	"context"
 	"fmt"
 
 	"github.com/spf13/cobra"
@@ -24,7 +23,13 @@ func retentionGet(cmd *cobra.Command, args []string) error {
 
 	metric := args[0]
 
-	pool, err := OpenConnectionToDB(namespace, name, user, dbname, FORWARD_PORT_TSDB)
+	ctx := cmd.Context()
+	client, err := getKubeClient()
+	if err != nil {
+		return fmt.Errorf("could not get retention period for %v: %w", metric, err)
+	}
+
+	pool, err := OpenConnectionToDB(ctx, client, namespace, name, user, dbname)
 	if err != nil {
 		return fmt.Errorf("could not get retention period for %v: %w", metric, err)
 	}
@@ -32,7 +37,7 @@ func retentionGet(cmd *cobra.Command, args []string) error {
 
 	fmt.Printf("Getting retention period for %v\n", metric)
 	var days int
-	err = pool.QueryRow(context.Background(), "SELECT EXTRACT(day FROM _prom_catalog.get_metric_retention_period($1))", metric).Scan(&days)
+	err = pool.QueryRow(ctx, "SELECT EXTRACT(day FROM _prom_catalog.get_metric_retention_period($1))", metric).Scan(&days)
 	if err != nil {
 		return fmt.Errorf("could not get retention period for %v: %w", metric, err)
 	}
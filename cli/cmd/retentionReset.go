@@ -1,39 +1,67 @@
 package cmd
 
 import (
-	"context"
 	"fmt"
+	"os"
 
 	"github.com/spf13/cobra"
 )
 
 // retentionResetCmd represents the retention reset command
 var retentionResetCmd = &cobra.Command{
-	Use:   "reset <metric>",
-	Short: "Resets data retention period to default for a specific metric",
-	Args:  cobra.ExactArgs(1),
+	Use:   "reset (<metric> | --match <pattern> | --all)",
+	Short: "Resets data retention period to default for a specific metric, or all matching metrics",
+	Args:  metricSelectionArgs(0),
 	RunE:  retentionReset,
 }
 
 func init() {
 	retentionCmd.AddCommand(retentionResetCmd)
+	addMetricSelectionFlags(retentionResetCmd)
 }
 
 func retentionReset(cmd *cobra.Command, args []string) error {
 	var err error
 
-	metric := args[0]
+	var selection *metricSelection
+	selection, _, err = getMetricSelection(cmd, args)
+	if err != nil {
+		return fmt.Errorf("could not reset retention period: %w", err)
+	}
+
+	ctx := cmd.Context()
+	client, err := getKubeClient()
+	if err != nil {
+		return fmt.Errorf("could not reset retention period for %v: %w", selection, err)
+	}
 
-	pool, err := OpenConnectionToDB(namespace, name, user, dbname, FORWARD_PORT_TSDB)
+	pool, err := OpenConnectionToDB(ctx, client, namespace, name, user, dbname)
 	if err != nil {
-		return fmt.Errorf("could not reset retention period for %v: %w", metric, err)
+		return fmt.Errorf("could not reset retention period for %v: %w", selection, err)
 	}
 	defer pool.Close()
 
-	fmt.Printf("Resetting retention period for %v back to default\n", metric)
-	_, err = pool.Exec(context.Background(), "SELECT prom_api.reset_metric_retention_period($1)", metric)
+	metrics, err := selection.expand(ctx, pool)
+	if err != nil {
+		return fmt.Errorf("could not reset retention period for %v: %w", selection, err)
+	}
+
+	proceed, err := selection.confirm(os.Stdin, os.Stdout, metrics, "reset the retention period to the default")
+	if err != nil {
+		return fmt.Errorf("could not reset retention period for %v: %w", selection, err)
+	}
+	if !proceed {
+		fmt.Println("Aborted")
+		return nil
+	}
+
+	fmt.Printf("Resetting retention period for %v back to default\n", selection)
+	err = selection.apply(os.Stdout, metrics, func(metric string) error {
+		_, err := pool.Exec(ctx, "SELECT prom_api.reset_metric_retention_period($1)", metric)
+		return err
+	})
 	if err != nil {
-		return fmt.Errorf("could not reset retention period for %v: %w", metric, err)
+		return fmt.Errorf("could not reset retention period for %v: %w", selection, err)
 	}
 
 	return nil
@@ -1,40 +1,68 @@
 package cmd
 
 import (
-	"context"
 	"fmt"
+	"os"
 
 	"github.com/spf13/cobra"
 )
 
 // retentionSetCmd represents the metrics retention set command
 var retentionSetCmd = &cobra.Command{
-	Use:   "set <metric> <days>",
-	Short: "Sets data retention period in days for a specific metric",
-	Args:  cobra.ExactArgs(2),
+	Use:   "set (<metric> | --match <pattern> | --all) <days>",
+	Short: "Sets data retention period in days for a specific metric, or all matching metrics",
+	Args:  metricSelectionArgs(1),
 	RunE:  retentionSet,
 }
 
 func init() {
 	retentionCmd.AddCommand(retentionSetCmd)
+	addMetricSelectionFlags(retentionSetCmd)
 }
 
 func retentionSet(cmd *cobra.Command, args []string) error {
 	var err error
 
-	metric := args[0]
-	retention_period := args[1]
+	var selection *metricSelection
+	selection, args, err = getMetricSelection(cmd, args)
+	if err != nil {
+		return fmt.Errorf("could not set retention period: %w", err)
+	}
+	retention_period := args[0]
+
+	ctx := cmd.Context()
+	client, err := getKubeClient()
+	if err != nil {
+		return fmt.Errorf("could not set retention period for %v: %w", selection, err)
+	}
 
-	pool, err := OpenConnectionToDB(namespace, name, user, dbname, FORWARD_PORT_TSDB)
+	pool, err := OpenConnectionToDB(ctx, client, namespace, name, user, dbname)
 	if err != nil {
-		return fmt.Errorf("could not set retention period for %v: %w", metric, err)
+		return fmt.Errorf("could not set retention period for %v: %w", selection, err)
 	}
 	defer pool.Close()
 
-	fmt.Printf("Setting retention period for %v to %v days\n", metric, retention_period)
-	_, err = pool.Exec(context.Background(), "SELECT prom_api.set_metric_retention_period($1, INTERVAL '1 day' * $2)", metric, retention_period)
+	metrics, err := selection.expand(ctx, pool)
+	if err != nil {
+		return fmt.Errorf("could not set retention period for %v: %w", selection, err)
+	}
+
+	proceed, err := selection.confirm(os.Stdin, os.Stdout, metrics, fmt.Sprintf("set the retention period to %v days", retention_period))
+	if err != nil {
+		return fmt.Errorf("could not set retention period for %v: %w", selection, err)
+	}
+	if !proceed {
+		fmt.Println("Aborted")
+		return nil
+	}
+
+	fmt.Printf("Setting retention period for %v to %v days\n", selection, retention_period)
+	err = selection.apply(os.Stdout, metrics, func(metric string) error {
+		_, err := pool.Exec(ctx, "SELECT prom_api.set_metric_retention_period($1, INTERVAL '1 day' * $2)", metric, retention_period)
+		return err
+	})
 	if err != nil {
-		return fmt.Errorf("could not set retention period for %v: %w", metric, err)
+		return fmt.Errorf("could not set retention period for %v: %w", selection, err)
 	}
 
 	return nil
@@ -1,7 +1,6 @@
 package cmd
 
 import (
-	"context"
 	"fmt"
 
 	"github.com/spf13/cobra"
@@ -24,14 +23,20 @@ func retentionSetDefault(cmd *cobra.Command, args []string) error {
 
 	retention_period := args[0]
 
-	pool, err := OpenConnectionToDB(namespace, name, user, dbname, FORWARD_PORT_TSDB)
+	ctx := cmd.Context()
+	client, err := getKubeClient()
+	if err != nil {
+		return fmt.Errorf("could not set default retention period: %w", err)
+	}
+
+	pool, err := OpenConnectionToDB(ctx, client, namespace, name, user, dbname)
 	if err != nil {
 		return fmt.Errorf("could not set default retention period: %w", err)
 	}
 	defer pool.Close()
 
 	fmt.Printf("Setting default retention period to %v days\n", retention_period)
-	_, err = pool.Exec(context.Background(), "SELECT prom_api.set_default_retention_period(INTERVAL '1 day' * $1)", retention_period)
+	_, err = pool.Exec(ctx, "SELECT prom_api.set_default_retention_period(INTERVAL '1 day' * $1)", retention_period)
 	if err != nil {
 		return fmt.Errorf("could not set default retention period: %w", err)
 	}
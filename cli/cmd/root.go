@@ -1,9 +1,16 @@
 package cmd
 
 import (
+	"context"
 	"fmt"
-	"github.com/spf13/cobra"
 	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/spf13/cobra"
 
 	homedir "github.com/mitchellh/go-homedir"
 	"github.com/spf13/viper"
@@ -12,6 +19,14 @@ import (
 var cfgFile string
 var namespace string
 var name string
+var profile string
+var kubeconfig string
+var kubeContext string
+var kubeAs string
+var kubeAsGroups []string
+var timeout = DEFAULT_TIMEOUT
+
+const DEFAULT_TIMEOUT = 10 * time.Minute
 
 // rootCmd represents the base command when called without any subcommands
 var rootCmd = &cobra.Command{
@@ -35,23 +50,131 @@ components of Observability.`,
 			return fmt.Errorf("could not read global flag: %w", err)
 		}
 
+		timeout, err = cmd.Flags().GetDuration("timeout")
+		if err != nil {
+			return fmt.Errorf("could not read global flag: %w", err)
+		}
+
+		err = loadKubeProfile(cmd)
+		if err != nil {
+			return fmt.Errorf("could not load Kubernetes settings: %w", err)
+		}
+
 		return nil
 	},
 }
 
 // Execute adds all child commands to the root command and sets flags appropriately.
 // This is called by main.main(). It only needs to happen once to the rootCmd.
+// The context passed to every command is cancelled on SIGINT or SIGTERM so
+// that commands can clean up after themselves; a second signal exits at once.
 func Execute() {
-	if err := rootCmd.Execute(); err != nil {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	sigchan := make(chan os.Signal, 2)
+	signal.Notify(sigchan, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-sigchan
+		fmt.Fprintln(os.Stderr, "Interrupted, cleaning up (interrupt again to exit immediately)")
+		cancel()
+		<-sigchan
+		os.Exit(1)
+	}()
+
+	if err := rootCmd.ExecuteContext(ctx); err != nil {
 		os.Exit(1)
 	}
 }
 
+// cleanupContext returns a context for deferred cleanup, which has to run even
+// after the command's own context was cancelled by an interrupt.
+func cleanupContext() (context.Context, context.CancelFunc) {
+	return context.WithTimeout(context.Background(), 30*time.Second)
+}
+
 func init() {
 	cobra.OnInitialize(initConfig)
 	rootCmd.PersistentFlags().StringVar(&cfgFile, "config", "", "config file (default is $HOME/.tobs.yaml)")
 	rootCmd.PersistentFlags().StringP("name", "n", "tobs", "Helm release name")
 	rootCmd.PersistentFlags().StringP("namespace", "", "default", "Kubernetes namespace")
+	rootCmd.PersistentFlags().DurationP("timeout", "", DEFAULT_TIMEOUT, "How long to wait for Kubernetes resources to become ready")
+	rootCmd.PersistentFlags().StringP("profile", "", "default", "Profile in the config file to read and save Kubernetes settings to")
+	rootCmd.PersistentFlags().StringP("kubeconfig", "", "", "Path to the kubeconfig file (default is $KUBECONFIG or $HOME/.kube/config)")
+	rootCmd.PersistentFlags().StringP("context", "", "", "Name of the kubeconfig context to use")
+	rootCmd.PersistentFlags().StringP("as", "", "", "Username to impersonate for Kubernetes operations")
+	rootCmd.PersistentFlags().StringArrayP("as-group", "", nil, "Group to impersonate for Kubernetes operations, can be repeated")
+}
+
+// loadKubeProfile resolves the kubeconfig, context and impersonation settings.
+// Flags take precedence over the values stored under profiles.<profile> in the
+// config file. Flags that were set explicitly are saved back to that profile so
+// later invocations keep targeting the same cluster.
+func loadKubeProfile(cmd *cobra.Command) error {
+	var err error
+
+	profile, err = cmd.Flags().GetString("profile")
+	if err != nil {
+		return err
+	}
+	key := "profiles." + profile + "."
+
+	changed := false
+	for _, flag := range []struct {
+		name  string
+		value *string
+	}{
+		{"kubeconfig", &kubeconfig},
+		{"context", &kubeContext},
+		{"as", &kubeAs},
+	} {
+		if cmd.Flags().Changed(flag.name) {
+			*flag.value, err = cmd.Flags().GetString(flag.name)
+			if err != nil {
+				return err
+			}
+			if viper.GetString(key+flag.name) != *flag.value {
+				viper.Set(key+flag.name, *flag.value)
+				changed = true
+			}
+		} else {
+			*flag.value = viper.GetString(key + flag.name)
+		}
+	}
+
+	if cmd.Flags().Changed("as-group") {
+		kubeAsGroups, err = cmd.Flags().GetStringArray("as-group")
+		if err != nil {
+			return err
+		}
+		if strings.Join(viper.GetStringSlice(key+"as-group"), ",") != strings.Join(kubeAsGroups, ",") {
+			viper.Set(key+"as-group", kubeAsGroups)
+			changed = true
+		}
+	} else {
+		kubeAsGroups = viper.GetStringSlice(key + "as-group")
+	}
+
+	if changed {
+		return saveConfig()
+	}
+
+	return nil
+}
+
+// saveConfig writes the current configuration back to the file it was read
+// from, creating $HOME/.tobs.yaml if no config file exists yet.
+func saveConfig() error {
+	path := viper.ConfigFileUsed()
+	if path == "" {
+		home, err := homedir.Dir()
+		if err != nil {
+			return err
+		}
+		path = filepath.Join(home, ".tobs.yaml")
+	}
+
+	return viper.WriteConfigAs(path)
 }
 
 // initConfig reads in config file and ENV variables if set.
@@ -63,7 +186,7 @@ func initConfig() {
 		// Find home directory.
 		home, err := homedir.Dir()
 		if err != nil {
-			fmt.Println(err)
+			fmt.Fprintln(os.Stderr, err)
 			os.Exit(1)
 		}
 
@@ -74,8 +197,9 @@ func initConfig() {
 
 	viper.AutomaticEnv() // read in environment variables that match
 
-	// If a config file is found, read it in.
+	// If a config file is found, read it in. Stdout is left to the output of
+	// the command, which may well be redirected into a file.
 	if err := viper.ReadInConfig(); err == nil {
-		fmt.Println("Using config file:", viper.ConfigFileUsed())
+		fmt.Fprintln(os.Stderr, "Using config file:", viper.ConfigFileUsed())
 	}
 }
@@ -0,0 +1,50 @@
+package cmd
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/viper"
+)
+
+func TestInitConfigKeepsStdoutClean(t *testing.T) {
+	viper.Reset()
+	defer viper.Reset()
+
+	dir, err := ioutil.TempDir("", "tobs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	config := filepath.Join(dir, ".tobs.yaml")
+	if err = ioutil.WriteFile(config, []byte("kube:\n  context: test\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	oldCfgFile := cfgFile
+	defer func() { cfgFile = oldCfgFile }()
+	cfgFile = config
+
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout := os.Stdout
+	os.Stdout = w
+	initConfig()
+	os.Stdout = stdout
+	w.Close()
+
+	out, err := ioutil.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(out) != 0 {
+		t.Fatalf("expected nothing on stdout, got %q", out)
+	}
+	if viper.ConfigFileUsed() != config {
+		t.Fatalf("expected %v to be read, got %v", config, viper.ConfigFileUsed())
+	}
+}
@@ -0,0 +1,15 @@
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+)
+
+// settingsCmd represents the settings command
+var settingsCmd = &cobra.Command{
+	Use:   "settings",
+	Short: "Subcommand for exporting and importing the storage settings of all metrics",
+}
+
+func init() {
+	metricsCmd.AddCommand(settingsCmd)
+}
@@ -0,0 +1,110 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/spf13/cobra"
+	"sigs.k8s.io/yaml"
+)
+
+// settingsExportCmd represents the settings export command
+var settingsExportCmd = &cobra.Command{
+	Use:   "export",
+	Short: "Prints the default storage settings and every per-metric override as YAML",
+	Args:  cobra.ExactArgs(0),
+	RunE:  settingsExport,
+}
+
+func init() {
+	settingsCmd.AddCommand(settingsExportCmd)
+}
+
+func settingsExport(cmd *cobra.Command, args []string) error {
+	var err error
+
+	ctx := cmd.Context()
+	client, err := getKubeClient()
+	if err != nil {
+		return fmt.Errorf("could not export metric settings: %w", err)
+	}
+
+	pool, err := OpenConnectionToDB(ctx, client, namespace, name, user, dbname)
+	if err != nil {
+		return fmt.Errorf("could not export metric settings: %w", err)
+	}
+	defer pool.Close()
+
+	defaults, err := getStorageDefaults(ctx, pool)
+	if err != nil {
+		return fmt.Errorf("could not export metric settings: %w", err)
+	}
+	metrics, err := getMetricSettings(ctx, pool)
+	if err != nil {
+		return fmt.Errorf("could not export metric settings: %w", err)
+	}
+
+	err = writeMetricSettings(os.Stdout, defaults, metrics)
+	if err != nil {
+		return fmt.Errorf("could not export metric settings: %w", err)
+	}
+
+	return nil
+}
+
+// writeMetricSettings writes the snapshot of the settings as YAML. Nothing
+// else goes to w, so that the export can be redirected into a file that
+// settings import reads back.
+func writeMetricSettings(w io.Writer, defaults storageDefaults, metrics []metricSettings) error {
+	data, err := yaml.Marshal(snapshotMetricSettings(defaults, metrics))
+	if err != nil {
+		return err
+	}
+
+	_, err = w.Write(data)
+	return err
+}
+
+// snapshotMetricSettings returns a metric policy with the defaults and a rule
+// for each metric overriding them, which restores the settings when applied
+// with pruning.
+func snapshotMetricSettings(defaults storageDefaults, metrics []metricSettings) *metricPolicy {
+	compression := defaults.Compression
+	snapshot := &metricPolicy{Defaults: policySettings{
+		ChunkInterval: defaults.ChunkInterval.String(),
+		Compression:   &compression,
+	}}
+	snapshot.Defaults.setRetention(defaults.Retention)
+
+	for _, metric := range metrics {
+		rule := metricPolicyRule{Match: metric.Name}
+		if metric.CustomRetention {
+			rule.setRetention(metric.Retention)
+		}
+		if metric.CustomChunkInterval {
+			rule.ChunkInterval = metric.ChunkInterval.String()
+		}
+		if metric.Compression != defaults.Compression {
+			enabled := metric.Compression
+			rule.Compression = &enabled
+		}
+		if rule.policySettings != (policySettings{}) {
+			snapshot.Metrics = append(snapshot.Metrics, rule)
+		}
+	}
+
+	return snapshot
+}
+
+// setRetention sets the retention in days if it is a whole number of days, and
+// as an exact duration otherwise.
+func (s *policySettings) setRetention(retention time.Duration) {
+	if retention%(24*time.Hour) == 0 {
+		days := retentionDays(retention)
+		s.RetentionDays = &days
+	} else {
+		s.Retention = retention.String()
+	}
+}
@@ -0,0 +1,118 @@
+package cmd
+
+import (
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"sigs.k8s.io/yaml"
+)
+
+func TestSnapshotMetricSettings(t *testing.T) {
+	defaults := storageDefaults{Retention: daysDuration(90), ChunkInterval: 8 * time.Hour, Compression: true}
+	metrics := []metricSettings{
+		{Name: "node_load1", Retention: daysDuration(30), CustomRetention: true, ChunkInterval: 8 * time.Hour, Compression: true},
+		{Name: "up", Retention: daysDuration(90), ChunkInterval: time.Hour, CustomChunkInterval: true, Compression: false},
+		{Name: "go_goroutines", Retention: daysDuration(90), ChunkInterval: 8 * time.Hour, Compression: true},
+	}
+
+	data, err := yaml.Marshal(snapshotMetricSettings(defaults, metrics))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `defaults:
+  chunkInterval: 8h0m0s
+  compression: true
+  retentionDays: 90
+metrics:
+- match: node_load1
+  retentionDays: 30
+- chunkInterval: 1h0m0s
+  compression: false
+  match: up
+`
+	if string(data) != expected {
+		t.Fatalf("expected\n%v\ngot\n%v", expected, string(data))
+	}
+
+	snapshot, err := parseMetricPolicy(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	changes, err := planMetricPolicy(snapshot, defaults, metrics, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(changes) != 0 {
+		t.Fatalf("expected the snapshot to match the settings it was taken from, got %v", changes)
+	}
+
+	target := storageDefaults{Retention: daysDuration(14), ChunkInterval: 8 * time.Hour, Compression: true}
+	changes, err = planMetricPolicy(snapshot, target, []metricSettings{
+		{Name: "node_load1", Retention: daysDuration(14), ChunkInterval: 8 * time.Hour, Compression: true},
+		{Name: "go_goroutines", Retention: daysDuration(7), CustomRetention: true, ChunkInterval: 8 * time.Hour, Compression: true},
+	}, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var actual []string
+	for _, change := range changes {
+		actual = append(actual, change.String())
+	}
+	expectedChanges := []string{
+		"default retention: 14 days => 90 days",
+		"node_load1 retention: 14 days (default) => 30 days (override)",
+		"go_goroutines retention: 7 days (override) => 90 days (default)",
+	}
+	if strings.Join(actual, "\n") != strings.Join(expectedChanges, "\n") {
+		t.Fatalf("expected changes\n%v\ngot\n%v", strings.Join(expectedChanges, "\n"), strings.Join(actual, "\n"))
+	}
+}
+
+func TestSnapshotMetricSettingsRoundTrip(t *testing.T) {
+	defaults := storageDefaults{Retention: 36 * time.Hour, ChunkInterval: 8 * time.Hour, Compression: true}
+	metrics := []metricSettings{
+		{Name: "up", Retention: 30 * time.Hour, CustomRetention: true, ChunkInterval: 8 * time.Hour, Compression: true},
+		{Name: "node_load1", Retention: 36 * time.Hour, ChunkInterval: 8 * time.Hour, Compression: true},
+	}
+
+	file, err := ioutil.TempFile("", "settings-*.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(file.Name())
+	err = writeMetricSettings(file, defaults, metrics)
+	file.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	snapshot, err := readMetricPolicy(file.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	changes, err := planMetricPolicy(snapshot, defaults, metrics, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(changes) != 0 {
+		t.Fatalf("expected the snapshot to match the settings it was taken from, got %v", changes)
+	}
+
+	target := storageDefaults{Retention: daysDuration(90), ChunkInterval: 8 * time.Hour, Compression: true}
+	changes, err = planMetricPolicy(snapshot, target, []metricSettings{
+		{Name: "up", Retention: daysDuration(90), ChunkInterval: 8 * time.Hour, Compression: true},
+	}, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertSettingChanges(t, changes, []string{
+		"default retention: 90 days => 36h0m0s",
+		"up retention: 90 days (default) => 30h0m0s (override)",
+	})
+	if changes[0].SQL() != "SELECT prom_api.set_default_retention_period('129600000000 microseconds'::INTERVAL)" {
+		t.Fatalf("unexpected query %v", changes[0].SQL())
+	}
+}
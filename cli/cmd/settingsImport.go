@@ -0,0 +1,122 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// settingsImportCmd represents the settings import command
+var settingsImportCmd = &cobra.Command{
+	Use:   "import <file>",
+	Short: "Restores the default storage settings and per-metric overrides exported with tobs metrics settings export",
+	Args:  cobra.ExactArgs(1),
+	RunE:  settingsImport,
+}
+
+func init() {
+	settingsCmd.AddCommand(settingsImportCmd)
+	settingsImportCmd.Flags().BoolP("dry-run", "", false, "Print the prom_api calls restoring the settings without running them")
+	settingsImportCmd.Flags().BoolP("diff", "", false, "Show how the settings differ from the snapshot without changing them, exits non-zero if they differ")
+}
+
+func settingsImport(cmd *cobra.Command, args []string) error {
+	var err error
+
+	filename := args[0]
+
+	var dryRun, diff bool
+	dryRun, err = cmd.Flags().GetBool("dry-run")
+	if err != nil {
+		return fmt.Errorf("could not import metric settings: %w", err)
+	}
+	diff, err = cmd.Flags().GetBool("diff")
+	if err != nil {
+		return fmt.Errorf("could not import metric settings: %w", err)
+	}
+
+	snapshot, err := readMetricPolicy(filename)
+	if err != nil {
+		return fmt.Errorf("could not import metric settings: %w", err)
+	}
+
+	ctx := cmd.Context()
+	client, err := getKubeClient()
+	if err != nil {
+		return fmt.Errorf("could not import metric settings: %w", err)
+	}
+
+	pool, err := OpenConnectionToDB(ctx, client, namespace, name, user, dbname)
+	if err != nil {
+		return fmt.Errorf("could not import metric settings: %w", err)
+	}
+	defer pool.Close()
+
+	defaults, err := getStorageDefaults(ctx, pool)
+	if err != nil {
+		return fmt.Errorf("could not import metric settings: %w", err)
+	}
+	metrics, err := getMetricSettings(ctx, pool)
+	if err != nil {
+		return fmt.Errorf("could not import metric settings: %w", err)
+	}
+
+	if missing := unmatchedRules(snapshot, metrics); len(missing) != 0 {
+		fmt.Printf("Skipping %d metrics that do not exist in this release: %v\n", len(missing), strings.Join(missing, ", "))
+	}
+
+	changes, err := planMetricPolicy(snapshot, defaults, metrics, true)
+	if err != nil {
+		return fmt.Errorf("could not import metric settings: %w", err)
+	}
+
+	if len(changes) == 0 {
+		fmt.Println("The metric settings already match the snapshot")
+		return nil
+	}
+
+	switch {
+	case diff:
+		printSettingChanges(os.Stdout, changes)
+		return fmt.Errorf("%d settings differ from the snapshot", len(changes))
+	case dryRun:
+		for _, change := range changes {
+			fmt.Printf("%v;\n", change.SQL())
+		}
+		return nil
+	}
+
+	printSettingChanges(os.Stdout, changes)
+	err = applySettingChanges(ctx, pool, changes)
+	if err != nil {
+		return fmt.Errorf("could not import metric settings: %w", err)
+	}
+
+	fmt.Printf("Imported %d changes\n", len(changes))
+	return nil
+}
+
+// unmatchedRules lists the patterns of the rules of a policy matching none of
+// the metrics.
+func unmatchedRules(policy *metricPolicy, metrics []metricSettings) []string {
+	var unmatched []string
+	for _, rule := range policy.Metrics {
+		matches, err := metricNameMatcher(rule.Match)
+		if err != nil {
+			continue
+		}
+		matched := false
+		for _, metric := range metrics {
+			if matches(metric.Name) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			unmatched = append(unmatched, rule.Match)
+		}
+	}
+	return unmatched
+}
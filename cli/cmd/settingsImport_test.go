@@ -0,0 +1,26 @@
+package cmd
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestUnmatchedRules(t *testing.T) {
+	policy, err := parseMetricPolicy([]byte(`
+metrics:
+- match: up
+  retentionDays: 30
+- match: node_*
+  retentionDays: 7
+- match: missing_metric
+  retentionDays: 7
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	unmatched := unmatchedRules(policy, []metricSettings{{Name: "up"}, {Name: "go_goroutines"}})
+	if strings.Join(unmatched, " ") != "node_* missing_metric" {
+		t.Fatalf("expected node_* and missing_metric to be unmatched, got %v", unmatched)
+	}
+}
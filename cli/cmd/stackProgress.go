@@ -0,0 +1,487 @@
+package cmd
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// stackPollInterval is how often waitForStack checks the components.
+var stackPollInterval = 2 * time.Second
+
+// stackStartupGrace is how long a component may have neither pods nor PVCs
+// before it counts as not deployed, e.g. because it is disabled in the Helm
+// values.
+var stackStartupGrace = 10 * time.Second
+
+// pvcPendingTimeout is how long a PVC may stay Pending before its component
+// counts as failing.
+var pvcPendingTimeout = 2 * time.Minute
+
+// crashLoopRestarts is how often a crash looping container may restart
+// before its component counts as failing. Promscale, for one, restarts a few
+// times while TimescaleDB is still starting.
+var crashLoopRestarts int32 = 5
+
+// failureLogLines is the number of log lines attached to a componentFailure.
+const failureLogLines = 20
+
+// pvcFailureReasons are PVC event reasons that mean the claim will not be
+// bound without intervention, e.g. because no default storage class exists.
+var pvcFailureReasons = map[string]bool{
+	"ProvisioningFailed": true,
+	"FailedBinding":      true,
+}
+
+const (
+	componentWaiting     = "Waiting"
+	componentReady       = "Ready"
+	componentNotDeployed = "NotDeployed"
+)
+
+// componentProgress is the readiness of one component while waiting for the
+// stack.
+type componentProgress struct {
+	Component stackComponent
+	Phase     string
+	Ready     int
+	Total     int
+	// Detail says what the component is waiting for, e.g. ContainerCreating.
+	Detail string
+}
+
+func (p componentProgress) String() string {
+	s := fmt.Sprintf("%v: %v", p.Component.Name, p.Phase)
+	if p.Total != 0 {
+		s += fmt.Sprintf(" (%d/%d pods ready)", p.Ready, p.Total)
+	}
+	if p.Detail != "" {
+		s += ", " + p.Detail
+	}
+	return s
+}
+
+// componentFailure is returned by waitForStack when a component fails in a
+// way that does not resolve on its own. It carries the events and last log
+// lines of the failing object to diagnose it without another command.
+type componentFailure struct {
+	Component string
+	// Object is the failing object, e.g. pod/gg-grafana-0.
+	Object  string
+	Reason  string
+	Message string
+	Events  []string
+	// LogSource is the container the Logs were read from.
+	LogSource string
+	Logs      []string
+}
+
+func (e *componentFailure) Error() string {
+	msg := fmt.Sprintf("%v is failing: %v: %v", e.Component, e.Object, e.Reason)
+	if e.Message != "" {
+		msg += ": " + e.Message
+	}
+	if len(e.Events) != 0 {
+		msg += "\nEvents:"
+		for _, event := range e.Events {
+			msg += "\n  " + event
+		}
+	}
+	if len(e.Logs) != 0 {
+		msg += fmt.Sprintf("\nLast log lines of %v:", e.LogSource)
+		for _, line := range e.Logs {
+			msg += "\n  " + line
+		}
+	}
+	return msg
+}
+
+// waitForStack waits up to waitTimeout until every deployed component of the
+// release is ready, checking all components in parallel and printing each
+// change of their progress. It aborts with a componentFailure as soon as a
+// component is clearly failing, e.g. an image can not be pulled, a container
+// crash loops, a PVC can not be bound or a Job gave up.
+func waitForStack(ctx context.Context, client *KubeClient, waitTimeout time.Duration) ([]componentProgress, error) {
+	var err error
+
+	components, err := stackComponents(name, namespace)
+	if err != nil {
+		return nil, err
+	}
+
+	waitCtx, cancel := context.WithTimeout(ctx, waitTimeout)
+	defer cancel()
+
+	started := time.Now()
+	printed := make([]string, len(components))
+	for {
+		progress := make([]componentProgress, len(components))
+		errs := make([]error, len(components))
+		graceOver := time.Since(started) >= stackStartupGrace
+
+		var wg sync.WaitGroup
+		for i, component := range components {
+			wg.Add(1)
+			go func(i int, component stackComponent) {
+				defer wg.Done()
+				progress[i], errs[i] = checkComponent(waitCtx, client, component, graceOver)
+			}(i, component)
+		}
+		wg.Wait()
+
+		for _, err := range errs {
+			var failure *componentFailure
+			if errors.As(err, &failure) {
+				return progress, err
+			}
+		}
+		for _, err := range errs {
+			if err != nil && waitCtx.Err() == nil {
+				return progress, err
+			}
+		}
+
+		done := true
+		for i, p := range progress {
+			if line := p.String(); line != printed[i] {
+				fmt.Println("  " + line)
+				printed[i] = line
+			}
+			if p.Phase == componentWaiting {
+				done = false
+			}
+		}
+		if done {
+			return progress, nil
+		}
+
+		select {
+		case <-waitCtx.Done():
+			if ctx.Err() != nil {
+				return progress, ctx.Err()
+			}
+			var waiting []string
+			for _, p := range progress {
+				if p.Phase == componentWaiting {
+					waiting = append(waiting, p.String())
+				}
+			}
+			return progress, fmt.Errorf("pods not ready after %v: %v", waitTimeout, strings.Join(waiting, "; "))
+		case <-time.After(stackPollInterval):
+		}
+	}
+}
+
+// checkComponent returns the progress of a component, or a componentFailure
+// if it is failing. Until graceOver, a component without pods or PVCs is
+// still expected to appear.
+func checkComponent(ctx context.Context, client *KubeClient, component stackComponent, graceOver bool) (componentProgress, error) {
+	progress := componentProgress{Component: component, Phase: componentWaiting}
+
+	pvcs, err := client.KubeGetPVCs(ctx, namespace, component.Selector)
+	if err != nil {
+		return progress, err
+	}
+	for _, pvc := range pvcs {
+		if pvc.Status.Phase != corev1.ClaimPending {
+			continue
+		}
+		err = pvcFailure(ctx, client, component, pvc)
+		if err != nil {
+			return progress, err
+		}
+		progress.Detail = fmt.Sprintf("PVC %v is Pending", pvc.Name)
+	}
+
+	pods, err := client.KubeGetPods(ctx, namespace, component.Selector)
+	if err != nil {
+		return progress, err
+	}
+	for i := range pods {
+		pod := &pods[i]
+		if pod.DeletionTimestamp != nil {
+			continue
+		}
+
+		ready, err := podReady(pod)
+		var waitErr *PodWaitError
+		if errors.As(err, &waitErr) && waitErr.Reason == "CrashLoopBackOff" {
+			if restarts := podRestarts(pod); restarts < crashLoopRestarts {
+				progress.Total++
+				if progress.Detail == "" {
+					progress.Detail = fmt.Sprintf("CrashLoopBackOff after %d restarts", restarts)
+				}
+				continue
+			}
+		}
+		if errors.As(err, &waitErr) {
+			// A Job replaces its failed pods until it runs out of retries,
+			// e.g. while the database it initializes is still starting.
+			if jobName := podJobName(pod); jobName != "" && waitErr.Reason == string(corev1.PodFailed) {
+				err = jobFailure(ctx, client, component, pod, jobName)
+				if err != nil {
+					return progress, err
+				}
+				progress.Detail = fmt.Sprintf("job %v is retrying", jobName)
+				continue
+			}
+			return progress, podFailure(ctx, client, component, pod, waitErr)
+		}
+		if err != nil {
+			return progress, err
+		}
+
+		progress.Total++
+		if ready {
+			progress.Ready++
+		} else if progress.Detail == "" {
+			progress.Detail = podWaitingReason(pod)
+		}
+	}
+
+	switch {
+	case progress.Total != 0 && progress.Ready == progress.Total:
+		progress.Phase = componentReady
+		progress.Detail = ""
+	case len(pods) == 0 && len(pvcs) == 0 && graceOver:
+		progress.Phase = componentNotDeployed
+	case len(pods) == 0 && progress.Detail == "":
+		progress.Detail = "no pods yet"
+	}
+
+	return progress, nil
+}
+
+// pvcFailure returns a componentFailure if a Pending PVC can not be bound.
+func pvcFailure(ctx context.Context, client *KubeClient, component stackComponent, pvc corev1.PersistentVolumeClaim) error {
+	events := client.kubeGetObjectEvents(ctx, namespace, pvc.Name)
+
+	failure := &componentFailure{Component: component.Name, Object: "persistentvolumeclaim/" + pvc.Name, Events: formatEvents(events)}
+	for _, event := range events {
+		if pvcFailureReasons[event.Reason] {
+			failure.Reason = event.Reason
+			failure.Message = event.Message
+		}
+	}
+	if failure.Reason == "" && time.Since(pvc.CreationTimestamp.Time) > pvcPendingTimeout {
+		failure.Reason = string(corev1.ClaimPending)
+		failure.Message = fmt.Sprintf("not bound after %v", pvcPendingTimeout)
+	}
+	if failure.Reason == "" {
+		return nil
+	}
+
+	return failure
+}
+
+// jobFailure returns a componentFailure if the Job a failed pod belongs to
+// has given up.
+func jobFailure(ctx context.Context, client *KubeClient, component stackComponent, pod *corev1.Pod, jobName string) error {
+	job, err := client.KubeGetJob(ctx, namespace, jobName)
+	if err != nil {
+		return err
+	}
+
+	for _, condition := range job.Status.Conditions {
+		if condition.Type == batchv1.JobFailed && condition.Status == corev1.ConditionTrue {
+			failure := podFailure(ctx, client, component, pod, &PodWaitError{Pod: pod.Name, Reason: condition.Reason, Message: condition.Message})
+			failure.Object = "job/" + jobName
+			return failure
+		}
+	}
+
+	return nil
+}
+
+// podFailure turns the error of a failing pod into a componentFailure with
+// the pod's events and, if a container has run, its last log lines.
+func podFailure(ctx context.Context, client *KubeClient, component stackComponent, pod *corev1.Pod, waitErr *PodWaitError) *componentFailure {
+	failure := &componentFailure{
+		Component: component.Name,
+		Object:    "pod/" + pod.Name,
+		Reason:    waitErr.Reason,
+		Message:   waitErr.Message,
+		Events:    formatEvents(client.kubeGetObjectEvents(ctx, namespace, pod.Name)),
+	}
+
+	status := failingContainer(pod)
+	if status == nil || (status.RestartCount == 0 && status.State.Terminated == nil) {
+		return failure
+	}
+
+	tailLines := int64(failureLogLines)
+	options := &corev1.PodLogOptions{
+		Container: status.Name,
+		TailLines: &tailLines,
+		// A crash looping container is waiting to restart, so its logs are
+		// those of the previous attempt.
+		Previous: status.State.Terminated == nil,
+	}
+	logs, err := client.KubeGetPodLogs(ctx, namespace, pod.Name, options)
+	if err != nil {
+		return failure
+	}
+	defer logs.Close()
+
+	scanner := bufio.NewScanner(logs)
+	for scanner.Scan() {
+		failure.Logs = append(failure.Logs, scanner.Text())
+	}
+	failure.LogSource = pod.Name + "/" + status.Name
+
+	return failure
+}
+
+// failingContainer returns the status of the first container that can not
+// start or exited with an error, or nil.
+func failingContainer(pod *corev1.Pod) *corev1.ContainerStatus {
+	var statuses []corev1.ContainerStatus
+	statuses = append(statuses, pod.Status.InitContainerStatuses...)
+	statuses = append(statuses, pod.Status.ContainerStatuses...)
+	for i := range statuses {
+		status := &statuses[i]
+		if waiting := status.State.Waiting; waiting != nil && podFailureReasons[waiting.Reason] {
+			return status
+		}
+		if terminated := status.State.Terminated; terminated != nil && terminated.ExitCode != 0 {
+			return status
+		}
+	}
+	return nil
+}
+
+// podRestarts returns the most restarts of any container of a pod.
+func podRestarts(pod *corev1.Pod) int32 {
+	var restarts int32
+	for _, statuses := range [][]corev1.ContainerStatus{pod.Status.InitContainerStatuses, pod.Status.ContainerStatuses} {
+		for _, status := range statuses {
+			if status.RestartCount > restarts {
+				restarts = status.RestartCount
+			}
+		}
+	}
+	return restarts
+}
+
+// podJobName returns the name of the Job controlling a pod, or "".
+func podJobName(pod *corev1.Pod) string {
+	if owner := metav1.GetControllerOf(pod); owner != nil && owner.Kind == "Job" {
+		return owner.Name
+	}
+	return ""
+}
+
+// podWaitingReason says why a pod that is not failing is not ready yet.
+func podWaitingReason(pod *corev1.Pod) string {
+	for _, condition := range pod.Status.Conditions {
+		if condition.Type == corev1.PodScheduled && condition.Status == corev1.ConditionFalse && condition.Reason != "" {
+			return condition.Reason
+		}
+	}
+
+	var statuses []corev1.ContainerStatus
+	statuses = append(statuses, pod.Status.InitContainerStatuses...)
+	statuses = append(statuses, pod.Status.ContainerStatuses...)
+	for _, status := range statuses {
+		if status.State.Waiting != nil && status.State.Waiting.Reason != "" {
+			return status.State.Waiting.Reason
+		}
+	}
+
+	if pod.Status.Phase == "" {
+		return string(corev1.PodPending)
+	}
+	return string(pod.Status.Phase)
+}
+
+// stackNextSteps are the commands to run next for each component.
+var stackNextSteps = map[string][]string{
+	"timescaledb": {"timescaledb connect", "timescaledb get-password"},
+	"prometheus":  {"prometheus port-forward"},
+	"grafana":     {"grafana get-password", "grafana port-forward"},
+	"promlens":    {"promlens port-forward"},
+}
+
+// printStackSummary prints the endpoints of the ready components and the
+// commands to use them.
+func printStackSummary(ctx context.Context, client *KubeClient, progress []componentProgress) error {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+
+	fmt.Fprintln(w, "COMPONENT\tSTATUS\tENDPOINTS")
+	var steps []string
+	for _, p := range progress {
+		if p.Phase == componentNotDeployed {
+			continue
+		}
+
+		endpoints, err := componentEndpoints(ctx, client, p.Component)
+		if err != nil {
+			return err
+		}
+		if len(endpoints) == 0 {
+			endpoints = []string{"-"}
+		}
+		fmt.Fprintf(w, "%v\t%v\t%v\n", p.Component.Name, p.Phase, strings.Join(endpoints, ", "))
+
+		for _, step := range stackNextSteps[p.Component.ID] {
+			steps = append(steps, "tobs "+step+releaseFlags())
+		}
+	}
+
+	if len(steps) != 0 {
+		fmt.Fprintln(w, "\nNext steps:")
+		for _, step := range steps {
+			fmt.Fprintln(w, "  "+step)
+		}
+	}
+
+	return w.Flush()
+}
+
+// componentEndpoints returns the in-cluster addresses of the services of a
+// component, and their external addresses if they are load balancers.
+func componentEndpoints(ctx context.Context, client *KubeClient, component stackComponent) ([]string, error) {
+	services, err := client.KubeGetServices(ctx, namespace, component.Service)
+	if err != nil {
+		return nil, err
+	}
+
+	var endpoints []string
+	for _, service := range services {
+		for _, port := range service.Spec.Ports {
+			endpoints = append(endpoints, fmt.Sprintf("%v.%v.svc:%d", service.Name, service.Namespace, port.Port))
+			for _, ingress := range service.Status.LoadBalancer.Ingress {
+				host := ingress.IP
+				if host == "" {
+					host = ingress.Hostname
+				}
+				endpoints = append(endpoints, fmt.Sprintf("%v:%d", host, port.Port))
+			}
+		}
+	}
+
+	return endpoints, nil
+}
+
+// releaseFlags returns the flags selecting the current release, or "" for
+// the default release.
+func releaseFlags() string {
+	var flags string
+	if name != rootCmd.PersistentFlags().Lookup("name").DefValue {
+		flags += " -n " + name
+	}
+	if namespace != rootCmd.PersistentFlags().Lookup("namespace").DefValue {
+		flags += " --namespace " + namespace
+	}
+	return flags
+}
@@ -0,0 +1,175 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+var testGrafanaLabels = map[string]string{"app.kubernetes.io/instance": "gg", "app.kubernetes.io/name": "grafana"}
+
+func TestWaitForStack(t *testing.T) {
+	oldName, oldNamespace := name, namespace
+	defer func() { name, namespace = oldName, oldNamespace }()
+	name, namespace = "gg", "ns"
+	defer func() { stackStartupGrace, stackPollInterval = 10*time.Second, 2*time.Second }()
+	stackStartupGrace, stackPollInterval = 0, 10*time.Millisecond
+
+	client := newFakeKubeClient(
+		readyPod("gg-grafana-0", testGrafanaLabels),
+		&corev1.Service{
+			ObjectMeta: metav1.ObjectMeta{Name: "gg-grafana", Namespace: "ns", Labels: testGrafanaLabels},
+			Spec:       corev1.ServiceSpec{Ports: []corev1.ServicePort{{Port: 80}}},
+		},
+	)
+
+	progress, err := waitForStack(context.Background(), client, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var grafana stackComponent
+	for _, p := range progress {
+		expected := componentNotDeployed
+		if p.Component.ID == "grafana" {
+			grafana = p.Component
+			expected = componentReady
+		}
+		if p.Phase != expected {
+			t.Fatalf("expected %v to be %v, got %v", p.Component.Name, expected, p)
+		}
+	}
+
+	endpoints, err := componentEndpoints(context.Background(), client, grafana)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(endpoints) != 1 || endpoints[0] != "gg-grafana.ns.svc:80" {
+		t.Fatalf("expected the grafana service endpoint, got %v", endpoints)
+	}
+
+	unready := testPod("gg-grafana-1", testGrafanaLabels)
+	unready.Status.Phase = corev1.PodPending
+	unready.Status.ContainerStatuses = []corev1.ContainerStatus{{
+		Name:  "grafana",
+		State: corev1.ContainerState{Waiting: &corev1.ContainerStateWaiting{Reason: "ContainerCreating"}},
+	}}
+	_, err = client.CoreV1().Pods("ns").Create(context.Background(), unready, metav1.CreateOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = waitForStack(context.Background(), client, 100*time.Millisecond)
+	if err == nil || !strings.Contains(err.Error(), "Grafana: Waiting (1/2 pods ready), ContainerCreating") {
+		t.Fatalf("expected a timeout naming the waiting component, got %v", err)
+	}
+}
+
+func TestCheckComponentFailures(t *testing.T) {
+	oldNamespace := namespace
+	defer func() { namespace = oldNamespace }()
+	namespace = "ns"
+	grafana := stackComponent{ID: "grafana", Name: "Grafana", Selector: testGrafanaLabels}
+
+	crashing := testPod("gg-grafana-0", testGrafanaLabels)
+	crashing.Status.Phase = corev1.PodRunning
+	crashing.Status.ContainerStatuses = []corev1.ContainerStatus{{
+		Name:  "grafana",
+		State: corev1.ContainerState{Waiting: &corev1.ContainerStateWaiting{Reason: "CrashLoopBackOff"}},
+	}}
+	event := &corev1.Event{
+		ObjectMeta:     metav1.ObjectMeta{Name: "gg-grafana-0.1", Namespace: "ns"},
+		InvolvedObject: corev1.ObjectReference{Kind: "Pod", Name: "gg-grafana-0", Namespace: "ns"},
+		Type:           corev1.EventTypeWarning,
+		Reason:         "BackOff",
+		Message:        "Back-off restarting failed container",
+	}
+
+	progress, err := checkComponent(context.Background(), newFakeKubeClient(crashing, event), grafana, true)
+	if err != nil || progress.Phase != componentWaiting || progress.Detail != "CrashLoopBackOff after 0 restarts" {
+		t.Fatalf("expected a container restarting a few times to be waited on, got %v %v", progress, err)
+	}
+
+	// Failing with restarts would read the logs of the previous container,
+	// which the fake clientset does not support.
+	oldRestarts := crashLoopRestarts
+	crashLoopRestarts = 0
+	defer func() { crashLoopRestarts = oldRestarts }()
+	_, err = checkComponent(context.Background(), newFakeKubeClient(crashing, event), grafana, true)
+	var failure *componentFailure
+	if !errors.As(err, &failure) || failure.Reason != "CrashLoopBackOff" {
+		t.Fatalf("expected a CrashLoopBackOff failure, got %v", err)
+	}
+	if len(failure.Events) != 1 {
+		t.Fatalf("expected the events of the pod, got %+v", failure)
+	}
+
+	pulling := testPod("gg-grafana-0", testGrafanaLabels)
+	pulling.Status.ContainerStatuses = []corev1.ContainerStatus{{
+		Name:  "grafana",
+		State: corev1.ContainerState{Waiting: &corev1.ContainerStateWaiting{Reason: "ImagePullBackOff"}},
+	}}
+	_, err = checkComponent(context.Background(), newFakeKubeClient(pulling), grafana, true)
+	if !errors.As(err, &failure) || failure.Reason != "ImagePullBackOff" || len(failure.Logs) != 0 {
+		t.Fatalf("expected an ImagePullBackOff failure without logs, got %v", err)
+	}
+
+	pvc := &corev1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{Name: "storage-gg-grafana", Namespace: "ns", Labels: testGrafanaLabels, CreationTimestamp: metav1.Now()},
+		Status:     corev1.PersistentVolumeClaimStatus{Phase: corev1.ClaimPending},
+	}
+	progress, err = checkComponent(context.Background(), newFakeKubeClient(pvc), grafana, true)
+	if err != nil || progress.Phase != componentWaiting || progress.Detail != "PVC storage-gg-grafana is Pending" {
+		t.Fatalf("expected a freshly created PVC to be waited on, got %v %v", progress, err)
+	}
+
+	binding := &corev1.Event{
+		ObjectMeta:     metav1.ObjectMeta{Name: "storage-gg-grafana.1", Namespace: "ns"},
+		InvolvedObject: corev1.ObjectReference{Kind: "PersistentVolumeClaim", Name: "storage-gg-grafana", Namespace: "ns"},
+		Type:           corev1.EventTypeNormal,
+		Reason:         "FailedBinding",
+		Message:        "no persistent volumes available for this claim and no storage class is set",
+	}
+	_, err = checkComponent(context.Background(), newFakeKubeClient(pvc, binding), grafana, true)
+	if !errors.As(err, &failure) || failure.Reason != "FailedBinding" || failure.Object != "persistentvolumeclaim/storage-gg-grafana" {
+		t.Fatalf("expected a FailedBinding failure, got %v", err)
+	}
+}
+
+func TestCheckComponentJob(t *testing.T) {
+	oldNamespace := namespace
+	defer func() { namespace = oldNamespace }()
+	namespace = "ns"
+	labels := map[string]string{"job-name": "gg-grafana-db"}
+	component := stackComponent{ID: "grafana-db", Name: "Grafana DB job", Selector: labels}
+
+	controller := true
+	failed := testPod("gg-grafana-db-1", labels)
+	failed.OwnerReferences = []metav1.OwnerReference{{APIVersion: "batch/v1", Kind: "Job", Name: "gg-grafana-db", Controller: &controller}}
+	failed.Status.Phase = corev1.PodFailed
+	job := &batchv1.Job{ObjectMeta: metav1.ObjectMeta{Name: "gg-grafana-db", Namespace: "ns"}}
+
+	progress, err := checkComponent(context.Background(), newFakeKubeClient(failed, job), component, true)
+	if err != nil || progress.Phase != componentWaiting || progress.Detail != "job gg-grafana-db is retrying" {
+		t.Fatalf("expected a retrying job to be waited on, got %v %v", progress, err)
+	}
+
+	completed := testPod("gg-grafana-db-2", labels)
+	completed.Status.Phase = corev1.PodSucceeded
+	progress, err = checkComponent(context.Background(), newFakeKubeClient(failed, completed, job), component, true)
+	if err != nil || progress.Phase != componentReady {
+		t.Fatalf("expected a job that succeeded on retry to be ready, got %v %v", progress, err)
+	}
+
+	job.Status.Conditions = []batchv1.JobCondition{{Type: batchv1.JobFailed, Status: corev1.ConditionTrue, Reason: "BackoffLimitExceeded"}}
+	_, err = checkComponent(context.Background(), newFakeKubeClient(failed, job), component, true)
+	var failure *componentFailure
+	if !errors.As(err, &failure) || failure.Reason != "BackoffLimitExceeded" || failure.Object != "job/gg-grafana-db" {
+		t.Fatalf("expected the job to fail, got %v", err)
+	}
+}
@@ -0,0 +1,323 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/duration"
+)
+
+// statusCmd represents the status command
+var statusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "Shows the health of every component of The Observability Stack",
+	Args:  cobra.ExactArgs(0),
+	RunE:  status,
+}
+
+func init() {
+	rootCmd.AddCommand(statusCmd)
+	statusCmd.Flags().StringP("output", "o", "table", "Output format, one of table or json")
+}
+
+type releaseStatus struct {
+	Release    string            `json:"release"`
+	Namespace  string            `json:"namespace"`
+	Revision   int               `json:"revision"`
+	HelmStatus string            `json:"helmStatus"`
+	Healthy    bool              `json:"healthy"`
+	Components []componentStatus `json:"components"`
+}
+
+type componentStatus struct {
+	Name     string          `json:"name"`
+	Deployed bool            `json:"deployed"`
+	Healthy  bool            `json:"healthy"`
+	Pods     []podStatus     `json:"pods"`
+	Services []serviceStatus `json:"services"`
+	PVCs     []pvcStatus     `json:"pvcs"`
+}
+
+type podStatus struct {
+	Name     string    `json:"name"`
+	Phase    string    `json:"phase"`
+	Ready    int       `json:"ready"`
+	Total    int       `json:"total"`
+	Restarts int32     `json:"restarts"`
+	Created  time.Time `json:"created"`
+	Images   []string  `json:"images"`
+	Healthy  bool      `json:"healthy"`
+}
+
+type serviceStatus struct {
+	Name      string   `json:"name"`
+	Type      string   `json:"type"`
+	ClusterIP string   `json:"clusterIP"`
+	Ports     []string `json:"ports"`
+	Endpoints []string `json:"endpoints"`
+	Healthy   bool     `json:"healthy"`
+}
+
+type pvcStatus struct {
+	Name     string `json:"name"`
+	Phase    string `json:"phase"`
+	Capacity string `json:"capacity"`
+	Healthy  bool   `json:"healthy"`
+}
+
+func status(cmd *cobra.Command, args []string) error {
+	var err error
+
+	var output string
+	output, err = cmd.Flags().GetString("output")
+	if err != nil {
+		return fmt.Errorf("could not get status: %w", err)
+	}
+	if output != "table" && output != "json" {
+		return fmt.Errorf("could not get status: unknown output format %v", output)
+	}
+
+	ctx := cmd.Context()
+	client, err := getKubeClient()
+	if err != nil {
+		return fmt.Errorf("could not get status: %w", err)
+	}
+
+	release, err := getReleaseStatus(ctx, client)
+	if err != nil {
+		return fmt.Errorf("could not get status: %w", err)
+	}
+
+	if output == "json" {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		err = encoder.Encode(release)
+	} else {
+		err = printReleaseStatus(release)
+	}
+	if err != nil {
+		return fmt.Errorf("could not get status: %w", err)
+	}
+
+	if !release.Healthy {
+		return fmt.Errorf("The Observability Stack release %v is unhealthy", name)
+	}
+
+	return nil
+}
+
+func getReleaseStatus(ctx context.Context, client *KubeClient) (*releaseStatus, error) {
+	release := &releaseStatus{Release: name, Namespace: namespace, Healthy: true}
+
+	var err error
+	release.Revision, release.HelmStatus, err = helmReleaseStatus(ctx)
+	if err != nil {
+		release.HelmStatus = "unknown: " + err.Error()
+	}
+	if release.HelmStatus != "deployed" {
+		release.Healthy = false
+	}
+
+	components, err := stackComponents(name, namespace)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, component := range components {
+		status, err := getComponentStatus(ctx, client, component)
+		if err != nil {
+			return nil, err
+		}
+		if !status.Healthy {
+			release.Healthy = false
+		}
+		release.Components = append(release.Components, *status)
+	}
+
+	return release, nil
+}
+
+// getComponentStatus collects the pods, services and PVCs of a component. A
+// component without any objects counts as not deployed (e.g. disabled in the
+// Helm values) rather than as unhealthy.
+func getComponentStatus(ctx context.Context, client *KubeClient, component stackComponent) (*componentStatus, error) {
+	status := &componentStatus{Name: component.Name, Healthy: true}
+
+	pods, err := client.KubeGetPods(ctx, namespace, component.Selector)
+	if err != nil {
+		return nil, err
+	}
+	jobs := make(map[string]*batchv1.Job)
+	for _, pod := range pods {
+		ps := getPodStatus(pod)
+		if jobName := podJobName(&pod); jobName != "" {
+			job, cached := jobs[jobName]
+			if !cached {
+				// A Job deleted after finishing leaves its pods to be judged
+				// on their own.
+				job, _ = client.KubeGetJob(ctx, namespace, jobName)
+				jobs[jobName] = job
+			}
+			if job != nil {
+				ps.Healthy = jobPodHealthy(job, &pod, ps.Healthy)
+			}
+		}
+		status.Healthy = status.Healthy && ps.Healthy
+		status.Pods = append(status.Pods, ps)
+	}
+
+	services, err := client.KubeGetServices(ctx, namespace, component.Selector)
+	if err != nil {
+		return nil, err
+	}
+	for _, service := range services {
+		ss := serviceStatus{Name: service.Name, Type: string(service.Spec.Type), ClusterIP: service.Spec.ClusterIP, Healthy: true}
+		for _, port := range service.Spec.Ports {
+			ss.Ports = append(ss.Ports, fmt.Sprintf("%d/%v", port.Port, port.Protocol))
+		}
+
+		endpoints, err := client.KubeGetEndpoints(ctx, namespace, service.Name)
+		if err == nil {
+			for _, subset := range endpoints.Subsets {
+				for _, address := range subset.Addresses {
+					for _, port := range subset.Ports {
+						ss.Endpoints = append(ss.Endpoints, fmt.Sprintf("%v:%d", address.IP, port.Port))
+					}
+				}
+			}
+		}
+		// Services without a selector (e.g. the Patroni config service) have
+		// their endpoints managed elsewhere and are not judged here.
+		if len(service.Spec.Selector) != 0 && len(ss.Endpoints) == 0 {
+			ss.Healthy = false
+		}
+		status.Healthy = status.Healthy && ss.Healthy
+		status.Services = append(status.Services, ss)
+	}
+
+	pvcs, err := client.KubeGetPVCs(ctx, namespace, component.Selector)
+	if err != nil {
+		return nil, err
+	}
+	for _, pvc := range pvcs {
+		ps := pvcStatus{Name: pvc.Name, Phase: string(pvc.Status.Phase), Healthy: pvc.Status.Phase == corev1.ClaimBound}
+		if capacity, ok := pvc.Status.Capacity[corev1.ResourceStorage]; ok {
+			ps.Capacity = capacity.String()
+		}
+		status.Healthy = status.Healthy && ps.Healthy
+		status.PVCs = append(status.PVCs, ps)
+	}
+
+	status.Deployed = len(status.Pods) != 0 || len(status.Services) != 0 || len(status.PVCs) != 0
+	if status.Deployed && len(status.Pods) == 0 {
+		status.Healthy = false
+	}
+
+	return status, nil
+}
+
+func getPodStatus(pod corev1.Pod) podStatus {
+	ps := podStatus{
+		Name:    pod.Name,
+		Phase:   string(pod.Status.Phase),
+		Total:   len(pod.Spec.Containers),
+		Created: pod.CreationTimestamp.Time,
+	}
+	for _, container := range pod.Spec.Containers {
+		ps.Images = append(ps.Images, container.Image)
+	}
+	for _, cs := range pod.Status.ContainerStatuses {
+		if cs.Ready {
+			ps.Ready++
+		}
+		ps.Restarts += cs.RestartCount
+	}
+	ps.Healthy, _ = podReady(&pod)
+
+	return ps
+}
+
+// jobPodHealthy judges a pod of a Job by the Job, which retries failed pods:
+// a pod that failed before a later one succeeded is of no concern.
+func jobPodHealthy(job *batchv1.Job, pod *corev1.Pod, podHealthy bool) bool {
+	for _, condition := range job.Status.Conditions {
+		if condition.Status != corev1.ConditionTrue {
+			continue
+		}
+		switch condition.Type {
+		case batchv1.JobComplete:
+			return true
+		case batchv1.JobFailed:
+			return false
+		}
+	}
+
+	return podHealthy || pod.Status.Phase == corev1.PodFailed
+}
+
+// helmReleaseStatus returns the revision and status of the deployed release.
+func helmReleaseStatus(ctx context.Context) (int, string, error) {
+	helm, err := getHelmClient()
+	if err != nil {
+		return 0, "", err
+	}
+
+	release, err := helm.HelmStatus(ctx, name)
+	if err != nil {
+		return 0, "", err
+	}
+
+	return release.Version, release.Info.Status.String(), nil
+}
+
+func printReleaseStatus(release *releaseStatus) error {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+
+	fmt.Fprintf(w, "Release %v in namespace %v: revision %d, %v\n\n", release.Release, release.Namespace, release.Revision, release.HelmStatus)
+
+	fmt.Fprintln(w, "COMPONENT\tSTATUS\tPOD\tREADY\tRESTARTS\tAGE\tIMAGE")
+	for _, component := range release.Components {
+		health := "Healthy"
+		if !component.Deployed {
+			health = "NotDeployed"
+		} else if !component.Healthy {
+			health = "Unhealthy"
+		}
+		if len(component.Pods) == 0 {
+			fmt.Fprintf(w, "%v\t%v\t-\t-\t-\t-\t-\n", component.Name, health)
+		}
+		for _, pod := range component.Pods {
+			age := duration.HumanDuration(time.Since(pod.Created))
+			fmt.Fprintf(w, "%v\t%v\t%v\t%d/%d\t%d\t%v\t%v\n", component.Name, health, pod.Name, pod.Ready, pod.Total, pod.Restarts, age, strings.Join(pod.Images, ","))
+		}
+	}
+
+	fmt.Fprintln(w, "\nCOMPONENT\tSERVICE\tTYPE\tCLUSTER-IP\tPORTS\tENDPOINTS")
+	for _, component := range release.Components {
+		for _, service := range component.Services {
+			endpoints := strings.Join(service.Endpoints, ",")
+			if endpoints == "" {
+				endpoints = "<none>"
+			}
+			fmt.Fprintf(w, "%v\t%v\t%v\t%v\t%v\t%v\n", component.Name, service.Name, service.Type, service.ClusterIP, strings.Join(service.Ports, ","), endpoints)
+		}
+	}
+
+	fmt.Fprintln(w, "\nCOMPONENT\tPVC\tSTATUS\tCAPACITY")
+	for _, component := range release.Components {
+		for _, pvc := range component.PVCs {
+			fmt.Fprintf(w, "%v\t%v\t%v\t%v\n", component.Name, pvc.Name, pvc.Phase, pvc.Capacity)
+		}
+	}
+
+	return w.Flush()
+}
@@ -0,0 +1,115 @@
+package cmd
+
+import (
+	"context"
+	"testing"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestGetComponentStatus(t *testing.T) {
+	oldNamespace := namespace
+	namespace = "ns"
+	defer func() { namespace = oldNamespace }()
+	grafana := map[string]string{"app.kubernetes.io/instance": "gg", "app.kubernetes.io/name": "grafana"}
+
+	unready := testPod("gg-grafana-1", grafana)
+	unready.Status.Phase = corev1.PodPending
+
+	client := newFakeKubeClient(
+		readyPod("gg-grafana-0", grafana),
+		&corev1.Service{
+			ObjectMeta: metav1.ObjectMeta{Name: "gg-grafana", Namespace: "ns", Labels: grafana},
+			Spec:       corev1.ServiceSpec{Selector: grafana, Ports: []corev1.ServicePort{{Port: 80, Protocol: corev1.ProtocolTCP}}},
+		},
+		&corev1.Endpoints{
+			ObjectMeta: metav1.ObjectMeta{Name: "gg-grafana", Namespace: "ns"},
+			Subsets: []corev1.EndpointSubset{{
+				Addresses: []corev1.EndpointAddress{{IP: "10.0.0.1"}},
+				Ports:     []corev1.EndpointPort{{Port: 3000}},
+			}},
+		},
+	)
+
+	status, err := getComponentStatus(context.Background(), client, stackComponent{Name: "Grafana", Selector: grafana})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !status.Deployed || !status.Healthy {
+		t.Fatalf("expected a deployed and healthy component, got %+v", status)
+	}
+	if len(status.Services) != 1 || len(status.Services[0].Endpoints) != 1 || status.Services[0].Endpoints[0] != "10.0.0.1:3000" {
+		t.Fatalf("unexpected services %+v", status.Services)
+	}
+
+	_, err = client.CoreV1().Pods("ns").Create(context.Background(), unready, metav1.CreateOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	status, err = getComponentStatus(context.Background(), client, stackComponent{Name: "Grafana", Selector: grafana})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status.Healthy {
+		t.Fatal("expected a component with a pending pod to be unhealthy")
+	}
+
+	status, err = getComponentStatus(context.Background(), client, stackComponent{Name: "PromLens", Selector: map[string]string{"component": "promlens"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status.Deployed || !status.Healthy {
+		t.Fatalf("expected a missing component to be not deployed but healthy, got %+v", status)
+	}
+}
+
+func TestGetComponentStatusJobRetries(t *testing.T) {
+	oldNamespace := namespace
+	namespace = "ns"
+	defer func() { namespace = oldNamespace }()
+
+	labels := map[string]string{"app": "gg-grafana-db"}
+	job := &batchv1.Job{ObjectMeta: metav1.ObjectMeta{Name: "gg-grafana-db", Namespace: "ns"}}
+	jobPod := func(name string, phase corev1.PodPhase) *corev1.Pod {
+		pod := testPod(name, labels)
+		pod.OwnerReferences = []metav1.OwnerReference{{APIVersion: "batch/v1", Kind: "Job", Name: job.Name, Controller: &[]bool{true}[0]}}
+		pod.Status.Phase = phase
+		return pod
+	}
+	component := stackComponent{Name: "Grafana DB job", Selector: labels}
+
+	job.Status.Conditions = []batchv1.JobCondition{{Type: batchv1.JobComplete, Status: corev1.ConditionTrue}}
+	client := newFakeKubeClient(job, jobPod("gg-grafana-db-1", corev1.PodFailed), jobPod("gg-grafana-db-2", corev1.PodSucceeded))
+	status, err := getComponentStatus(context.Background(), client, component)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !status.Healthy {
+		t.Fatalf("expected a Job that succeeded on a retry to be healthy, got %+v", status)
+	}
+
+	job.Status.Conditions = []batchv1.JobCondition{{Type: batchv1.JobFailed, Status: corev1.ConditionTrue, Reason: "BackoffLimitExceeded"}}
+	client = newFakeKubeClient(job, jobPod("gg-grafana-db-1", corev1.PodFailed))
+	status, err = getComponentStatus(context.Background(), client, component)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status.Healthy {
+		t.Fatalf("expected a failed Job to be unhealthy, got %+v", status)
+	}
+
+	job.Status.Conditions = nil
+	client = newFakeKubeClient(job, jobPod("gg-grafana-db-1", corev1.PodFailed), jobPod("gg-grafana-db-2", corev1.PodPending))
+	status, err = getComponentStatus(context.Background(), client, component)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status.Healthy {
+		t.Fatalf("expected a running Job with a pending pod to be unhealthy, got %+v", status)
+	}
+	if !status.Pods[0].Healthy {
+		t.Fatalf("expected the failed pod of a retrying Job not to count, got %+v", status.Pods)
+	}
+}
@@ -1,7 +1,6 @@
 package cmd
 
 import (
-	"context"
 	"fmt"
 
 	"github.com/spf13/cobra"
@@ -39,13 +38,24 @@ func timescaledbChangePassword(cmd *cobra.Command, args []string) error {
 	}
 
 	fmt.Println("Changing password...")
-	pool, err := OpenConnectionToDB(namespace, name, user, dbname, FORWARD_PORT_TSDB)
+	ctx := cmd.Context()
+	client, err := getKubeClient()
+	if err != nil {
+		return fmt.Errorf("could not change TimescaleDB password: %w", err)
+	}
+
+	pool, err := OpenConnectionToDB(ctx, client, namespace, name, user, dbname)
 	if err != nil {
 		return fmt.Errorf("could not change TimescaleDB password: %w", err)
 	}
 	defer pool.Close()
 
-	secret, err := KubeGetSecret(namespace, name+"-timescaledb-passwords")
+	component, err := getStackComponent(name, namespace, "timescaledb")
+	if err != nil {
+		return fmt.Errorf("could not change TimescaleDB password: %w", err)
+	}
+
+	secret, err := client.KubeGetSecret(ctx, namespace, component.Secrets["passwords"])
 	if err != nil {
 		return fmt.Errorf("could not get TimescaleDB password: %w", err)
 	}
@@ -53,14 +63,14 @@ func timescaledbChangePassword(cmd *cobra.Command, args []string) error {
 	oldpassword := secret.Data[user]
 
 	secret.Data[user] = []byte(password)
-	err = KubeUpdateSecret(namespace, secret)
+	err = client.KubeUpdateSecret(ctx, namespace, secret)
 	if err != nil {
 		return fmt.Errorf("could not change TimescaleDB password: %w", err)
 	}
-	_, err = pool.Exec(context.Background(), "ALTER USER "+user+" WITH PASSWORD '"+password+"'")
+	_, err = pool.Exec(ctx, "ALTER USER "+user+" WITH PASSWORD '"+password+"'")
 	if err != nil {
 		secret.Data[user] = oldpassword
-		_ = KubeUpdateSecret(namespace, secret)
+		_ = client.KubeUpdateSecret(ctx, namespace, secret)
 		return fmt.Errorf("could not change TimescaleDB password: %w", err)
 	}
 
@@ -4,7 +4,6 @@ import (
 	"errors"
 	"fmt"
 	"os"
-	"time"
 
 	"github.com/spf13/cobra"
 
@@ -26,8 +25,7 @@ func init() {
 	timescaledbConnectCmd.Flags().BoolP("master", "m", false, "directly execute session on master node")
 }
 
-func timescaledbConnect(cmd *cobra.Command, args []string) error {
-	var err error
+func timescaledbConnect(cmd *cobra.Command, args []string) (err error) {
 
 	var user string
 	user, err = cmd.Flags().GetString("user")
@@ -41,7 +39,18 @@ func timescaledbConnect(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("could not connect to TimescaleDB: %w", err)
 	}
 
-	secret, err := KubeGetSecret(namespace, name+"-timescaledb-passwords")
+	ctx := cmd.Context()
+	client, err := getKubeClient()
+	if err != nil {
+		return fmt.Errorf("could not connect to TimescaleDB: %w", err)
+	}
+
+	component, err := getStackComponent(name, namespace, "timescaledb")
+	if err != nil {
+		return fmt.Errorf("could not connect to TimescaleDB: %w", err)
+	}
+
+	secret, err := client.KubeGetSecret(ctx, namespace, component.Secrets["passwords"])
 	if err != nil {
 		return fmt.Errorf("could not get TimescaleDB password: %w", err)
 	}
@@ -54,41 +63,42 @@ func timescaledbConnect(cmd *cobra.Command, args []string) error {
 	}
 
 	if master {
-		masterpod, err := KubeGetPodName(namespace, map[string]string{"release": name, "role": "master"})
+		masterpod, err := client.KubeGetPodName(ctx, namespace, component.Primary)
 		if err != nil {
 			return fmt.Errorf("could not connect to TimescaleDB: %w", err)
 		}
 
-		err = KubeExecCmd(namespace, masterpod, "", "psql -U postgres", os.Stdin, true)
+		err = client.KubeExecCmd(ctx, namespace, masterpod, component.Container, "psql -U postgres", os.Stdin, true)
 		if err != nil {
 			return fmt.Errorf("could not connect to TimescaleDB: %w", err)
 		}
 	} else {
 		pod := getPodObject(name, namespace, user, pass)
 
-		err = KubeCreatePod(pod)
+		err = client.KubeCreatePod(ctx, pod)
 		if err != nil {
 			return fmt.Errorf("could not connect to TimescaleDB: %w", err)
 		}
 
-		time.Sleep(time.Second)
+		// The psql pod must not outlive the session, even when interrupted
+		defer func() {
+			cleanupCtx, cancel := cleanupContext()
+			defer cancel()
 
-		err = KubeWaitOnPod(namespace, "psql")
-		if err != nil {
-			KubeDeletePod(namespace, "psql")
-			return fmt.Errorf("could not connect to TimescaleDB: %w", err)
-		}
-		err = KubeExecCmd(namespace, "psql", "", "psql -U "+user+" -h "+name+"."+namespace+".svc.cluster.local postgres", os.Stdin, true)
+			deleteErr := client.KubeDeletePod(cleanupCtx, namespace, "psql")
+			if deleteErr != nil && err == nil {
+				err = fmt.Errorf("could not connect to TimescaleDB: %w", deleteErr)
+			}
+		}()
+
+		err = client.KubeWaitOnPod(ctx, namespace, "psql")
 		if err != nil {
-			KubeDeletePod(namespace, "psql")
 			return fmt.Errorf("could not connect to TimescaleDB: %w", err)
 		}
-
-		err = KubeDeletePod(namespace, "psql")
+		err = client.KubeExecCmd(ctx, namespace, "psql", "", "psql -U "+user+" -h "+name+"."+namespace+".svc.cluster.local postgres", os.Stdin, true)
 		if err != nil {
 			return fmt.Errorf("could not connect to TimescaleDB: %w", err)
 		}
-		time.Sleep(3 * time.Second)
 	}
 
 	return nil
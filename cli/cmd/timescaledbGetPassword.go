@@ -29,7 +29,18 @@ func timescaledbGetPassword(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("could not get TimescaleDB password: %w", err)
 	}
 
-	secret, err := KubeGetSecret(namespace, name+"-timescaledb-passwords")
+	ctx := cmd.Context()
+	client, err := getKubeClient()
+	if err != nil {
+		return fmt.Errorf("could not get TimescaleDB password: %w", err)
+	}
+
+	component, err := getStackComponent(name, namespace, "timescaledb")
+	if err != nil {
+		return fmt.Errorf("could not get TimescaleDB password: %w", err)
+	}
+
+	secret, err := client.KubeGetSecret(ctx, namespace, component.Secrets["passwords"])
 	if err != nil {
 		return fmt.Errorf("could not get TimescaleDB password: %w", err)
 	}
@@ -7,7 +7,6 @@ import (
 )
 
 const LISTEN_PORT_TSDB = 5432
-const FORWARD_PORT_TSDB = 5432
 
 // timescaledbPortForwardCmd represents the timescaledb port-forward command
 var timescaledbPortForwardCmd = &cobra.Command{
@@ -31,17 +30,21 @@ func timescaledbPortForward(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("could not port-forward TimescaleDB: %w", err)
 	}
 
-	podName, err := KubeGetPodName(namespace, map[string]string{"release": name, "role": "master"})
+	ctx := cmd.Context()
+	client, err := getKubeClient()
 	if err != nil {
 		return fmt.Errorf("could not port-forward TimescaleDB: %w", err)
 	}
 
-	_, err = KubePortForwardPod(namespace, podName, port, FORWARD_PORT_TSDB)
+	component, err := getStackComponent(name, namespace, "timescaledb")
 	if err != nil {
 		return fmt.Errorf("could not port-forward TimescaleDB: %w", err)
 	}
 
-	select {}
+	err = runPortForwards(ctx, newPodPortForward(client, namespace, component.Name, port, component.Port, component.Primary))
+	if err != nil {
+		return fmt.Errorf("could not port-forward TimescaleDB: %w", err)
+	}
 
 	return nil
 }
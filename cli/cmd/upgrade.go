@@ -0,0 +1,22 @@
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+)
+
+// upgradeCmd represents the upgrade command
+var upgradeCmd = &cobra.Command{
+	Use:   "upgrade",
+	Short: "Alias for helm upgrade",
+	Args:  cobra.ExactArgs(0),
+	RunE:  upgrade,
+}
+
+func init() {
+	rootCmd.AddCommand(upgradeCmd)
+	addHelmUpgradeFlags(upgradeCmd)
+}
+
+func upgrade(cmd *cobra.Command, args []string) error {
+	return helmUpgrade(cmd, args)
+}
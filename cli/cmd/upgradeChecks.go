@@ -0,0 +1,213 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/Masterminds/semver/v3"
+	"helm.sh/helm/v3/pkg/chartutil"
+	"helm.sh/helm/v3/pkg/release"
+	"helm.sh/helm/v3/pkg/releaseutil"
+	appsv1 "k8s.io/api/apps/v1"
+	"sigs.k8s.io/yaml"
+)
+
+// promscaleRequirements lists, for each Promscale version that raised it, the
+// minimum TimescaleDB extension version Promscale needs.
+var promscaleRequirements = []struct {
+	promscale   string
+	timescaledb string
+}{
+	{"0.1.0", "1.7.3"},
+}
+
+// timescaledbImageTag matches tags of the timescale/timescaledb-ha image,
+// e.g. pg12-ts1.7-latest.
+var timescaledbImageTag = regexp.MustCompile(`^pg(\d+)(?:\.\d+)?-ts(\d+\.\d+)`)
+
+type upgradeCheck struct {
+	Name string
+	Err  error
+}
+
+// runUpgradeChecks compares the current release with the planned upgrade. If
+// extVersion, the installed TimescaleDB extension version, is empty the
+// checks depending on it are skipped.
+func runUpgradeChecks(current, planned *release.Release, extVersion string) ([]upgradeCheck, error) {
+	currentValues, err := chartutil.CoalesceValues(current.Chart, current.Config)
+	if err != nil {
+		return nil, err
+	}
+	plannedValues, err := chartutil.CoalesceValues(planned.Chart, planned.Config)
+	if err != nil {
+		return nil, err
+	}
+
+	checks := []upgradeCheck{
+		{"Promscale version", checkPromscaleVersion(currentValues, plannedValues)},
+		{"TimescaleDB image", checkTimescaleDBImage(currentValues, plannedValues, extVersion)},
+		{"Promscale compatibility", checkPromscaleCompatibility(plannedValues, extVersion)},
+		{"PVC sizes", checkPVCSizes(current.Manifest, planned.Manifest)},
+	}
+	return checks, nil
+}
+
+// getTimescaleDBExtVersion returns the version of the TimescaleDB extension
+// installed in the database Promscale writes to.
+func getTimescaleDBExtVersion(ctx context.Context, client *KubeClient) (string, error) {
+	pool, err := OpenConnectionToDB(ctx, client, namespace, name, "postgres", "postgres")
+	if err != nil {
+		return "", err
+	}
+	defer pool.Close()
+
+	var version string
+	err = pool.QueryRow(ctx, "SELECT extversion FROM pg_extension WHERE extname = 'timescaledb'").Scan(&version)
+	if err != nil {
+		return "", err
+	}
+	return version, nil
+}
+
+// checkPromscaleVersion refuses to downgrade Promscale, whose database schema
+// migrations can not be reverted.
+func checkPromscaleVersion(current, planned chartutil.Values) error {
+	currentVersion := promscaleVersion(current)
+	plannedVersion := promscaleVersion(planned)
+	if currentVersion == nil || plannedVersion == nil {
+		return nil
+	}
+
+	if plannedVersion.LessThan(currentVersion) {
+		return fmt.Errorf("downgrading Promscale from %v to %v is not supported", currentVersion, plannedVersion)
+	}
+	return nil
+}
+
+// checkTimescaleDBImage refuses images of another PostgreSQL major version,
+// which would need the data directory to be upgraded, and images shipping an
+// older TimescaleDB extension than the one installed.
+func checkTimescaleDBImage(current, planned chartutil.Values, extVersion string) error {
+	currentTag, _ := current.PathValue("timescaledb-single.image.tag")
+	plannedTag, _ := planned.PathValue("timescaledb-single.image.tag")
+	currentMatch := timescaledbImageTag.FindStringSubmatch(fmt.Sprint(currentTag))
+	plannedMatch := timescaledbImageTag.FindStringSubmatch(fmt.Sprint(plannedTag))
+	if plannedMatch == nil {
+		return nil
+	}
+
+	if currentMatch != nil && currentMatch[1] != plannedMatch[1] {
+		return fmt.Errorf("changing the PostgreSQL major version from %v to %v is not supported", currentMatch[1], plannedMatch[1])
+	}
+
+	if extVersion == "" {
+		return nil
+	}
+	installed, err := semver.NewVersion(extVersion)
+	if err != nil {
+		return nil
+	}
+	shipped, err := semver.NewVersion(plannedMatch[2])
+	if err != nil {
+		return nil
+	}
+	if shipped.Major() < installed.Major() || (shipped.Major() == installed.Major() && shipped.Minor() < installed.Minor()) {
+		return fmt.Errorf("image %v ships TimescaleDB %v, but version %v is installed", plannedTag, plannedMatch[2], extVersion)
+	}
+	return nil
+}
+
+// checkPromscaleCompatibility checks that the installed TimescaleDB extension
+// is recent enough for the planned Promscale version.
+func checkPromscaleCompatibility(planned chartutil.Values, extVersion string) error {
+	plannedVersion := promscaleVersion(planned)
+	if plannedVersion == nil || extVersion == "" {
+		return nil
+	}
+	installed, err := semver.NewVersion(extVersion)
+	if err != nil {
+		return nil
+	}
+
+	var required *semver.Version
+	for _, requirement := range promscaleRequirements {
+		if !plannedVersion.LessThan(semver.MustParse(requirement.promscale)) {
+			required = semver.MustParse(requirement.timescaledb)
+		}
+	}
+	if required != nil && installed.LessThan(required) {
+		return fmt.Errorf("Promscale %v requires TimescaleDB %v or newer, but version %v is installed", plannedVersion, required, installed)
+	}
+	return nil
+}
+
+// checkPVCSizes refuses changes to the volume claim templates of the stateful
+// sets, which Kubernetes does not allow to be updated.
+func checkPVCSizes(currentManifest, plannedManifest string) error {
+	current, err := volumeClaimSizes(currentManifest)
+	if err != nil {
+		return err
+	}
+	planned, err := volumeClaimSizes(plannedManifest)
+	if err != nil {
+		return err
+	}
+
+	var changes []string
+	for claim, size := range planned {
+		if currentSize, exists := current[claim]; exists && currentSize != size {
+			changes = append(changes, fmt.Sprintf("%v from %v to %v", claim, currentSize, size))
+		}
+	}
+	if len(changes) != 0 {
+		sort.Strings(changes)
+		return fmt.Errorf("volume claim templates can not be resized: %v", strings.Join(changes, ", "))
+	}
+	return nil
+}
+
+// volumeClaimSizes returns the storage requested by the volume claim templates
+// of the stateful sets in a manifest, keyed by <statefulset>/<template>.
+func volumeClaimSizes(manifest string) (map[string]string, error) {
+	sizes := make(map[string]string)
+	for _, doc := range releaseutil.SplitManifests(manifest) {
+		var statefulSet appsv1.StatefulSet
+		err := yaml.Unmarshal([]byte(doc), &statefulSet)
+		if err != nil {
+			return nil, fmt.Errorf("could not parse the release manifest: %w", err)
+		}
+		if statefulSet.Kind != "StatefulSet" {
+			continue
+		}
+
+		for _, claim := range statefulSet.Spec.VolumeClaimTemplates {
+			if size, exists := claim.Spec.Resources.Requests["storage"]; exists {
+				sizes[statefulSet.Name+"/"+claim.Name] = size.String()
+			}
+		}
+	}
+	return sizes, nil
+}
+
+// promscaleVersion returns the version of the Promscale image, or nil if its
+// tag is not a version.
+func promscaleVersion(vals chartutil.Values) *semver.Version {
+	image, err := vals.PathValue("promscale.image")
+	if err != nil {
+		return nil
+	}
+	ref := fmt.Sprint(image)
+	i := strings.LastIndex(ref, ":")
+	if i < 0 || strings.Contains(ref[i:], "/") {
+		return nil
+	}
+
+	version, err := semver.NewVersion(ref[i+1:])
+	if err != nil {
+		return nil
+	}
+	return version
+}
@@ -0,0 +1,91 @@
+package cmd
+
+import (
+	"strings"
+	"testing"
+
+	"helm.sh/helm/v3/pkg/chartutil"
+)
+
+func testValues(promscaleImage, timescaledbTag string) chartutil.Values {
+	return chartutil.Values{
+		"promscale":          map[string]interface{}{"image": promscaleImage},
+		"timescaledb-single": map[string]interface{}{"image": map[string]interface{}{"tag": timescaledbTag}},
+	}
+}
+
+func TestCheckPromscaleVersion(t *testing.T) {
+	current := testValues("timescale/promscale:0.1.1", "pg12-ts1.7-latest")
+
+	if err := checkPromscaleVersion(current, testValues("timescale/promscale:0.1.2", "pg12-ts1.7-latest")); err != nil {
+		t.Fatalf("expected an upgrade to pass, got %v", err)
+	}
+	if err := checkPromscaleVersion(current, testValues("timescale/promscale:0.1.0", "pg12-ts1.7-latest")); err == nil {
+		t.Fatal("expected a downgrade to fail")
+	}
+	if err := checkPromscaleVersion(current, testValues("timescale/promscale:latest", "pg12-ts1.7-latest")); err != nil {
+		t.Fatalf("expected a tag that is not a version to be skipped, got %v", err)
+	}
+}
+
+func TestCheckTimescaleDBImage(t *testing.T) {
+	current := testValues("timescale/promscale:0.1.0", "pg12-ts1.7-latest")
+
+	if err := checkTimescaleDBImage(current, testValues("timescale/promscale:0.1.0", "pg12.4-ts2.0-latest"), "1.7.4"); err != nil {
+		t.Fatalf("expected a newer extension to pass, got %v", err)
+	}
+	if err := checkTimescaleDBImage(current, testValues("timescale/promscale:0.1.0", "pg11-ts1.7-latest"), "1.7.4"); err == nil {
+		t.Fatal("expected a PostgreSQL major version change to fail")
+	}
+	if err := checkTimescaleDBImage(current, testValues("timescale/promscale:0.1.0", "pg12-ts1.6-latest"), "1.7.4"); err == nil {
+		t.Fatal("expected an image with an older extension to fail")
+	}
+	if err := checkTimescaleDBImage(current, testValues("timescale/promscale:0.1.0", "pg12-ts1.6-latest"), ""); err != nil {
+		t.Fatalf("expected the extension check to be skipped without a version, got %v", err)
+	}
+}
+
+func TestCheckPromscaleCompatibility(t *testing.T) {
+	planned := testValues("timescale/promscale:0.1.0", "pg12-ts1.7-latest")
+
+	if err := checkPromscaleCompatibility(planned, "1.7.4"); err != nil {
+		t.Fatalf("expected 1.7.4 to be compatible, got %v", err)
+	}
+	if err := checkPromscaleCompatibility(planned, "1.7.2"); err == nil {
+		t.Fatal("expected 1.7.2 to be incompatible")
+	}
+}
+
+func TestCheckPVCSizes(t *testing.T) {
+	manifest := func(size string) string {
+		return `---
+# Source: tobs/templates/configmap.yaml
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: gg-cli
+---
+# Source: tobs/charts/timescaledb-single/templates/statefulset-timescaledb.yaml
+apiVersion: apps/v1
+kind: StatefulSet
+metadata:
+  name: gg-timescaledb
+spec:
+  volumeClaimTemplates:
+  - metadata:
+      name: storage-volume
+    spec:
+      resources:
+        requests:
+          storage: ` + size + `
+`
+	}
+
+	if err := checkPVCSizes(manifest("150Gi"), manifest("150Gi")); err != nil {
+		t.Fatalf("expected unchanged sizes to pass, got %v", err)
+	}
+	err := checkPVCSizes(manifest("150Gi"), manifest("200Gi"))
+	if err == nil || !strings.Contains(err.Error(), "gg-timescaledb/storage-volume from 150Gi to 200Gi") {
+		t.Fatalf("expected the resize to be reported, got %v", err)
+	}
+}
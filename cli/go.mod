@@ -3,13 +3,28 @@ module cli
 go 1.14
 
 require (
+	github.com/Masterminds/semver/v3 v3.1.0
+	github.com/containerd/containerd v1.3.4
+	github.com/deislabs/oras v0.8.1
+	github.com/evanphx/json-patch v4.2.0+incompatible // indirect
+	github.com/fatih/color v1.7.0
 	github.com/imdario/mergo v0.3.10 // indirect
 	github.com/jackc/pgx/v4 v4.8.0
 	github.com/mitchellh/go-homedir v1.1.0
+	github.com/pmezard/go-difflib v1.0.0
 	github.com/spf13/cobra v1.0.0
 	github.com/spf13/viper v1.7.0
-	k8s.io/api v0.18.6
-	k8s.io/apimachinery v0.18.6
-	k8s.io/client-go v0.18.6
+	github.com/xeipuuv/gojsonschema v1.2.0
+	helm.sh/helm/v3 v3.3.4
+	k8s.io/api v0.18.8
+	k8s.io/apimachinery v0.18.8
+	k8s.io/cli-runtime v0.18.8
+	k8s.io/client-go v0.18.8
 	k8s.io/utils v0.0.0-20200720150651-0bdb4ca86cbc // indirect
+	sigs.k8s.io/yaml v1.2.0
+)
+
+replace (
+	github.com/Azure/go-autorest => github.com/Azure/go-autorest v13.3.2+incompatible
+	github.com/docker/distribution => github.com/docker/distribution v0.0.0-20191216044856-a8371794149d
 )
@@ -1,10 +1,9 @@
 package tests
 
 import (
+	"context"
 	"testing"
 	"time"
-
-	"cli/cmd"
 )
 
 func changeRelease(t testing.TB) {
@@ -67,14 +66,14 @@ func TestConcurrent(t *testing.T) {
 	time.Sleep(10 * time.Second)
 
 	t.Logf("Waiting for pods to initialize...")
-	pods, err := cmd.KubeGetAllPods(NAMESPACE, RELEASE_NAME)
+	pods, err := kubeClient(t).KubeGetAllPods(context.Background(), NAMESPACE, RELEASE_NAME)
 	if err != nil {
 		t.Logf("Error getting all pods")
 		t.Fatal(err)
 	}
 
 	for _, pod := range pods {
-		err = cmd.KubeWaitOnPod(NAMESPACE, pod.Name)
+		err = kubeClient(t).KubeWaitOnPod(context.Background(), NAMESPACE, pod.Name)
 		if err != nil {
 			t.Logf("Error while waiting on pod")
 			t.Fatal(err)
@@ -1,12 +1,11 @@
 package tests
 
 import (
+	"context"
 	"os/exec"
 	"strings"
 	"testing"
 	"time"
-
-	"cli/cmd"
 )
 
 func testInstall(t testing.TB, name, namespace, filename string) {
@@ -112,7 +111,7 @@ func testHelmUninstall(t testing.TB, name, namespace string, deleteData bool) {
 		t.Fatal(err)
 	}
 
-	pods, err := cmd.KubeGetAllPods("tobs", "default")
+	pods, err := kubeClient(t).KubeGetAllPods(context.Background(), "tobs", "default")
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -144,7 +143,7 @@ func testHelmDeleteData(t testing.TB, name, namespace string) {
 		t.Fatal(err)
 	}
 
-	pvcs, err := cmd.KubeGetPVCNames("default", map[string]string{})
+	pvcs, err := kubeClient(t).KubeGetPVCNames(context.Background(), "default", map[string]string{})
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -208,14 +207,14 @@ func TestInstallation(t *testing.T) {
 	time.Sleep(10 * time.Second)
 
 	t.Logf("Waiting for pods to initialize...")
-	pods, err := cmd.KubeGetAllPods(NAMESPACE, RELEASE_NAME)
+	pods, err := kubeClient(t).KubeGetAllPods(context.Background(), NAMESPACE, RELEASE_NAME)
 	if err != nil {
 		t.Logf("Error getting all pods")
 		t.Fatal(err)
 	}
 
 	for _, pod := range pods {
-		err = cmd.KubeWaitOnPod(NAMESPACE, pod.Name)
+		err = kubeClient(t).KubeWaitOnPod(context.Background(), NAMESPACE, pod.Name)
 		if err != nil {
 			t.Logf("Error while waiting on pod")
 			t.Fatal(err)
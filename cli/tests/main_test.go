@@ -7,6 +7,8 @@ import (
 	"os/signal"
 	"testing"
 	"time"
+
+	"cli/cmd"
 )
 
 var RELEASE_NAME = "gg"
@@ -64,6 +66,15 @@ func deleteKube() {
 	}
 }
 
+func kubeClient(t testing.TB) *cmd.KubeClient {
+	client, err := cmd.KubeInit()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return client
+}
+
 func TestMain(m *testing.M) {
 
 	// Signal handling
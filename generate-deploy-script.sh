@@ -19,24 +19,12 @@ set -o nounset
 set -o pipefail
 
 DIR=$(cd $(dirname "${BASH_SOURCE}") && pwd -P)
+FILE_ARG=$(cd $(dirname "${FILE_ARG}") && pwd -P)/$(basename "${FILE_ARG}")
 
-RELEASE_NAME=tobs
-NAMESPACE=tobs
-
-NAMESPACE_VAR="
-apiVersion: v1
-kind: Namespace
-metadata:
-  name: $NAMESPACE
-  labels:
-    app.kubernetes.io/name: $RELEASE_NAME
-    app.kubernetes.io/instance: tobs
-"
-helm dependency update ${DIR}/chart
+RELEASE_NAME=${RELEASE_NAME:-tobs}
+NAMESPACE=${NAMESPACE:-tobs}
 
 OUTPUT_FILE="${DIR}/deploy/static/deploy.yaml"
-cat << EOF | helm template $RELEASE_NAME ${DIR}/chart --namespace $NAMESPACE --values $FILE_ARG > ${OUTPUT_FILE}
-EOF
-
-echo "${NAMESPACE_VAR}
-$(cat ${OUTPUT_FILE})" > ${OUTPUT_FILE}
+cd ${DIR}/cli
+go run . helm template --name $RELEASE_NAME --namespace $NAMESPACE --chart-reference ${DIR}/chart \
+	--filename $FILE_ARG --include-namespace --dependency-update > ${OUTPUT_FILE}